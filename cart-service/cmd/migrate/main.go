@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/config"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/db"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/migrate"
+)
+
+// Usage:
+//
+//	migrate [up]          apply every pending migration (the init container)
+//	migrate down [N]      revert the latest N migrations (default 1)
+//	migrate version       print the schema version
+//	migrate force V       record V as applied and clean, after repairing a
+//	                      failed migration by hand; 0 records none
+func main() {
+	cfg := config.Load()
+
+	gdb, err := db.NewMySQL(cfg.MySQL.DSN())
+	if err != nil {
+		log.Fatal(err)
+	}
+	sqlDB, err := gdb.DB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	migrations, err := migrate.Migrations()
+	if err != nil {
+		log.Fatal(err)
+	}
+	m := migrate.New(sqlDB, migrations, migrate.Options{LockTimeout: cfg.Migrate.LockTimeout})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cmd, args := "up", []string(nil)
+	if len(os.Args) > 1 {
+		cmd, args = os.Args[1], os.Args[2:]
+	}
+	switch cmd {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			log.Printf("applied %d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		printVersion(ctx, m)
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			if steps, err = strconv.Atoi(args[0]); err != nil || steps < 1 {
+				log.Fatalf("invalid number of steps %q", args[0])
+			}
+		}
+		reverted, err := m.Down(ctx, steps)
+		for _, mig := range reverted {
+			log.Printf("reverted %d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		printVersion(ctx, m)
+	case "version":
+		printVersion(ctx, m)
+	case "force":
+		if len(args) != 1 {
+			log.Fatal("usage: migrate force VERSION")
+		}
+		version, err := strconv.Atoi(args[0])
+		if err != nil || version < 0 {
+			log.Fatalf("invalid version %q", args[0])
+		}
+		if err := m.Force(ctx, version); err != nil {
+			log.Fatal(err)
+		}
+		printVersion(ctx, m)
+	default:
+		log.Fatalf("unknown command %q; want up, down, version or force", cmd)
+	}
+}
+
+func printVersion(ctx context.Context, m *migrate.Migrator) {
+	st, err := m.Version(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	dirty := ""
+	if st.Dirty {
+		dirty = " (dirty)"
+	}
+	fmt.Printf("schema version %d%s\n", st.Version, dirty)
+}
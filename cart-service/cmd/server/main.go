@@ -1,13 +1,22 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"os"
 	"strconv"
 
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/catalog"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/checkout"
 	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/config"
 	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/db"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/fx"
 	httpx "github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/http"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/inventory"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/lifecycle"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/promo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/shipping"
 )
 
 func main() {
@@ -18,7 +27,60 @@ func main() {
 		log.Fatal(err)
 	}
 
-	r := httpx.NewRouter(gdb)
+	promos, err := promo.LoadFile(cfg.PromoRulesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("promotion rules file %s not found; no coupons configured\n", cfg.PromoRulesFile)
+		promos, err = promo.NewEngine(promo.Config{})
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ship, err := shipping.LoadFile(cfg.ShippingRulesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("shipping rules file %s not found; shipping is free\n", cfg.ShippingRulesFile)
+		err = nil
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var inv inventory.Port
+	if cfg.Checkout.InventoryURL != "" {
+		inv = inventory.NewHTTPClient(cfg.Checkout.InventoryURL, cfg.Checkout.InventoryTimeout)
+	} else {
+		log.Println("INVENTORY_URL not set; checkout does not reserve stock")
+	}
+
+	var products catalog.Port
+	if cfg.Catalog.URL != "" {
+		products = catalog.NewHTTPClient(cfg.Catalog.URL, cfg.Catalog.Timeout)
+	} else {
+		f, err := catalog.LoadFile(cfg.Catalog.File)
+		if errors.Is(err, os.ErrNotExist) {
+			log.Printf("CATALOG_URL not set and catalog file %s not found; no SKU can be added\n", cfg.Catalog.File)
+			f, err = catalog.NewFile(nil), nil
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		products = f
+	}
+
+	r := httpx.NewRouter(repo.NewGormStore(gdb), httpx.Deps{
+		Promotions: promos,
+		Shipping:   ship,
+		CartTTL:    lifecycle.TTL{Guest: cfg.Carts.GuestTTL, User: cfg.Carts.UserTTL},
+		Currency:   cfg.Carts.DefaultCurrency,
+		FX:         fx.NewRates(cfg.FX.MaxRateAge),
+		Inventory:  inv,
+		Catalog:    catalog.NewCache(products, cfg.Catalog.CacheTTL),
+		Checkout: checkout.Options{
+			ReservationTTL: cfg.Checkout.ReservationTTL,
+			Lease:          cfg.Checkout.Lease,
+		},
+		IdempotencyTTL: cfg.Idempotency.TTL,
+	})
 	log.Printf("cart-service listening on :%d\n", cfg.HTTPPort)
 	log.Fatal(r.Run(":" + strconv.Itoa(cfg.HTTPPort)))
 }
@@ -2,14 +2,39 @@ package main
 
 import (
 	"context"
+	"errors"
+	_ "expvar" // serves /debug/vars
 	"log"
+	"net/http"
+	"os"
 
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/catalogsync"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/checkout"
 	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/config"
 	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/db"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/fx"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/inventory"
 	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/kafka"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/lifecycle"
 	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/outbox"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/pricing"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/promo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/shipping"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/sweeper"
+	"github.com/dhananjayksharma/golang-k8s-microservices/pkg/cloudevents"
+
+	"github.com/google/uuid"
 )
 
+// Usage:
+//
+//	worker                          publish the outbox, run the sweeper, expire
+//	                                idle carts, resume interrupted checkouts and
+//	                                consume catalog/inventory events
+//	worker requeue-dead [id ...]    move DEAD outbox rows (all, or the given
+//	                                outbox_id UUIDs) back to NEW
 func main() {
 	cfg := config.Load()
 
@@ -18,10 +43,124 @@ func main() {
 		log.Fatal(err)
 	}
 
+	var ceMode cloudevents.Mode
+	if cfg.Outbox.CloudEvents != "" {
+		if ceMode, err = cloudevents.ParseMode(cfg.Outbox.CloudEvents); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	prod := kafka.NewProducer(cfg.Kafka.Brokers, cfg.Kafka.Topic)
 	defer prod.Close()
 
-	pub := outbox.NewPublisher(gdb, prod)
-	log.Printf("cart-worker publishing outbox to topic=%s\n", cfg.Kafka.Topic)
+	pub := outbox.NewPublisher(gdb, prod, outbox.Options{
+		MaxAttempts: cfg.Outbox.MaxAttempts,
+		BackoffBase: cfg.Outbox.BackoffBase,
+		BackoffMax:  cfg.Outbox.BackoffMax,
+
+		Workers:      cfg.Outbox.Workers,
+		BatchSize:    cfg.Outbox.BatchSize,
+		PollInterval: cfg.Outbox.PollInterval,
+		Lease:        cfg.Outbox.Lease,
+
+		CloudEvents: ceMode,
+	})
+
+	if len(os.Args) > 1 && os.Args[1] == "requeue-dead" {
+		requeueDead(pub, os.Args[2:])
+		return
+	}
+
+	if cfg.MetricsAddr != "" {
+		go func() {
+			log.Printf("cart-worker metrics on %s/debug/vars\n", cfg.MetricsAddr)
+			log.Println(http.ListenAndServe(cfg.MetricsAddr, nil))
+		}()
+	}
+
+	sw := sweeper.New(gdb, sweeper.Options{
+		Interval:        cfg.Sweeper.Interval,
+		BatchSize:       cfg.Sweeper.BatchSize,
+		MaxBatches:      cfg.Sweeper.MaxBatches,
+		OutboxRetention: cfg.Sweeper.OutboxRetention,
+		ArchiveOutbox:   cfg.Sweeper.ArchiveOutbox,
+	})
+	go func() {
+		log.Printf("cart-worker sweeping every %s (outbox retention %s)\n", cfg.Sweeper.Interval, cfg.Sweeper.OutboxRetention)
+		log.Println(sw.Run(context.Background()))
+	}()
+
+	exp := lifecycle.NewExpirer(gdb, cfg.Carts.ExpiryInterval, cfg.Carts.ExpiryBatchSize)
+	go func() {
+		log.Printf("cart-worker expiring idle carts every %s\n", cfg.Carts.ExpiryInterval)
+		log.Println(exp.Run(context.Background()))
+	}()
+
+	repricer := loadRepricer(cfg)
+
+	var inv inventory.Port
+	if cfg.Checkout.InventoryURL != "" {
+		inv = inventory.NewHTTPClient(cfg.Checkout.InventoryURL, cfg.Checkout.InventoryTimeout)
+	}
+	saga := checkout.NewSaga(repo.NewGormStore(gdb), inv, repricer, checkout.Options{
+		ReservationTTL: cfg.Checkout.ReservationTTL,
+		Lease:          cfg.Checkout.Lease,
+	})
+	res := checkout.NewResumer(saga, cfg.Checkout.ResumeInterval, cfg.Checkout.ResumeBatchSize)
+	go func() {
+		log.Printf("cart-worker resuming stalled checkouts every %s\n", cfg.Checkout.ResumeInterval)
+		log.Println(res.Run(context.Background()))
+	}()
+
+	if cfg.Kafka.CatalogConsumer {
+		cons := kafka.NewConsumer(cfg.Kafka.Brokers, cfg.Kafka.GroupID, cfg.Kafka.CatalogTopics...)
+		defer cons.Close()
+		h := catalogsync.NewHandler(repo.NewGormStore(gdb), repricer)
+		go func() {
+			log.Printf("cart-worker consuming %v as group=%s\n", cfg.Kafka.CatalogTopics, cfg.Kafka.GroupID)
+			log.Println(cons.Run(context.Background(), h.Handle))
+		}()
+	}
+
+	log.Printf("cart-worker publishing outbox to topic=%s with %d workers\n", cfg.Kafka.Topic, cfg.Outbox.Workers)
 	log.Fatal(pub.Run(context.Background()))
 }
+
+// loadRepricer builds the same pricing rules the API uses, so totals
+// recomputed by the worker match totals recomputed by handlers.
+func loadRepricer(cfg config.Config) *pricing.Repricer {
+	promos, err := promo.LoadFile(cfg.PromoRulesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("promotion rules file %s not found; no coupons configured\n", cfg.PromoRulesFile)
+		promos, err = promo.NewEngine(promo.Config{})
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ship, err := shipping.LoadFile(cfg.ShippingRulesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("shipping rules file %s not found; shipping is free\n", cfg.ShippingRulesFile)
+		err = nil
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	return pricing.NewRepricer(promos, ship, fx.NewRates(cfg.FX.MaxRateAge))
+}
+
+func requeueDead(pub *outbox.Publisher, args []string) {
+	var ids [][]byte
+	for _, a := range args {
+		u, err := uuid.Parse(a)
+		if err != nil {
+			log.Fatalf("invalid outbox_id %q: %v", a, err)
+		}
+		ids = append(ids, domain.UUIDToBin16(u))
+	}
+	n, err := pub.Requeue(context.Background(), ids)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("requeued %d dead outbox rows\n", n)
+}
@@ -3,7 +3,9 @@ module github.com/dhananjayksharma/golang-k8s-microservices/cart-service
 go 1.25
 
 require (
+	github.com/dhananjayksharma/golang-k8s-microservices/pkg/cloudevents v0.0.0
 	github.com/gin-gonic/gin v1.10.0
+	github.com/go-sql-driver/mysql v1.7.0
 	github.com/google/uuid v1.6.0
 	github.com/segmentio/kafka-go v0.4.47
 	gorm.io/driver/mysql v1.5.7
@@ -20,7 +22,6 @@ require (
 	github.com/go-playground/locales v0.14.1 // indirect
 	github.com/go-playground/universal-translator v0.18.1 // indirect
 	github.com/go-playground/validator/v10 v10.20.0 // indirect
-	github.com/go-sql-driver/mysql v1.7.0 // indirect
 	github.com/goccy/go-json v0.10.2 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
@@ -43,3 +44,5 @@ require (
 	google.golang.org/protobuf v1.34.1 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/dhananjayksharma/golang-k8s-microservices/pkg/cloudevents => ../pkg/cloudevents
@@ -0,0 +1,69 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Cache keeps lookups for a short TTL so adding the same SKU repeatedly does
+// not call the catalog each time. Products and ErrNotFound answers are cached;
+// other errors are not. Prices may be up to TTL old, which is why it is short:
+// price changes that must reach open carts arrive as catalog events anyway.
+type Cache struct {
+	next Port
+	ttl  time.Duration
+	max  int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	product Product
+	err     error
+	expires time.Time
+}
+
+const defaultCacheSize = 10000
+
+func NewCache(next Port, ttl time.Duration) *Cache {
+	return &Cache{next: next, ttl: ttl, max: defaultCacheSize, now: time.Now, entries: map[string]cacheEntry{}}
+}
+
+func (c *Cache) Lookup(ctx context.Context, sku, variantID string) (Product, error) {
+	if c.ttl <= 0 {
+		return c.next.Lookup(ctx, sku, variantID)
+	}
+	k := key(sku, variantID)
+	now := c.now()
+
+	c.mu.Lock()
+	e, ok := c.entries[k]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.product, e.err
+	}
+
+	p, err := c.next.Lookup(ctx, sku, variantID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return p, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.max {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.max {
+			c.entries = map[string]cacheEntry{}
+		}
+	}
+	c.entries[k] = cacheEntry{product: p, err: err, expires: now.Add(c.ttl)}
+	return p, err
+}
@@ -0,0 +1,132 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFileLookup(t *testing.T) {
+	ctx := context.Background()
+	f := NewFile([]Product{
+		{SKU: "TSHIRT", Name: "T-shirt", UnitPricePaise: 49900},
+		{SKU: "TSHIRT", VariantID: "XL", Name: "T-shirt XL", UnitPricePaise: 54900},
+	})
+
+	tests := []struct {
+		sku, variant string
+		name         string
+		price        int64
+		err          error
+	}{
+		{"TSHIRT", "", "T-shirt", 49900, nil},
+		{"TSHIRT", "XL", "T-shirt XL", 54900, nil},
+		{"TSHIRT", "M", "T-shirt", 49900, nil},
+		{"MUG", "", "", 0, ErrNotFound},
+	}
+	for _, tc := range tests {
+		p, err := f.Lookup(ctx, tc.sku, tc.variant)
+		if !errors.Is(err, tc.err) || p.Name != tc.name || p.UnitPricePaise != tc.price {
+			t.Errorf("%s/%s = %+v, %v", tc.sku, tc.variant, p, err)
+		}
+		if err == nil && p.VariantID != tc.variant {
+			t.Errorf("%s/%s: variant_id = %q", tc.sku, tc.variant, p.VariantID)
+		}
+	}
+}
+
+type countingPort struct {
+	calls int
+	err   error
+}
+
+func (c *countingPort) Lookup(_ context.Context, sku, variantID string) (Product, error) {
+	c.calls++
+	if c.err != nil {
+		return Product{}, c.err
+	}
+	if sku == "GONE" {
+		return Product{}, ErrNotFound
+	}
+	return Product{SKU: sku, VariantID: variantID, UnitPricePaise: int64(100 * c.calls)}, nil
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 4, 1, 10, 0, 0, 0, time.UTC)
+	next := &countingPort{}
+	c := NewCache(next, 30*time.Second)
+	c.now = func() time.Time { return now }
+
+	p1, _ := c.Lookup(ctx, "A", "")
+	p2, _ := c.Lookup(ctx, "A", "")
+	if next.calls != 1 || p1.UnitPricePaise != p2.UnitPricePaise {
+		t.Fatalf("calls = %d, prices %d/%d", next.calls, p1.UnitPricePaise, p2.UnitPricePaise)
+	}
+	if _, err := c.Lookup(ctx, "A", "red"); err != nil || next.calls != 2 {
+		t.Fatalf("variant is a separate entry: calls = %d, %v", next.calls, err)
+	}
+
+	// Not found is cached too.
+	for i := 0; i < 2; i++ {
+		if _, err := c.Lookup(ctx, "GONE", ""); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("err = %v", err)
+		}
+	}
+	if next.calls != 3 {
+		t.Fatalf("calls = %d, want 3", next.calls)
+	}
+
+	now = now.Add(31 * time.Second)
+	if p, _ := c.Lookup(ctx, "A", ""); next.calls != 4 || p.UnitPricePaise != 400 {
+		t.Errorf("after ttl: calls = %d, price = %d", next.calls, p.UnitPricePaise)
+	}
+
+	// Outages are not cached.
+	next.err = errors.New("timeout")
+	now = now.Add(31 * time.Second)
+	_, _ = c.Lookup(ctx, "A", "")
+	_, _ = c.Lookup(ctx, "A", "")
+	if next.calls != 6 {
+		t.Errorf("errors cached: calls = %d, want 6", next.calls)
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/products/TSHIRT":
+			if r.URL.Query().Get("variant_id") != "XL" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"sku":"TSHIRT","variant_id":"XL","name":"T-shirt XL","currency":"INR",
+				"unit_price_paise":54900,"mrp_paise":59900,"tax_rate_bps":1200,"meta":{"category":"apparel"}}`))
+		case "/v1/products/DOWN":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := NewHTTPClient(srv.URL, time.Second)
+
+	p, err := c.Lookup(ctx, "TSHIRT", "XL")
+	if err != nil || p.UnitPricePaise != 54900 || p.MRPPaise == nil || *p.MRPPaise != 59900 || p.TaxRateBps != 1200 {
+		t.Errorf("lookup = %+v, %v", p, err)
+	}
+	if string(p.Meta) != `{"category":"apparel"}` {
+		t.Errorf("meta = %s", p.Meta)
+	}
+	if _, err := c.Lookup(ctx, "MUG", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("404 err = %v", err)
+	}
+	if _, err := c.Lookup(ctx, "DOWN", ""); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("503 err = %v", err)
+	}
+}
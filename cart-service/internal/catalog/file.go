@@ -0,0 +1,59 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// File is a Port over a fixed product list, for local development and tests.
+// A product without a variant_id matches every variant of its SKU that has no
+// entry of its own.
+type File struct {
+	products map[string]Product
+}
+
+// FileConfig is the JSON layout read by LoadFile.
+type FileConfig struct {
+	Products []Product `json:"products"`
+}
+
+func NewFile(products []Product) *File {
+	f := &File{products: make(map[string]Product, len(products))}
+	for _, p := range products {
+		f.products[key(p.SKU, p.VariantID)] = p
+	}
+	return f
+}
+
+// LoadFile reads a JSON product list.
+func LoadFile(path string) (*File, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg FileConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, err
+	}
+	return NewFile(cfg.Products), nil
+}
+
+func (f *File) Lookup(_ context.Context, sku, variantID string) (Product, error) {
+	if p, ok := f.products[key(sku, variantID)]; ok {
+		return p, nil
+	}
+	if p, ok := f.products[key(sku, "")]; ok && variantID != "" {
+		p.VariantID = variantID
+		return p, nil
+	}
+	return Product{}, fmt.Errorf("%w: %s %s", ErrNotFound, sku, variantID)
+}
+
+func key(sku, variantID string) string {
+	if variantID == "" {
+		return sku
+	}
+	return sku + "/" + variantID
+}
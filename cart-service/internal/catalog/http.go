@@ -0,0 +1,60 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTPClient is the Port backed by the catalog service:
+//
+//	GET {base}/v1/products/{sku}?variant_id={variant}  200 Product, 404 unknown
+type HTTPClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewHTTPClient(baseURL string, timeout time.Duration) *HTTPClient {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &HTTPClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (h *HTTPClient) Lookup(ctx context.Context, sku, variantID string) (Product, error) {
+	u := h.baseURL + "/v1/products/" + url.PathEscape(sku)
+	if variantID != "" {
+		u += "?variant_id=" + url.QueryEscape(variantID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return Product{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return Product{}, fmt.Errorf("catalog: lookup %s: %w", sku, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var p Product
+		if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+			return Product{}, fmt.Errorf("catalog: lookup %s: decode response: %w", sku, err)
+		}
+		return p, nil
+	case http.StatusNotFound:
+		return Product{}, fmt.Errorf("%w: %s %s", ErrNotFound, sku, variantID)
+	default:
+		return Product{}, fmt.Errorf("catalog: lookup %s: unexpected status %d", sku, resp.StatusCode)
+	}
+}
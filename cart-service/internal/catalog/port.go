@@ -0,0 +1,31 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// Port resolves a SKU / variant to what the cart may charge for it. Prices,
+// tax rates and display data always come from here, never from the client.
+type Port interface {
+	Lookup(ctx context.Context, sku, variantID string) (Product, error)
+}
+
+// Product is the catalog's view of one SKU / variant. Amounts are in the
+// minor unit of Currency.
+type Product struct {
+	SKU            string          `json:"sku"`
+	VariantID      string          `json:"variant_id,omitempty"`
+	Name           string          `json:"name"`
+	ImageURL       string          `json:"image_url,omitempty"`
+	Currency       string          `json:"currency"`
+	UnitPricePaise int64           `json:"unit_price_paise"`
+	MRPPaise       *int64          `json:"mrp_paise,omitempty"`
+	TaxRateBps     int             `json:"tax_rate_bps"`
+	Availability   string          `json:"availability,omitempty"` // IN_STOCK, LOW_STOCK, OUT_OF_STOCK; empty means IN_STOCK
+	Meta           json.RawMessage `json:"meta,omitempty"`         // category, weight_grams, dimensions ...
+}
+
+// ErrNotFound means the catalog has no such SKU / variant.
+var ErrNotFound = errors.New("catalog: product not found")
@@ -0,0 +1,367 @@
+package catalogsync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/events"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/fx"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/history"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/kafka"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/outbox"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/pricing"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/pkg/cloudevents"
+
+	"github.com/google/uuid"
+)
+
+// Catalog and inventory signals applied to open carts. Each event is
+// processed exactly once per consumer: the processed_events row, the cart
+// line updates, the repriced totals and the outbox events commit together.
+
+// Consumer is the processed_events.consumer value for this handler.
+const Consumer = "cart-service.catalog-sync"
+
+// Incoming event types.
+const (
+	EventPriceChanged = events.CatalogPriceChanged
+	EventStockChanged = events.InventoryStockChanged
+)
+
+// Availability values written to cart_items.availability.
+const (
+	InStock    = domain.AvailabilityInStock
+	LowStock   = domain.AvailabilityLowStock
+	OutOfStock = domain.AvailabilityOutOfStock
+)
+
+// PriceChanged is the data of CatalogPriceChanged.v1. An empty VariantID
+// applies to every variant of the SKU; nil fields are left unchanged.
+type PriceChanged struct {
+	SKU            string `json:"sku"`
+	VariantID      string `json:"variant_id"`
+	Currency       string `json:"currency"`
+	UnitPricePaise *int64 `json:"unit_price_paise"`
+	MRPPaise       *int64 `json:"mrp_paise"`
+	TaxRateBps     *int   `json:"tax_rate_bps"`
+}
+
+// StockChanged is the data of InventoryStockChanged.v1.
+type StockChanged struct {
+	SKU          string `json:"sku"`
+	VariantID    string `json:"variant_id"`
+	Availability string `json:"availability"` // IN_STOCK, LOW_STOCK, OUT_OF_STOCK
+}
+
+type envelope struct {
+	EventID       string          `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	CorrelationID string          `json:"correlation_id"`
+	TraceParent   string          `json:"traceparent"`
+	Data          json.RawMessage `json:"data"`
+}
+
+type Handler struct {
+	store    repo.Store
+	repricer *pricing.Repricer
+}
+
+func NewHandler(store repo.Store, repricer *pricing.Repricer) *Handler {
+	return &Handler{store: store, repricer: repricer}
+}
+
+// Handle is a kafka.HandlerFunc. Unknown event types are ignored; malformed
+// events are skipped; database errors are returned so the message is retried.
+func (h *Handler) Handle(ctx context.Context, msg kafka.Message) error {
+	ev, err := decode(msg)
+	if err != nil {
+		return fmt.Errorf("%w: %v", kafka.ErrSkip, err)
+	}
+	// Older versions of the events are brought up to the shape decoded below.
+	eventType, data, err := events.Upcast(ev.EventType, ev.Data)
+	switch {
+	case errors.Is(err, events.ErrUnknownType):
+		return nil
+	case err != nil:
+		return fmt.Errorf("%w: %v", kafka.ErrSkip, err)
+	}
+	ev.EventType, ev.Data = eventType, data
+
+	switch ev.EventType {
+	case EventPriceChanged:
+		var d PriceChanged
+		if err := json.Unmarshal(ev.Data, &d); err != nil || d.SKU == "" {
+			return fmt.Errorf("%w: bad %s data", kafka.ErrSkip, ev.EventType)
+		}
+		return h.process(ctx, ev, d.SKU, d.VariantID, func(tx repo.Tx, cart *domain.Cart) ([]line, error) {
+			return applyPrice(tx, cart, d)
+		})
+	case EventStockChanged:
+		var d StockChanged
+		if err := json.Unmarshal(ev.Data, &d); err != nil || d.SKU == "" || !validAvailability(d.Availability) {
+			return fmt.Errorf("%w: bad %s data", kafka.ErrSkip, ev.EventType)
+		}
+		return h.process(ctx, ev, d.SKU, d.VariantID, func(tx repo.Tx, cart *domain.Cart) ([]line, error) {
+			return applyStock(tx, cart, d)
+		})
+	default:
+		return nil
+	}
+}
+
+// decode reads the envelope of msg, which is either a CloudEvent (binary or
+// structured) or the legacy JSON envelope.
+func decode(msg kafka.Message) (envelope, error) {
+	ce, err := msg.CloudEvent()
+	switch {
+	case err == nil:
+		return envelope{
+			EventID:       ce.ID,
+			EventType:     ce.Type,
+			CorrelationID: ce.Extensions["correlationid"],
+			TraceParent:   ce.Extensions["traceparent"],
+			Data:          ce.Data,
+		}, nil
+	case !errors.Is(err, cloudevents.ErrNotCloudEvent):
+		return envelope{}, err
+	}
+
+	var ev envelope
+	if err := json.Unmarshal(msg.Value, &ev); err != nil {
+		return envelope{}, err
+	}
+	if ev.EventType == "" {
+		ev.EventType = msg.Headers["event_type"]
+	}
+	return ev, nil
+}
+
+// line describes one changed cart line in an outbound event.
+type line = map[string]any
+
+type applyFunc func(tx repo.Tx, cart *domain.Cart) ([]line, error)
+
+func (h *Handler) process(ctx context.Context, ev envelope, sku, variantID string, apply applyFunc) error {
+	eventID, err := uuid.Parse(ev.EventID)
+	if err != nil {
+		return fmt.Errorf("%w: event_id %q", kafka.ErrSkip, ev.EventID)
+	}
+
+	return h.store.Transaction(ctx, func(tx repo.Tx) error {
+		row := &domain.ProcessedEvent{
+			EventID:   domain.UUIDToBin16(eventID),
+			Consumer:  Consumer,
+			EventType: ev.EventType,
+		}
+		if u, err := uuid.Parse(ev.CorrelationID); err == nil {
+			row.CorrelationID = domain.UUIDToBin16(u)
+		}
+		err := tx.ProcessedEvents().Create(row)
+		if errors.Is(err, repo.ErrDuplicate) {
+			return nil // duplicate delivery: already applied
+		}
+		if err != nil {
+			return err
+		}
+
+		cartIDs, err := tx.Carts().ActiveWithSKU(sku, variantID)
+		if err != nil {
+			return err
+		}
+		for _, cartID := range cartIDs {
+			if err := h.applyToCart(tx, cartID, ev, apply); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (h *Handler) applyToCart(tx repo.Tx, cartID []byte, ev envelope, apply applyFunc) error {
+	cart, err := tx.Carts().Lock(cartID)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if cart.Edit() != nil {
+		return nil // checking out, checked out or abandoned since the lookup
+	}
+	before, err := history.Take(tx, cart)
+	if err != nil {
+		return err
+	}
+
+	lines, err := apply(tx, cart)
+	if err != nil || len(lines) == 0 {
+		return err
+	}
+
+	// Line changes are cart changes: clients holding the old ETag must refresh.
+	if err := tx.Carts().Bump(cart); err != nil {
+		return err
+	}
+	if err := h.repricer.Recompute(tx, cart); err != nil {
+		return err
+	}
+
+	outType, action := events.CartItemsRepriced, history.ActionRepriced
+	if ev.EventType == EventStockChanged {
+		outType, action = events.CartItemsAvailabilityChanged, history.ActionAvailabilityChanged
+	}
+	// The source event id stands in for an idempotency key: redeliveries of
+	// it are skipped.
+	actor := history.Worker("catalog-sync")
+	actor.IdempotencyKey = ev.EventID
+	if err := history.Record(tx, cart.CartID, before, history.Entry{Action: action, Actor: actor}); err != nil {
+		return err
+	}
+
+	cartUUID, err := domain.Bin16ToUUID(cart.CartID)
+	if err != nil {
+		return err
+	}
+	return outbox.Enqueue(tx.Outbox(), cart.CartID, domain.EventEnvelope{
+		EventType:     outType,
+		CorrelationID: cartUUID.String(),
+		TraceParent:   ev.TraceParent,
+		Data: map[string]any{
+			"cart_id":         cartUUID.String(),
+			"cart_version":    cart.Version,
+			"source_event_id": ev.EventID,
+			"lines":           lines,
+		},
+	})
+}
+
+// cartLines returns the lines of a locked cart holding the SKU (and variant,
+// when given); the cart lock covers its lines.
+func cartLines(tx repo.Tx, cartID []byte, sku, variantID string) ([]domain.CartItem, error) {
+	items, err := tx.Items().List(cartID)
+	if err != nil {
+		return nil, err
+	}
+	var out []domain.CartItem
+	for _, it := range items {
+		if it.SKU == sku && (variantID == "" || it.VariantID == variantID) {
+			out = append(out, it)
+		}
+	}
+	return out, nil
+}
+
+// applyPrice updates lines listed in the event's currency. Lines converted
+// from another currency are re-converted at the rate they were added at, so
+// a price change does not also silently apply a newer exchange rate.
+func applyPrice(tx repo.Tx, cart *domain.Cart, d PriceChanged) ([]line, error) {
+	items, err := cartLines(tx, cart.CartID, d.SKU, d.VariantID)
+	if err != nil {
+		return nil, err
+	}
+	var lines []line
+	for _, it := range items {
+		rate, err := lineRate(it)
+		if err != nil {
+			return nil, err
+		}
+		if d.Currency != "" && rate.From != d.Currency {
+			continue
+		}
+		old, changed := it.UnitPricePaise, false
+		if d.UnitPricePaise != nil && !eqInt64(listPrice(it.ListUnitPricePaise, it.UnitPricePaise), d.UnitPricePaise) {
+			v, err := rate.Convert(*d.UnitPricePaise)
+			if err != nil {
+				return nil, err
+			}
+			it.UnitPricePaise, it.ListUnitPricePaise, changed = &v, d.UnitPricePaise, true
+		}
+		if d.MRPPaise != nil && !eqInt64(listPrice(it.ListMRPPaise, it.MRPPaise), d.MRPPaise) {
+			v, err := rate.Convert(*d.MRPPaise)
+			if err != nil {
+				return nil, err
+			}
+			it.MRPPaise, it.ListMRPPaise, changed = &v, d.MRPPaise, true
+		}
+		if d.TaxRateBps != nil && (it.TaxRateBps == nil || *it.TaxRateBps != *d.TaxRateBps) {
+			it.TaxRateBps, changed = d.TaxRateBps, true
+		}
+		if !changed {
+			continue
+		}
+		if err := tx.Items().Update(&it); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line{
+			"sku":                  it.SKU,
+			"variant_id":           it.VariantID,
+			"old_unit_price_paise": old,
+			"unit_price_paise":     it.UnitPricePaise,
+		})
+	}
+	return lines, nil
+}
+
+// lineRate is the rate a line was converted at; lines in the catalog's own
+// currency convert at 1.
+func lineRate(it domain.CartItem) (fx.Rate, error) {
+	from := it.ListCurrency
+	if from == "" {
+		from = it.Currency // added before lines recorded their list price
+	}
+	if it.FXRate == nil {
+		return fx.Parse(from, it.Currency, "1", time.Time{})
+	}
+	var at time.Time
+	if it.FXRateAt != nil {
+		at = *it.FXRateAt
+	}
+	return fx.Parse(from, it.Currency, *it.FXRate, at)
+}
+
+// listPrice is the catalog's price for a line, falling back to the charged
+// price on lines that predate list prices.
+func listPrice(list, charged *int64) *int64 {
+	if list != nil {
+		return list
+	}
+	return charged
+}
+
+func applyStock(tx repo.Tx, cart *domain.Cart, d StockChanged) ([]line, error) {
+	items, err := cartLines(tx, cart.CartID, d.SKU, d.VariantID)
+	if err != nil {
+		return nil, err
+	}
+	var lines []line
+	for _, it := range items {
+		if it.Availability == d.Availability {
+			continue
+		}
+		old := it.Availability
+		it.Availability = d.Availability
+		if err := tx.Items().Update(&it); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line{
+			"sku":              it.SKU,
+			"variant_id":       it.VariantID,
+			"old_availability": old,
+			"availability":     d.Availability,
+		})
+	}
+	return lines, nil
+}
+
+func validAvailability(s string) bool {
+	return s == InStock || s == LowStock || s == OutOfStock
+}
+
+func eqInt64(a, b *int64) bool {
+	return a != nil && b != nil && *a == *b
+}
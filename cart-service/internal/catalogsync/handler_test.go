@@ -0,0 +1,141 @@
+package catalogsync
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/kafka"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/pricing"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/promo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+)
+
+// Messages rejected before any database access.
+func TestHandleRejectsWithoutDB(t *testing.T) {
+	h := &Handler{}
+
+	binary := func(eventType string) map[string]string {
+		return map[string]string{"ce_specversion": "1.0", "ce_id": "x", "ce_source": "/catalog-service", "ce_type": eventType}
+	}
+	structured := map[string]string{"content-type": "application/cloudevents+json"}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		value   string
+		skip    bool
+	}{
+		{"not json", nil, `{`, true},
+		{"unknown event type is ignored", nil, `{"event_id":"x","event_type":"ProductRenamed.v1","data":{}}`, false},
+		{"price change without sku", nil, `{"event_id":"x","event_type":"CatalogPriceChanged.v1","data":{"unit_price_paise":100}}`, true},
+		{"unknown availability", nil, `{"event_id":"x","event_type":"InventoryStockChanged.v1","data":{"sku":"A","availability":"MAYBE"}}`, true},
+		{"event_id not a uuid", nil, `{"event_id":"x","event_type":"InventoryStockChanged.v1","data":{"sku":"A","availability":"OUT_OF_STOCK"}}`, true},
+		{"binary cloudevent of unknown type", binary("ProductRenamed.v1"), `{}`, false},
+		{"binary cloudevent without id", map[string]string{"ce_specversion": "1.0", "ce_source": "/x", "ce_type": "CatalogPriceChanged.v1"}, `{}`, true},
+		{"binary cloudevent, id not a uuid", binary("InventoryStockChanged.v1"), `{"sku":"A","availability":"OUT_OF_STOCK"}`, true},
+		{"structured cloudevent without sku", structured, `{"specversion":"1.0","id":"x","source":"/catalog-service","type":"CatalogPriceChanged.v1","data":{"unit_price_paise":100}}`, true},
+		{"structured cloudevent, not json", structured, `{`, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := h.Handle(context.Background(), kafka.Message{Headers: tc.headers, Value: []byte(tc.value)})
+			if got := errors.Is(err, kafka.ErrSkip); got != tc.skip || (!tc.skip && err != nil) {
+				t.Fatalf("err = %v, want skip=%v", err, tc.skip)
+			}
+		})
+	}
+}
+
+// A redelivered event changes nothing, even after a later event moved the
+// line on.
+func TestHandleRedeliveryChangesNothing(t *testing.T) {
+	store := repo.NewMemory()
+	cartID := []byte("cart-00000000001")
+	price := int64(19900)
+	err := store.Insert(
+		domain.Cart{CartID: cartID, OwnerType: "GUEST", GuestID: "g", Channel: "WEB", Status: domain.CartActive, Currency: "INR", Version: 1},
+		domain.CartItem{CartItemID: []byte("item-1"), CartID: cartID, SKU: "SOCK-001", Qty: 2, Currency: "INR", UnitPricePaise: &price, Availability: InStock},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	promos, err := promo.NewEngine(promo.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(store, pricing.NewRepricer(promos, nil, nil))
+
+	priceEvent := func(id string, paise int) kafka.Message {
+		return kafka.Message{Value: []byte(`{"event_id":"` + id + `","event_type":"CatalogPriceChanged.v1",` +
+			`"data":{"sku":"SOCK-001","currency":"INR","unit_price_paise":` + strconv.Itoa(paise) + `}}`)}
+	}
+	first := priceEvent("6f1c1a52-8d5e-4a43-9a51-0d4c3f0e2b01", 18900)
+	for _, msg := range []kafka.Message{first, priceEvent("6f1c1a52-8d5e-4a43-9a51-0d4c3f0e2b02", 17900)} {
+		if err := h.Handle(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type state struct {
+		version, history, outbox int
+		price                    int64
+	}
+	read := func() state {
+		var s state
+		err := store.Transaction(context.Background(), func(tx repo.Tx) error {
+			cart, err := tx.Carts().Get(cartID)
+			if err != nil {
+				return err
+			}
+			items, err := tx.Items().List(cartID)
+			if err != nil {
+				return err
+			}
+			entries, err := tx.History().List(cartID, 0, 100)
+			s = state{version: cart.Version, history: len(entries), price: *items[0].UnitPricePaise}
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.outbox = len(store.Outbox())
+		return s
+	}
+	before := read()
+	if want := (state{version: 3, history: 2, outbox: 2, price: 17900}); before != want {
+		t.Fatalf("after two events: %+v, want %+v", before, want)
+	}
+	if err := h.Handle(context.Background(), first); err != nil {
+		t.Fatal(err)
+	}
+	if after := read(); after != before {
+		t.Errorf("redelivery changed the cart: %+v, want %+v", after, before)
+	}
+}
+
+func TestLineRate(t *testing.T) {
+	rate := "83.25"
+	tests := []struct {
+		name string
+		item domain.CartItem
+		from string
+		want int64 // 1999 list minor units in the cart's currency
+	}{
+		{"legacy line", domain.CartItem{Currency: "INR"}, "INR", 1999},
+		{"listed in cart currency", domain.CartItem{Currency: "INR", ListCurrency: "INR"}, "INR", 1999},
+		{"converted at recorded rate", domain.CartItem{Currency: "INR", ListCurrency: "USD", FXRate: &rate}, "USD", 166417},
+	}
+	for _, tc := range tests {
+		r, err := lineRate(tc.item)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		got, err := r.Convert(1999)
+		if r.From != tc.from || r.To != "INR" || err != nil || got != tc.want {
+			t.Errorf("%s: %s->%s converts 1999 to %d (%v), want %s->INR %d", tc.name, r.From, r.To, got, err, tc.from, tc.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package checkout
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+)
+
+// Metrics are published under "cart_checkout" on /debug/vars.
+var metrics = expvar.NewMap("cart_checkout")
+
+// Resumer finishes sagas whose runner went away: any RESERVING or
+// COMPENSATING saga whose lease lapsed is claimed (one replica wins the
+// conditional lease update) and Run again.
+type Resumer struct {
+	saga      *Saga
+	interval  time.Duration
+	batchSize int
+}
+
+// resumable are the statuses of sagas still running.
+var resumable = []string{StatusReserving, StatusCompensating}
+
+func NewResumer(saga *Saga, interval time.Duration, batchSize int) *Resumer {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+	return &Resumer{saga: saga, interval: interval, batchSize: batchSize}
+}
+
+func (r *Resumer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := r.resumeBatch(ctx); err != nil {
+				metrics.Add("errors", 1)
+				log.Printf("checkout: %v\n", err)
+			}
+		}
+	}
+}
+
+// resumeBatch resumes up to batchSize stalled sagas and returns how many it
+// claimed.
+func (r *Resumer) resumeBatch(ctx context.Context) (int, error) {
+	now := r.saga.now().UTC()
+	var ids [][]byte
+	if err := r.saga.store.Transaction(ctx, func(tx repo.Tx) error {
+		var err error
+		ids, err = tx.Sagas().Stalled(resumable, now, r.batchSize)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+
+	claimed := 0
+	for _, id := range ids {
+		ok, err := r.claim(ctx, id, now)
+		if err != nil {
+			return claimed, err
+		}
+		if !ok {
+			continue // another replica took it
+		}
+		claimed++
+		metrics.Add("resumed", 1)
+
+		saga, err := r.saga.Run(ctx, id, nil)
+		if err != nil {
+			metrics.Add("errors", 1)
+			log.Printf("checkout: resume saga %s: %v\n", bin16String(id), err)
+			continue
+		}
+		metrics.Add(saga.Status, 1)
+	}
+	return claimed, nil
+}
+
+func (r *Resumer) claim(ctx context.Context, sagaID []byte, now time.Time) (bool, error) {
+	var ok bool
+	err := r.saga.store.Transaction(ctx, func(tx repo.Tx) error {
+		var err error
+		ok, err = tx.Sagas().Claim(sagaID, resumable, now, now.Add(r.saga.opts.Lease))
+		return err
+	})
+	return ok, err
+}
@@ -0,0 +1,380 @@
+package checkout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/events"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/history"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/inventory"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/outbox"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/pricing"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/storedvalue"
+
+	"github.com/google/uuid"
+)
+
+// Checkout is a saga over the cart and inventory-service:
+//
+//  1. Start (in the caller's transaction): the cart moves to CHECKOUT_PENDING
+//     and a checkout_sagas row lists one reservation per line.
+//  2. Run reserves the lines one by one, saving each outcome on the saga row.
+//  3. All reserved: the cart is re-priced and moves to CHECKED_OUT, gift card /
+//     wallet holds are captured and CartCheckedOut.v1 is emitted.
+//     Otherwise the saga turns COMPENSATING: every reservation that was or may
+//     have been made is released, the cart goes back to ACTIVE and
+//     CartCheckoutFailed.v1 is emitted.
+//
+// Reservations are keyed "<saga_id>:<line>", so a saga interrupted by a crash
+// is simply Run again (by the worker's Resumer once its lease lapses) without
+// reserving anything twice.
+
+// Saga statuses.
+const (
+	StatusReserving    = "RESERVING"
+	StatusCompensating = "COMPENSATING"
+	StatusCompleted    = "COMPLETED"
+	StatusFailed       = "FAILED"
+)
+
+// Line statuses.
+const (
+	LinePending  = "PENDING"
+	LineReserved = "RESERVED"
+	LineRejected = "REJECTED" // not enough stock
+	LineUnknown  = "UNKNOWN"  // the call failed; stock may or may not be held
+	LineReleased = "RELEASED"
+)
+
+// Failure reasons recorded on FAILED sagas.
+const (
+	ReasonOutOfStock           = "OUT_OF_STOCK"
+	ReasonInventoryUnavailable = "INVENTORY_UNAVAILABLE"
+)
+
+const (
+	EventCartCheckedOut     = events.CartCheckedOut
+	EventCartCheckoutFailed = events.CartCheckoutFailed
+)
+
+// Line is one reservation of a saga, stored in checkout_sagas.lines.
+type Line struct {
+	Key           string     `json:"reservation_key"`
+	SKU           string     `json:"sku"`
+	VariantID     string     `json:"variant_id,omitempty"`
+	Qty           int        `json:"qty"`
+	Status        string     `json:"status"`
+	ReservationID string     `json:"reservation_id,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+type Options struct {
+	// ReservationTTL is how long inventory holds stock for an order to be
+	// created from the checkout.
+	ReservationTTL time.Duration
+	// Lease is how long a runner owns a saga after each step; the Resumer
+	// takes over sagas whose lease lapsed.
+	Lease time.Duration
+}
+
+// Request is the context of the checkout request, carried into the events.
+type Request struct {
+	ClientID       string
+	IdempotencyKey string
+	TraceParent    string
+}
+
+// DoneFunc runs inside the transaction that ends a saga (COMPLETED or FAILED),
+// e.g. to record the HTTP response against the request's idempotency key.
+type DoneFunc func(tx repo.Tx, saga *domain.CheckoutSaga) error
+
+type Saga struct {
+	store     repo.Store
+	inventory inventory.Port
+	repricer  *pricing.Repricer
+	opts      Options
+	now       func() time.Time
+}
+
+// NewSaga returns the checkout saga. A nil inventory port means stock is not
+// reserved (an unlimited in-memory fake is used).
+func NewSaga(store repo.Store, inv inventory.Port, repricer *pricing.Repricer, opts Options) *Saga {
+	if inv == nil {
+		inv = inventory.NewFake(nil)
+	}
+	if opts.ReservationTTL <= 0 {
+		opts.ReservationTTL = 15 * time.Minute
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = time.Minute
+	}
+	return &Saga{store: store, inventory: inv, repricer: repricer, opts: opts, now: time.Now}
+}
+
+// Start records a saga for a locked cart the caller has already moved to
+// CHECKOUT_PENDING, in the caller's transaction. Run it after commit.
+func (s *Saga) Start(tx repo.Tx, cart *domain.Cart, items []domain.CartItem, req Request) (*domain.CheckoutSaga, error) {
+	sagaID := uuid.New()
+	lines := make([]Line, len(items))
+	for i, it := range items {
+		lines[i] = Line{
+			Key:       fmt.Sprintf("%s:%d", sagaID, i),
+			SKU:       it.SKU,
+			VariantID: it.VariantID,
+			Qty:       it.Qty,
+			Status:    LinePending,
+		}
+	}
+	row := &domain.CheckoutSaga{
+		SagaID:         domain.UUIDToBin16(sagaID),
+		CartID:         cart.CartID,
+		Status:         StatusReserving,
+		Lines:          encodeLines(lines),
+		ClientID:       req.ClientID,
+		IdempotencyKey: req.IdempotencyKey,
+		TraceParent:    req.TraceParent,
+		LeaseUntil:     s.now().UTC().Add(s.opts.Lease),
+	}
+	if err := tx.Sagas().Create(row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// Run drives a saga from wherever it stopped to COMPLETED or FAILED. An error
+// leaves it RESERVING or COMPENSATING for the Resumer to pick up; the returned
+// saga reflects what was saved.
+func (s *Saga) Run(ctx context.Context, sagaID []byte, done DoneFunc) (*domain.CheckoutSaga, error) {
+	var saga *domain.CheckoutSaga
+	if err := s.store.Transaction(ctx, func(tx repo.Tx) error {
+		var err error
+		saga, err = tx.Sagas().Get(sagaID)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	lines, err := DecodeLines(saga.Lines)
+	if err != nil {
+		return saga, err
+	}
+	save := func() error { return s.save(ctx, saga, lines) }
+
+	if saga.Status == StatusReserving {
+		reason, msg, err := reserveLines(ctx, s.inventory, bin16String(saga.CartID), s.opts.ReservationTTL, lines, save)
+		if err != nil {
+			return saga, err
+		}
+		if reason == "" {
+			return saga, s.end(ctx, saga, lines, StatusCompleted, done)
+		}
+		saga.Status, saga.FailureReason, saga.Error = StatusCompensating, reason, msg
+		if err := save(); err != nil {
+			return saga, err
+		}
+	}
+	if saga.Status == StatusCompensating {
+		if err := releaseLines(ctx, s.inventory, lines, save); err != nil {
+			return saga, err
+		}
+		return saga, s.end(ctx, saga, lines, StatusFailed, done)
+	}
+	return saga, nil
+}
+
+// reserveLines reserves every PENDING line in order and saves after each.
+// It stops at the first refusal or failure and reports why; err is only set
+// when saving fails.
+func reserveLines(ctx context.Context, inv inventory.Port, cartID string, ttl time.Duration, lines []Line, save func() error) (reason, msg string, err error) {
+	for i := range lines {
+		l := &lines[i]
+		if l.Status != LinePending {
+			continue
+		}
+		res, rerr := inv.Reserve(ctx, inventory.Request{
+			Key:       l.Key,
+			CartID:    cartID,
+			SKU:       l.SKU,
+			VariantID: l.VariantID,
+			Qty:       l.Qty,
+			TTL:       ttl,
+		})
+		switch {
+		case rerr == nil:
+			l.Status, l.ReservationID = LineReserved, res.ID
+			if !res.ExpiresAt.IsZero() {
+				l.ExpiresAt = &res.ExpiresAt
+			}
+		case errors.Is(rerr, inventory.ErrInsufficientStock):
+			l.Status, l.Error = LineRejected, rerr.Error()
+			return ReasonOutOfStock, rerr.Error(), nil
+		default:
+			l.Status, l.Error = LineUnknown, rerr.Error()
+			return ReasonInventoryUnavailable, rerr.Error(), nil
+		}
+		if err := save(); err != nil {
+			return "", "", err
+		}
+	}
+	return "", "", nil
+}
+
+// releaseLines releases every line that holds, or may hold, stock. Lines never
+// attempted and lines refused hold nothing.
+func releaseLines(ctx context.Context, inv inventory.Port, lines []Line, save func() error) error {
+	for i := range lines {
+		l := &lines[i]
+		if l.Status != LineReserved && l.Status != LineUnknown {
+			continue
+		}
+		if err := inv.Release(ctx, l.Key); err != nil {
+			return err
+		}
+		l.Status = LineReleased
+		if err := save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// end closes the saga in one transaction with the cart change and its event.
+// The saga row is locked first so that of two runners only one ends it.
+func (s *Saga) end(ctx context.Context, saga *domain.CheckoutSaga, lines []Line, status string, done DoneFunc) error {
+	now := s.now().UTC()
+	from := saga.Status
+	err := s.store.Transaction(ctx, func(tx repo.Tx) error {
+		current, err := tx.Sagas().Lock(saga.SagaID)
+		if err != nil {
+			return err
+		}
+		if current.Status != from {
+			return fmt.Errorf("checkout saga %s: ended by another runner (%s)", bin16String(saga.SagaID), current.Status)
+		}
+
+		cart, err := tx.Carts().Lock(saga.CartID)
+		if err != nil {
+			return err
+		}
+		before, err := history.Take(tx, cart)
+		if err != nil {
+			return err
+		}
+		cartStatus := cart.Status
+		saga.Status, saga.CompletedAt, saga.Lines = status, &now, encodeLines(lines)
+		action := history.ActionCheckedOut
+		if status == StatusCompleted {
+			err = s.checkOut(tx, saga, cart, lines, now)
+		} else {
+			action = history.ActionCheckoutFailed
+			err = s.reopen(tx, saga, cart, lines)
+		}
+		if err != nil {
+			return err
+		}
+		if cart.Status != cartStatus {
+			if err := history.Record(tx, cart.CartID, before, history.Entry{
+				Action: action,
+				Actor:  history.Actor{ClientID: saga.ClientID, IdempotencyKey: saga.IdempotencyKey},
+			}); err != nil {
+				return err
+			}
+		}
+		if err := tx.Sagas().Update(saga); err != nil {
+			return err
+		}
+		if done != nil {
+			return done(tx, saga)
+		}
+		return nil
+	})
+	if err != nil {
+		saga.Status, saga.CompletedAt = from, nil
+	}
+	return err
+}
+
+// checkOut moves the CHECKOUT_PENDING cart to CHECKED_OUT and emits the order
+// snapshot together with the reservations order-service converts.
+func (s *Saga) checkOut(tx repo.Tx, saga *domain.CheckoutSaga, cart *domain.Cart, lines []Line, now time.Time) error {
+	if err := cart.Transition(domain.CartCheckedOut); err != nil {
+		return err
+	}
+	priced, err := s.repricer.Reprice(tx, cart)
+	if err != nil {
+		return err
+	}
+	if err := tx.Carts().Bump(cart); err != nil {
+		return err
+	}
+	if err := storedvalue.Capture(tx.StoredValue(), cart.CartID); err != nil {
+		return err
+	}
+
+	data := Snapshot(cart, priced, now)
+	data["client_id"] = saga.ClientID
+	data["saga_id"] = bin16String(saga.SagaID)
+	data["reservations"] = lines
+	return s.enqueue(tx, saga, cart, EventCartCheckedOut, data)
+}
+
+// reopen hands the cart back to the shopper after a failed checkout.
+func (s *Saga) reopen(tx repo.Tx, saga *domain.CheckoutSaga, cart *domain.Cart, lines []Line) error {
+	if cart.Status != domain.CartCheckoutPending {
+		return nil // nothing to give back
+	}
+	if err := cart.Transition(domain.CartActive); err != nil {
+		return err
+	}
+	if err := tx.Carts().Bump(cart); err != nil {
+		return err
+	}
+	return s.enqueue(tx, saga, cart, EventCartCheckoutFailed, map[string]any{
+		"cart_id":        bin16String(cart.CartID),
+		"cart_version":   cart.Version,
+		"client_id":      saga.ClientID,
+		"saga_id":        bin16String(saga.SagaID),
+		"failure_reason": saga.FailureReason,
+		"error":          saga.Error,
+		"lines":          lines,
+	})
+}
+
+func (s *Saga) enqueue(tx repo.Tx, saga *domain.CheckoutSaga, cart *domain.Cart, eventType string, data map[string]any) error {
+	return outbox.Enqueue(tx.Outbox(), cart.CartID, domain.EventEnvelope{
+		EventType:      eventType,
+		CorrelationID:  bin16String(cart.CartID),
+		TraceParent:    saga.TraceParent,
+		IdempotencyKey: saga.IdempotencyKey,
+		Data:           data,
+	})
+}
+
+// save records progress and extends the runner's lease.
+func (s *Saga) save(ctx context.Context, saga *domain.CheckoutSaga, lines []Line) error {
+	saga.Lines = encodeLines(lines)
+	saga.LeaseUntil = s.now().UTC().Add(s.opts.Lease)
+	return s.store.Transaction(ctx, func(tx repo.Tx) error {
+		return tx.Sagas().Update(saga)
+	})
+}
+
+// DecodeLines parses checkout_sagas.lines.
+func DecodeLines(s string) ([]Line, error) {
+	var lines []Line
+	if s == "" {
+		return lines, nil
+	}
+	err := json.Unmarshal([]byte(s), &lines)
+	return lines, err
+}
+
+func encodeLines(lines []Line) string {
+	b, _ := json.Marshal(lines)
+	return string(b)
+}
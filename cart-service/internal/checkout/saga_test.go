@@ -0,0 +1,170 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/inventory"
+)
+
+func testLines(saga string, skus ...string) []Line {
+	lines := make([]Line, len(skus))
+	for i, sku := range skus {
+		lines[i] = Line{Key: fmt.Sprintf("%s:%d", saga, i), SKU: sku, Qty: 2, Status: LinePending}
+	}
+	return lines
+}
+
+func statuses(lines []Line) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = l.Status
+	}
+	return out
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReserveAndCompensate(t *testing.T) {
+	ctx := context.Background()
+	errDown := errors.New("connection refused")
+
+	tests := []struct {
+		name       string
+		stock      map[string]int
+		fail       string // SKU whose Reserve fails with errDown
+		reason     string
+		reserved   []string
+		compensate []string
+	}{
+		{
+			name:     "all reserved",
+			stock:    map[string]int{"A": 5, "B": 5, "C": 5},
+			reserved: []string{LineReserved, LineReserved, LineReserved},
+		},
+		{
+			name:       "out of stock releases earlier lines",
+			stock:      map[string]int{"A": 5, "B": 1, "C": 5},
+			reason:     ReasonOutOfStock,
+			reserved:   []string{LineReserved, LineRejected, LinePending},
+			compensate: []string{LineReleased, LineRejected, LinePending},
+		},
+		{
+			name:       "unknown outcome is released too",
+			stock:      map[string]int{"A": 5, "B": 5, "C": 5},
+			fail:       "C",
+			reason:     ReasonInventoryUnavailable,
+			reserved:   []string{LineReserved, LineReserved, LineUnknown},
+			compensate: []string{LineReleased, LineReleased, LineReleased},
+		},
+	}
+	for _, tc := range tests {
+		inv := inventory.NewFake(tc.stock)
+		inv.Fail = func(r inventory.Request) error {
+			if r.SKU == tc.fail {
+				return errDown
+			}
+			return nil
+		}
+		lines := testLines("s1", "A", "B", "C")
+		saves := 0
+		save := func() error { saves++; return nil }
+
+		reason, _, err := reserveLines(ctx, inv, "cart", time.Minute, lines, save)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if reason != tc.reason || !sameStrings(statuses(lines), tc.reserved) {
+			t.Errorf("%s: reserve = %q %v, want %q %v", tc.name, reason, statuses(lines), tc.reason, tc.reserved)
+		}
+		if reason == "" {
+			if saves != len(lines) {
+				t.Errorf("%s: saved %d times, want after every line", tc.name, saves)
+			}
+			continue
+		}
+
+		if err := releaseLines(ctx, inv, lines, save); err != nil {
+			t.Fatalf("%s: release: %v", tc.name, err)
+		}
+		if !sameStrings(statuses(lines), tc.compensate) {
+			t.Errorf("%s: compensate = %v, want %v", tc.name, statuses(lines), tc.compensate)
+		}
+		if held := inv.Held(); len(held) != 0 {
+			t.Errorf("%s: still held after compensation: %v", tc.name, held)
+		}
+		for sku, want := range tc.stock {
+			if got := inv.Available(sku, ""); got != want {
+				t.Errorf("%s: %s available = %d, want %d", tc.name, sku, got, want)
+			}
+		}
+	}
+}
+
+// A saga resumed after a crash reserves with the same keys, so lines reserved
+// before the crash (but not yet saved) are not reserved twice.
+func TestResumeDoesNotDoubleReserve(t *testing.T) {
+	ctx := context.Background()
+	inv := inventory.NewFake(map[string]int{"A": 3})
+	lines := testLines("s2", "A")
+
+	crash := errors.New("crash")
+	if _, _, err := reserveLines(ctx, inv, "cart", time.Minute, lines, func() error { return crash }); !errors.Is(err, crash) {
+		t.Fatalf("err = %v", err)
+	}
+	// The save failed: the stored saga still has the line PENDING.
+	stored := testLines("s2", "A")
+	reason, _, err := reserveLines(ctx, inv, "cart", time.Minute, stored, func() error { return nil })
+	if err != nil || reason != "" {
+		t.Fatalf("resume = %q, %v", reason, err)
+	}
+	if got := inv.Available("A", ""); got != 1 {
+		t.Errorf("A available = %d, want 1 (reserved once)", got)
+	}
+}
+
+func TestReleaseFailureStopsCompensation(t *testing.T) {
+	ctx := context.Background()
+	lines := testLines("s3", "A", "B")
+	lines[0].Status, lines[1].Status = LineReserved, LineReserved
+
+	if err := releaseLines(ctx, failingRelease{}, lines, func() error { return nil }); err == nil {
+		t.Fatal("expected release error")
+	}
+	if lines[0].Status != LineReserved {
+		t.Errorf("line marked %s although release failed", lines[0].Status)
+	}
+}
+
+type failingRelease struct{}
+
+func (failingRelease) Reserve(context.Context, inventory.Request) (inventory.Reservation, error) {
+	return inventory.Reservation{}, nil
+}
+
+func (failingRelease) Release(context.Context, string) error { return errors.New("timeout") }
+
+func TestDecodeLines(t *testing.T) {
+	in := testLines("s4", "A", "B")
+	in[0].Status, in[0].ReservationID = LineReserved, "r1"
+	out, err := DecodeLines(encodeLines(in))
+	if err != nil || len(out) != 2 || out[0].ReservationID != "r1" || out[1].Key != "s4:1" {
+		t.Errorf("round trip = %+v, %v", out, err)
+	}
+	if out, err := DecodeLines(""); err != nil || len(out) != 0 {
+		t.Errorf("empty = %+v, %v", out, err)
+	}
+}
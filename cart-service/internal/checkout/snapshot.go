@@ -0,0 +1,105 @@
+package checkout
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/money"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/pricing"
+)
+
+// Snapshot is the data of CartCheckedOut.v1: the cart as priced at
+// checkout. Line amounts come from the same pricing run as the totals, so the
+// lines always add up to them.
+func Snapshot(cart *domain.Cart, priced *pricing.Priced, now time.Time) map[string]any {
+	sum := priced.Summary
+
+	lines := make([]map[string]any, 0, len(priced.Items))
+	for i, it := range priced.Items {
+		lp := sum.Lines[i]
+		lines = append(lines, map[string]any{
+			"cart_item_id":     bin16String(it.CartItemID),
+			"sku":              it.SKU,
+			"variant_id":       it.VariantID,
+			"qty":              it.Qty,
+			"product_name":     it.ProductName,
+			"image_url":        it.ImageURL,
+			"product_meta":     jsonOrNil(it.ProductMeta),
+			"currency":         it.Currency,
+			"unit_price_paise": lp.UnitPricePaise,
+			"mrp_paise":        it.MRPPaise,
+			"tax_rate_bps":     it.TaxRateBps,
+			"subtotal_paise":   lp.SubtotalPaise,
+			"discount_paise":   lp.DiscountPaise,
+			"tax_paise":        lp.TaxPaise,
+			"total_paise":      lp.SubtotalPaise - lp.DiscountPaise + lp.TaxPaise,
+
+			"list_currency":         it.ListCurrency,
+			"list_unit_price_paise": it.ListUnitPricePaise,
+			"fx_rate":               it.FXRate,
+			"fx_rate_at":            it.FXRateAt,
+		})
+	}
+
+	promos := make([]map[string]any, 0, len(priced.Promos))
+	for _, p := range priced.Promos {
+		if p.Status != "APPLIED" {
+			continue
+		}
+		promos = append(promos, map[string]any{
+			"cart_promo_id":  bin16String(p.CartPromoID),
+			"promo_code":     p.PromoCode,
+			"promo_type":     p.PromoType,
+			"discount_paise": p.DiscountPaise,
+			"promo_meta":     jsonOrNil(p.PromoMeta),
+		})
+	}
+
+	minorUnits, _ := money.MinorUnits(cart.Currency)
+	return map[string]any{
+		"cart_id":        bin16String(cart.CartID),
+		"cart_version":   cart.Version,
+		"owner_type":     cart.OwnerType,
+		"user_id":        bin16String(cart.UserID),
+		"guest_id":       cart.GuestID,
+		"channel":        cart.Channel,
+		"currency":       cart.Currency,
+		"locale":         cart.Locale,
+		"ship_pincode":   cart.ShipPincode,
+		"checked_out_at": now,
+		"items":          lines,
+		"promotions":     promos,
+		"shipping":       priced.Shipping,
+		"totals": map[string]any{
+			"minor_units":        minorUnits,
+			"subtotal_paise":     sum.SubtotalPaise,
+			"discount_paise":     sum.DiscountPaise,
+			"tax_paise":          sum.TaxPaise,
+			"shipping_paise":     sum.ShippingPaise,
+			"stored_value_paise": sum.StoredValuePaise,
+			"grand_total_paise":  sum.GrandTotalPaise,
+			"savings_paise":      sum.SavingsPaise,
+			"pricing_version":    domain.PricingVersion,
+		},
+	}
+}
+
+func jsonOrNil(s string) any {
+	if s == "" {
+		return nil
+	}
+	return json.RawMessage(s)
+}
+
+// bin16String renders a BINARY(16) column as a UUID string, or "" when unset.
+func bin16String(b []byte) string {
+	if len(b) != 16 {
+		return ""
+	}
+	u, err := domain.Bin16ToUUID(b)
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
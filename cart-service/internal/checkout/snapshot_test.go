@@ -0,0 +1,64 @@
+package checkout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/events"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/pricing"
+)
+
+func TestSnapshot(t *testing.T) {
+	price := func(v int64) *int64 { return &v }
+	rate := 1800
+	items := []domain.CartItem{
+		{CartItemID: make([]byte, 16), SKU: "A", Qty: 2, Currency: "INR", UnitPricePaise: price(10000), TaxRateBps: &rate},
+		{CartItemID: make([]byte, 16), SKU: "B", Qty: 1, Currency: "INR", UnitPricePaise: price(5001), TaxRateBps: &rate},
+	}
+	promos := []domain.CartPromotion{
+		{CartPromoID: make([]byte, 16), PromoCode: "SAVE10", PromoType: domain.PromoTypeCoupon, DiscountPaise: 2500, Status: "APPLIED", PromoMeta: `{"kind":"FLAT_OFF"}`},
+		{PromoCode: "OLD", PromoType: domain.PromoTypeCoupon, Status: "SUSPENDED"},
+	}
+	priced := &pricing.Priced{
+		Items:   items,
+		Promos:  promos,
+		Summary: domain.ComputePricing(items, promos, 4000),
+	}
+	cart := &domain.Cart{CartID: make([]byte, 16), OwnerType: "GUEST", GuestID: "g-1", Version: 7, Currency: "INR"}
+
+	snap := Snapshot(cart, priced, time.Now())
+
+	lines := snap["items"].([]map[string]any)
+	if len(lines) != 2 {
+		t.Fatalf("items = %d", len(lines))
+	}
+	var sub, disc, tax, total int64
+	for _, l := range lines {
+		sub += l["subtotal_paise"].(int64)
+		disc += l["discount_paise"].(int64)
+		tax += l["tax_paise"].(int64)
+		total += l["total_paise"].(int64)
+	}
+	totals := snap["totals"].(map[string]any)
+	if sub != totals["subtotal_paise"] || disc != totals["discount_paise"] || tax != totals["tax_paise"] {
+		t.Errorf("lines %d/%d/%d do not add up to totals %v", sub, disc, tax, totals)
+	}
+	if want := totals["grand_total_paise"].(int64) - totals["shipping_paise"].(int64); total != want {
+		t.Errorf("line totals = %d, want %d", total, want)
+	}
+	if p := snap["promotions"].([]map[string]any); len(p) != 1 || p[0]["promo_code"] != "SAVE10" {
+		t.Errorf("promotions = %v, want only the applied coupon", p)
+	}
+	if snap["cart_version"] != 7 {
+		t.Errorf("cart_version = %v", snap["cart_version"])
+	}
+
+	// What checkOut emits matches the published schema.
+	snap["client_id"] = "web"
+	snap["saga_id"] = bin16String(make([]byte, 16))
+	snap["reservations"] = []Line{{Key: "s:0", SKU: "A", Qty: 2, Status: LineReserved}}
+	if _, err := events.Marshal(EventCartCheckedOut, snap); err != nil {
+		t.Error(err)
+	}
+}
@@ -4,12 +4,102 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
 	HTTPPort int
 	MySQL    MySQL
 	Kafka    Kafka
+
+	PromoRulesFile    string
+	ShippingRulesFile string
+
+	Outbox      Outbox
+	Idempotency Idempotency
+	Sweeper     Sweeper
+	Carts       Carts
+	Checkout    Checkout
+	Catalog     Catalog
+	FX          FX
+	Migrate     Migrate
+	// MetricsAddr is where the worker serves /debug/vars; empty disables it.
+	MetricsAddr string
+}
+
+// Carts controls cart expiry: idle TTLs per owner type (slid forward on each
+// mutation) and the worker job that closes expired carts.
+type Carts struct {
+	// DefaultCurrency is used when a cart is created without one.
+	DefaultCurrency string
+	GuestTTL        time.Duration
+	UserTTL         time.Duration
+	ExpiryInterval  time.Duration
+	ExpiryBatchSize int
+}
+
+// Checkout controls the checkout saga: where inventory-service is, how long
+// reservations are held, and how the worker resumes interrupted checkouts.
+type Checkout struct {
+	// InventoryURL is inventory-service's base URL; empty skips reservations.
+	InventoryURL     string
+	InventoryTimeout time.Duration
+	ReservationTTL   time.Duration
+	Lease            time.Duration
+	ResumeInterval   time.Duration
+	ResumeBatchSize  int
+}
+
+// Catalog controls where cart prices come from: catalog-service when URL is
+// set, otherwise the local product file. Lookups are cached for CacheTTL.
+type Catalog struct {
+	URL      string
+	Timeout  time.Duration
+	File     string
+	CacheTTL time.Duration
+}
+
+// FX controls conversion of catalog prices into the cart's currency.
+// MaxRateAge refuses rates published longer ago than that; zero accepts any.
+type FX struct {
+	MaxRateAge time.Duration
+}
+
+// Migrate controls cmd/migrate. LockTimeout is how long a runner waits for
+// another one (say, a second pod's init container) to finish.
+type Migrate struct {
+	LockTimeout time.Duration
+}
+
+// Outbox controls how the worker claims, publishes and retries outbox rows.
+type Outbox struct {
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+
+	Workers      int
+	BatchSize    int
+	PollInterval time.Duration
+	Lease        time.Duration
+
+	// CloudEvents is "binary" or "structured" to publish CloudEvents 1.0;
+	// empty keeps the EventEnvelope JSON.
+	CloudEvents string
+}
+
+// Idempotency controls how long the server replays responses to retried
+// Idempotency-Keys. Keys are kept that long; the sweeper then deletes them.
+type Idempotency struct {
+	TTL time.Duration
+}
+
+// Sweeper controls the worker jobs that prune cart_idempotency and cart_outbox.
+type Sweeper struct {
+	Interval        time.Duration
+	BatchSize       int
+	MaxBatches      int
+	OutboxRetention time.Duration
+	ArchiveOutbox   bool
 }
 
 type MySQL struct {
@@ -24,6 +114,11 @@ type Kafka struct {
 	Brokers []string
 	Topic   string
 	GroupID string
+
+	// CatalogTopics carry catalog price and inventory stock events applied
+	// to open carts by the worker; CatalogConsumer disables that consumer.
+	CatalogTopics   []string
+	CatalogConsumer bool
 }
 
 func Load() Config {
@@ -40,7 +135,62 @@ func Load() Config {
 			Brokers: strings.Split(getenv("KAFKA_BROKERS", "localhost:9092"), ","),
 			Topic:   getenv("KAFKA_TOPIC", "cart.events"),
 			GroupID: getenv("KAFKA_GROUP_ID", "cart-service"),
+
+			CatalogTopics:   strings.Split(getenv("CATALOG_TOPICS", "catalog.events,inventory.events"), ","),
+			CatalogConsumer: getenv("CATALOG_CONSUMER_ENABLED", "true") == "true",
+		},
+		PromoRulesFile:    getenv("PROMO_RULES_FILE", "config/promotions.json"),
+		ShippingRulesFile: getenv("SHIPPING_RULES_FILE", "config/shipping.json"),
+		Outbox: Outbox{
+			MaxAttempts: mustInt(getenv("OUTBOX_MAX_ATTEMPTS", "10")),
+			BackoffBase: mustDuration(getenv("OUTBOX_BACKOFF_BASE", "1s")),
+			BackoffMax:  mustDuration(getenv("OUTBOX_BACKOFF_MAX", "5m")),
+
+			Workers:      mustInt(getenv("OUTBOX_WORKERS", "4")),
+			BatchSize:    mustInt(getenv("OUTBOX_BATCH_SIZE", "50")),
+			PollInterval: mustDuration(getenv("OUTBOX_POLL_INTERVAL", "200ms")),
+			Lease:        mustDuration(getenv("OUTBOX_LEASE", "30s")),
+
+			CloudEvents: getenv("OUTBOX_CLOUDEVENTS_MODE", ""),
+		},
+		Carts: Carts{
+			DefaultCurrency: getenv("CART_DEFAULT_CURRENCY", "INR"),
+			GuestTTL:        mustDuration(getenv("CART_TTL_GUEST", "72h")),
+			UserTTL:         mustDuration(getenv("CART_TTL_USER", "720h")),
+			ExpiryInterval:  mustDuration(getenv("CART_EXPIRY_INTERVAL", "1m")),
+			ExpiryBatchSize: mustInt(getenv("CART_EXPIRY_BATCH_SIZE", "100")),
 		},
+		Checkout: Checkout{
+			InventoryURL:     getenv("INVENTORY_URL", ""),
+			InventoryTimeout: mustDuration(getenv("INVENTORY_TIMEOUT", "5s")),
+			ReservationTTL:   mustDuration(getenv("RESERVATION_TTL", "15m")),
+			Lease:            mustDuration(getenv("CHECKOUT_LEASE", "1m")),
+			ResumeInterval:   mustDuration(getenv("CHECKOUT_RESUME_INTERVAL", "30s")),
+			ResumeBatchSize:  mustInt(getenv("CHECKOUT_RESUME_BATCH_SIZE", "50")),
+		},
+		Catalog: Catalog{
+			URL:      getenv("CATALOG_URL", ""),
+			Timeout:  mustDuration(getenv("CATALOG_TIMEOUT", "2s")),
+			File:     getenv("CATALOG_FILE", "config/catalog.json"),
+			CacheTTL: mustDuration(getenv("CATALOG_CACHE_TTL", "30s")),
+		},
+		FX: FX{
+			MaxRateAge: mustDuration(getenv("FX_MAX_RATE_AGE", "36h")),
+		},
+		Migrate: Migrate{
+			LockTimeout: mustDuration(getenv("MIGRATE_LOCK_TIMEOUT", "5m")),
+		},
+		Idempotency: Idempotency{
+			TTL: mustDuration(getenv("IDEMPOTENCY_TTL", "24h")),
+		},
+		Sweeper: Sweeper{
+			Interval:        mustDuration(getenv("SWEEP_INTERVAL", "1m")),
+			BatchSize:       mustInt(getenv("SWEEP_BATCH_SIZE", "500")),
+			MaxBatches:      mustInt(getenv("SWEEP_MAX_BATCHES", "20")),
+			OutboxRetention: mustDuration(getenv("OUTBOX_RETENTION", "168h")),
+			ArchiveOutbox:   getenv("OUTBOX_ARCHIVE", "false") == "true",
+		},
+		MetricsAddr: getenv("WORKER_METRICS_ADDR", ":9915"),
 	}
 }
 
@@ -62,3 +212,8 @@ func mustInt(s string) int {
 	i, _ := strconv.Atoi(s)
 	return i
 }
+
+func mustDuration(s string) time.Duration {
+	d, _ := time.ParseDuration(s)
+	return d
+}
@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CartStatus is the lifecycle state stored in carts.status. Every change of
+// state goes through Transition, so the rules live in one table instead of in
+// each handler.
+type CartStatus string
+
+const (
+	CartActive          CartStatus = "ACTIVE"
+	CartCheckoutPending CartStatus = "CHECKOUT_PENDING" // checkout started, inventory being reserved
+	CartCheckedOut      CartStatus = "CHECKED_OUT"
+	CartAbandoned       CartStatus = "ABANDONED" // USER cart idle past its TTL
+	CartExpired         CartStatus = "EXPIRED"   // GUEST or empty cart idle past its TTL
+	CartMerged          CartStatus = "MERGED"    // guest cart folded into a user cart
+)
+
+// cartTransitions lists the states each state may move to. ACTIVE -> ACTIVE
+// is an in-place edit (items, promotions, destination). States without an
+// entry are terminal.
+var cartTransitions = map[CartStatus][]CartStatus{
+	CartActive: {
+		CartActive,
+		CartCheckoutPending,
+		CartCheckedOut,
+		CartAbandoned,
+		CartExpired,
+		CartMerged,
+	},
+	CartCheckoutPending: {
+		CartCheckedOut,
+		CartActive, // checkout failed and was compensated
+	},
+}
+
+// CartStatuses returns every known cart state.
+func CartStatuses() []CartStatus {
+	return []CartStatus{CartActive, CartCheckoutPending, CartCheckedOut, CartAbandoned, CartExpired, CartMerged}
+}
+
+// Valid reports whether s is a known cart state.
+func (s CartStatus) Valid() bool {
+	for _, k := range CartStatuses() {
+		if s == k {
+			return true
+		}
+	}
+	return false
+}
+
+// Terminal reports whether no transition leaves s.
+func (s CartStatus) Terminal() bool {
+	return s.Valid() && len(cartTransitions[s]) == 0
+}
+
+// CanTransition reports whether a cart in s may move to to.
+func (s CartStatus) CanTransition(to CartStatus) bool {
+	for _, next := range cartTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrInvalidTransition matches every *TransitionError with errors.Is.
+var ErrInvalidTransition = errors.New("invalid cart state transition")
+
+// TransitionError is returned for a transition the table does not allow.
+type TransitionError struct {
+	From CartStatus
+	To   CartStatus
+}
+
+func (e *TransitionError) Error() string {
+	if e.From == e.To {
+		return fmt.Sprintf("cart is %s and cannot be modified", e.From)
+	}
+	return fmt.Sprintf("cart cannot move from %s to %s", e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool { return target == ErrInvalidTransition }
+
+// Edit checks that the cart may be modified in place. Edits are the
+// self-transition, which only ACTIVE allows: a cart in CHECKOUT_PENDING may go
+// back to ACTIVE but cannot be edited while checkout holds it.
+func (c *Cart) Edit() error {
+	return c.Transition(c.Status)
+}
+
+// Transition checks that the cart may move to to and updates cart.Status.
+// The caller persists the new status, normally together with a version bump.
+func (c *Cart) Transition(to CartStatus) error {
+	if !c.Status.CanTransition(to) {
+		return &TransitionError{From: c.Status, To: to}
+	}
+	c.Status = to
+	return nil
+}
@@ -0,0 +1,168 @@
+package domain
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// cartOps are the state changes handlers and jobs ask for, by the status each
+// one moves the cart to.
+var cartOps = []struct {
+	name string
+	to   CartStatus
+}{
+	{"add/update/remove item, promotions, destination", CartActive},
+	{"start checkout", CartCheckoutPending},
+	{"compensate checkout", CartActive},
+	{"checkout", CartCheckedOut},
+	{"abandon", CartAbandoned},
+	{"expire", CartExpired},
+	{"merge", CartMerged},
+}
+
+// opSeq is a random sequence of indexes into cartOps.
+type opSeq []int
+
+func (opSeq) Generate(r *rand.Rand, size int) reflect.Value {
+	s := make(opSeq, r.Intn(size+1))
+	for i := range s {
+		s[i] = r.Intn(len(cartOps))
+	}
+	return reflect.ValueOf(s)
+}
+
+// anyStatus is a random known cart status.
+type anyStatus CartStatus
+
+func (anyStatus) Generate(r *rand.Rand, _ int) reflect.Value {
+	all := CartStatuses()
+	return reflect.ValueOf(anyStatus(all[r.Intn(len(all))]))
+}
+
+func TestCartTransitions(t *testing.T) {
+	tests := []struct {
+		from, to CartStatus
+		want     bool
+	}{
+		{CartActive, CartActive, true},
+		{CartActive, CartCheckoutPending, true},
+		{CartActive, CartCheckedOut, true},
+		{CartActive, CartAbandoned, true},
+		{CartActive, CartExpired, true},
+		{CartActive, CartMerged, true},
+		{CartCheckoutPending, CartCheckedOut, true},
+		{CartCheckoutPending, CartActive, true},
+		{CartCheckoutPending, CartCheckoutPending, false},
+		{CartCheckoutPending, CartMerged, false},
+		{CartCheckoutPending, CartExpired, false},
+		{CartCheckedOut, CartActive, false},
+		{CartCheckedOut, CartCheckedOut, false},
+		{CartAbandoned, CartActive, false},
+		{CartExpired, CartActive, false},
+		{CartMerged, CartActive, false},
+		{"", CartActive, false},
+		{"PAID", CartActive, false},
+	}
+	for _, tc := range tests {
+		if got := tc.from.CanTransition(tc.to); got != tc.want {
+			t.Errorf("%s -> %s: got %v, want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+
+	for _, s := range CartStatuses() {
+		want := s == CartCheckedOut || s == CartAbandoned || s == CartExpired || s == CartMerged
+		if got := s.Terminal(); got != want {
+			t.Errorf("%s.Terminal() = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestCartTransitionError(t *testing.T) {
+	cart := Cart{Status: CartCheckedOut}
+	err := cart.Transition(CartActive)
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("err = %v, want ErrInvalidTransition", err)
+	}
+	var te *TransitionError
+	if !errors.As(err, &te) || te.From != CartCheckedOut || te.To != CartActive {
+		t.Fatalf("err = %#v", err)
+	}
+	if cart.Status != CartCheckedOut {
+		t.Errorf("status changed to %s on a refused transition", cart.Status)
+	}
+
+	if err := cart.Transition(CartCheckedOut); err == nil || err.Error() != "cart is CHECKED_OUT and cannot be modified" {
+		t.Errorf("self transition err = %v", err)
+	}
+}
+
+func TestCartEdit(t *testing.T) {
+	for _, s := range CartStatuses() {
+		cart := Cart{Status: s}
+		err := cart.Edit()
+		if (err == nil) != (s == CartActive) {
+			t.Errorf("Edit() from %s: err = %v", s, err)
+		}
+		if cart.Status != s {
+			t.Errorf("Edit() from %s changed status to %s", s, cart.Status)
+		}
+	}
+}
+
+// No sequence of operations moves a cart out of a terminal state, and every
+// attempt is refused with ErrInvalidTransition.
+func TestTerminalStatesAreFinal(t *testing.T) {
+	prop := func(start anyStatus, ops opSeq) bool {
+		from := CartStatus(start)
+		if !from.Terminal() {
+			return true
+		}
+		cart := Cart{Status: from}
+		for _, i := range ops {
+			if err := cart.Transition(cartOps[i].to); !errors.Is(err, ErrInvalidTransition) {
+				t.Logf("%s from %s: err = %v", cartOps[i].name, from, err)
+				return false
+			}
+			if cart.Status != from {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(prop, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+// From ACTIVE, a cart only ever holds known states, and once an operation
+// lands it in a terminal state nothing that follows changes it.
+func TestTransitionSequences(t *testing.T) {
+	prop := func(ops opSeq) bool {
+		cart := Cart{Status: CartActive}
+		var terminal CartStatus
+		for _, i := range ops {
+			before := cart.Status
+			err := cart.Transition(cartOps[i].to)
+			switch {
+			case !cart.Status.Valid():
+				return false
+			case err != nil && cart.Status != before:
+				return false
+			case err == nil && !before.CanTransition(cart.Status):
+				return false
+			case terminal != "" && cart.Status != terminal:
+				return false
+			}
+			if cart.Status.Terminal() {
+				terminal = cart.Status
+			}
+		}
+		return true
+	}
+	if err := quick.Check(prop, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
@@ -5,15 +5,17 @@ import "time"
 // BINARY(16) columns map well to []byte in Go.
 
 type Cart struct {
-	CartID    []byte `gorm:"column:cart_id;type:binary(16);primaryKey"`
-	OwnerType string `gorm:"column:owner_type;not null"`
-	UserID    []byte `gorm:"column:user_id;type:binary(16)"`
-	GuestID   string `gorm:"column:guest_id"`
-	Channel   string `gorm:"column:channel;not null"`
-	Status    string `gorm:"column:status;not null"`
-	Currency  string `gorm:"column:currency;not null"`
-	Locale    string `gorm:"column:locale"`
-	Version   int    `gorm:"column:version;not null"`
+	CartID    []byte     `gorm:"column:cart_id;type:binary(16);primaryKey"`
+	OwnerType string     `gorm:"column:owner_type;not null"`
+	UserID    []byte     `gorm:"column:user_id;type:binary(16)"`
+	GuestID   string     `gorm:"column:guest_id"`
+	Channel   string     `gorm:"column:channel;not null"`
+	Status    CartStatus `gorm:"column:status;not null"`
+	Currency  string     `gorm:"column:currency;not null"`
+	Locale    string     `gorm:"column:locale"`
+	Version   int        `gorm:"column:version;not null"`
+
+	ShipPincode string `gorm:"column:ship_pincode"`
 
 	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime"`
 	UpdatedAt time.Time  `gorm:"column:updated_at;autoUpdateTime"`
@@ -33,12 +35,21 @@ type CartItem struct {
 	ProductName string `gorm:"column:product_name"`
 	ImageURL    string `gorm:"column:image_url"`
 
-	Currency       string `gorm:"column:currency;not null"`
+	Currency       string `gorm:"column:currency;not null"` // always the cart's currency
 	UnitPricePaise *int64 `gorm:"column:unit_price_paise"`
 	MRPPaise       *int64 `gorm:"column:mrp_paise"`
 	TaxRateBps     *int   `gorm:"column:tax_rate_bps"`
 	ProductMeta    string `gorm:"column:product_meta;type:json"`
 
+	// What the catalog charges, in its own currency. When ListCurrency is not
+	// Currency, the prices above were converted at FXRate (one ListCurrency
+	// buys FXRate Currency) published at FXRateAt.
+	ListCurrency       string     `gorm:"column:list_currency"`
+	ListUnitPricePaise *int64     `gorm:"column:list_unit_price_paise"`
+	ListMRPPaise       *int64     `gorm:"column:list_mrp_paise"`
+	FXRate             *string    `gorm:"column:fx_rate;type:decimal(24,12)"`
+	FXRateAt           *time.Time `gorm:"column:fx_rate_at"`
+
 	Availability string    `gorm:"column:availability;not null"`
 	AddedAt      time.Time `gorm:"column:added_at;autoCreateTime"`
 	UpdatedAt    time.Time `gorm:"column:updated_at;autoUpdateTime"`
@@ -46,6 +57,13 @@ type CartItem struct {
 
 func (CartItem) TableName() string { return "cart_items" }
 
+// Values of cart_items.availability, kept current by inventory events.
+const (
+	AvailabilityInStock    = "IN_STOCK"
+	AvailabilityLowStock   = "LOW_STOCK"
+	AvailabilityOutOfStock = "OUT_OF_STOCK"
+)
+
 type CartPromotion struct {
 	CartPromoID   []byte    `gorm:"column:cart_promo_id;type:binary(16);primaryKey"`
 	CartID        []byte    `gorm:"column:cart_id;type:binary(16);index;not null"`
@@ -59,6 +77,19 @@ type CartPromotion struct {
 
 func (CartPromotion) TableName() string { return "cart_promotions" }
 
+// FXRate is one published exchange rate: one BaseCurrency buys Rate
+// QuoteCurrency from EffectiveAt until the next row for the pair.
+type FXRate struct {
+	BaseCurrency  string    `gorm:"column:base_currency;type:char(3);primaryKey"`
+	QuoteCurrency string    `gorm:"column:quote_currency;type:char(3);primaryKey"`
+	EffectiveAt   time.Time `gorm:"column:effective_at;primaryKey"`
+	Rate          string    `gorm:"column:rate;type:decimal(24,12);not null"`
+	Source        string    `gorm:"column:source"`
+	CreatedAt     time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (FXRate) TableName() string { return "fx_rates" }
+
 type CartTotals struct {
 	CartID          []byte    `gorm:"column:cart_id;type:binary(16);primaryKey"`
 	SubtotalPaise   int64     `gorm:"column:subtotal_paise;not null"`
@@ -68,6 +99,10 @@ type CartTotals struct {
 	GrandTotalPaise int64     `gorm:"column:grand_total_paise;not null"`
 	PricingVersion  int       `gorm:"column:pricing_version;not null"`
 	ComputedAt      time.Time `gorm:"column:computed_at;autoCreateTime"`
+
+	StoredValuePaise int64  `gorm:"column:stored_value_paise;not null;default:0"`
+	ShippingRule     string `gorm:"column:shipping_rule"`
+	ShippingMeta     string `gorm:"column:shipping_meta;type:json"`
 }
 
 func (CartTotals) TableName() string { return "cart_totals" }
@@ -81,7 +116,9 @@ type CartIdempotency struct {
 	ResourceID   []byte `gorm:"column:resource_id;type:binary(16)"`
 	HTTPStatus   *int16 `gorm:"column:http_status"`
 	ResponseBody string `gorm:"column:response_body;type:json"`
-	State        string `gorm:"column:state;not null"`
+	// ResponseHeaders holds the replayed headers (ETag, Location) as a JSON object.
+	ResponseHeaders string `gorm:"column:response_headers;type:json"`
+	State           string `gorm:"column:state;not null"`
 
 	LockedAt  *time.Time `gorm:"column:locked_at"`
 	ExpiresAt time.Time  `gorm:"column:expires_at;not null"`
@@ -93,10 +130,24 @@ func (CartIdempotency) TableName() string { return "cart_idempotency" }
 type CartOutbox struct {
 	OutboxID      []byte `gorm:"column:outbox_id;type:binary(16);primaryKey"`
 	AggregateType string `gorm:"column:aggregate_type;not null"`
-	AggregateID   []byte `gorm:"column:aggregate_id;type:binary(16);index;not null"`
+	AggregateID   []byte `gorm:"column:aggregate_id;type:binary(16);index;index:idx_outbox_aggregate_seq,priority:1;not null"`
 	EventType     string `gorm:"column:event_type;not null"`
 	Payload       string `gorm:"column:payload;type:json;not null"`
-	Status        string `gorm:"column:status;not null"`
+	Status        string `gorm:"column:status;not null"` // NEW, FAILED (retrying), PUBLISHED, DEAD
+
+	// Seq orders rows of one aggregate; only the lowest pending Seq of an
+	// aggregate may be published.
+	Seq uint64 `gorm:"column:seq;autoIncrement;uniqueIndex;index:idx_outbox_aggregate_seq,priority:2;<-:false"`
+	// LeaseOwner/LeaseUntil mark a row claimed by a publisher; an expired
+	// lease means the worker died and the row can be claimed again.
+	LeaseOwner string     `gorm:"column:lease_owner"`
+	LeaseUntil *time.Time `gorm:"column:lease_until"`
+
+	// Attempts counts failed publishes; the row goes DEAD after the
+	// publisher's max attempts. NextAttemptAt is when a FAILED row is retried.
+	Attempts      int        `gorm:"column:attempts;not null;default:0"`
+	LastError     string     `gorm:"column:last_error;type:text"`
+	NextAttemptAt *time.Time `gorm:"column:next_attempt_at;index"`
 
 	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime"`
 	PublishedAt *time.Time `gorm:"column:published_at"`
@@ -104,9 +155,53 @@ type CartOutbox struct {
 
 func (CartOutbox) TableName() string { return "cart_outbox" }
 
+// CartOutboxArchive keeps published outbox rows swept out of cart_outbox.
+type CartOutboxArchive struct {
+	OutboxID      []byte `gorm:"column:outbox_id;type:binary(16);primaryKey"`
+	AggregateType string `gorm:"column:aggregate_type;not null"`
+	AggregateID   []byte `gorm:"column:aggregate_id;type:binary(16);index;not null"`
+	EventType     string `gorm:"column:event_type;not null"`
+	Payload       string `gorm:"column:payload;type:json;not null"`
+	Status        string `gorm:"column:status;not null"`
+
+	CreatedAt   time.Time  `gorm:"column:created_at"`
+	PublishedAt *time.Time `gorm:"column:published_at"`
+	ArchivedAt  time.Time  `gorm:"column:archived_at;not null"`
+}
+
+func (CartOutboxArchive) TableName() string { return "cart_outbox_archive" }
+
+// CartHistory is one entry of a cart's audit trail: an action, who took it,
+// and what it changed. Rows are appended and never updated or swept.
+type CartHistory struct {
+	EntryID []byte `gorm:"column:entry_id;type:binary(16);primaryKey"`
+	CartID  []byte `gorm:"column:cart_id;type:binary(16);index:idx_history_cart_seq,priority:1;not null"`
+	// Seq orders entries; it is the pagination cursor.
+	Seq         uint64 `gorm:"column:seq;autoIncrement;uniqueIndex;index:idx_history_cart_seq,priority:2;<-:false"`
+	CartVersion int    `gorm:"column:cart_version;not null"`
+	Action      string `gorm:"column:action;not null"`
+
+	// Actor is the X-Client-Id of the request, or the worker job.
+	Actor          string `gorm:"column:actor;not null"`
+	IdempotencyKey string `gorm:"column:idempotency_key"`
+	// RelatedCartID is the other cart of a merge.
+	RelatedCartID []byte `gorm:"column:related_cart_id;type:binary(16)"`
+
+	StatusBefore          string `gorm:"column:status_before"`
+	StatusAfter           string `gorm:"column:status_after;not null"`
+	GrandTotalBeforePaise int64  `gorm:"column:grand_total_before_paise;not null"`
+	GrandTotalAfterPaise  int64  `gorm:"column:grand_total_after_paise;not null"`
+	// Changes lists the lines, promotions and destination that changed, as JSON.
+	Changes string `gorm:"column:changes;type:json;not null"`
+
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (CartHistory) TableName() string { return "cart_history" }
+
 type ProcessedEvent struct {
 	EventID       []byte    `gorm:"column:event_id;type:binary(16);primaryKey"`
-	Consumer      string    `gorm:"column:consumer;not null"`
+	Consumer      string    `gorm:"column:consumer;primaryKey"`
 	EventType     string    `gorm:"column:event_type;not null"`
 	CorrelationID []byte    `gorm:"column:correlation_id;type:binary(16)"`
 	ProcessedAt   time.Time `gorm:"column:processed_at;autoCreateTime"`
@@ -114,6 +209,83 @@ type ProcessedEvent struct {
 
 func (ProcessedEvent) TableName() string { return "processed_events" }
 
+// Stored value (gift cards and wallets). Balances live on the account row;
+// every movement is appended to stored_value_ledger and never updated.
+
+type StoredValueAccount struct {
+	AccountID   []byte `gorm:"column:account_id;type:binary(16);primaryKey"`
+	Kind        string `gorm:"column:kind;not null"` // GIFT_CARD/WALLET
+	Code        string `gorm:"column:code"`          // gift card code
+	OwnerUserID []byte `gorm:"column:owner_user_id;type:binary(16)"`
+	Currency    string `gorm:"column:currency;not null"`
+	Status      string `gorm:"column:status;not null"` // ACTIVE/BLOCKED
+
+	// BalancePaise includes HeldPaise; available = balance - held.
+	BalancePaise int64 `gorm:"column:balance_paise;not null"`
+	HeldPaise    int64 `gorm:"column:held_paise;not null"`
+
+	ExpiresAt *time.Time `gorm:"column:expires_at"`
+	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt time.Time  `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (StoredValueAccount) TableName() string { return "stored_value_accounts" }
+
+type StoredValueHold struct {
+	HoldID      []byte `gorm:"column:hold_id;type:binary(16);primaryKey"`
+	AccountID   []byte `gorm:"column:account_id;type:binary(16);index;not null"`
+	CartID      []byte `gorm:"column:cart_id;type:binary(16);index;not null"`
+	CartPromoID []byte `gorm:"column:cart_promo_id;type:binary(16);index;not null"`
+	AmountPaise int64  `gorm:"column:amount_paise;not null"`
+	Status      string `gorm:"column:status;not null"` // HELD/CAPTURED/RELEASED
+
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (StoredValueHold) TableName() string { return "stored_value_holds" }
+
+type StoredValueLedgerEntry struct {
+	EntryID           []byte    `gorm:"column:entry_id;type:binary(16);primaryKey"`
+	AccountID         []byte    `gorm:"column:account_id;type:binary(16);index;not null"`
+	HoldID            []byte    `gorm:"column:hold_id;type:binary(16)"`
+	CartID            []byte    `gorm:"column:cart_id;type:binary(16)"`
+	EntryType         string    `gorm:"column:entry_type;not null"` // HOLD/CAPTURE/RELEASE
+	AmountPaise       int64     `gorm:"column:amount_paise;not null"`
+	BalanceAfterPaise int64     `gorm:"column:balance_after_paise;not null"`
+	HeldAfterPaise    int64     `gorm:"column:held_after_paise;not null"`
+	Reason            string    `gorm:"column:reason"`
+	CreatedAt         time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (StoredValueLedgerEntry) TableName() string { return "stored_value_ledger" }
+
+// CheckoutSaga records one checkout attempt: the inventory reservations it
+// made and how it ended, so a checkout interrupted by a crash can be resumed
+// and its progress observed.
+type CheckoutSaga struct {
+	SagaID []byte `gorm:"column:saga_id;type:binary(16);primaryKey"`
+	CartID []byte `gorm:"column:cart_id;type:binary(16);index:idx_saga_cart_created,priority:1;not null"`
+	Status string `gorm:"column:status;index:idx_saga_status_lease,priority:1;not null"` // RESERVING/COMPENSATING/COMPLETED/FAILED
+
+	// Lines are the reservations, one per cart line, as JSON.
+	Lines         string `gorm:"column:lines;type:json;not null"`
+	FailureReason string `gorm:"column:failure_reason"`
+	Error         string `gorm:"column:error;type:text"`
+
+	// Request context carried into the events the saga emits.
+	ClientID       string `gorm:"column:client_id"`
+	IdempotencyKey string `gorm:"column:idempotency_key"`
+	TraceParent    string `gorm:"column:traceparent"`
+
+	LeaseUntil  time.Time  `gorm:"column:lease_until;index:idx_saga_status_lease,priority:2;not null"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime;index:idx_saga_cart_created,priority:2"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at;autoUpdateTime"`
+	CompletedAt *time.Time `gorm:"column:completed_at"`
+}
+
+func (CheckoutSaga) TableName() string { return "checkout_sagas" }
+
 type EventEnvelope struct {
 	EventID        string    `json:"event_id"`
 	EventType      string    `json:"event_type"`
@@ -121,6 +293,7 @@ type EventEnvelope struct {
 	OccurredAt     time.Time `json:"occurred_at"`
 	CorrelationID  string    `json:"correlation_id"` // cart_id or order_id
 	TraceID        string    `json:"trace_id,omitempty"`
+	TraceParent    string    `json:"traceparent,omitempty"` // W3C trace context of the originating request
 	IdempotencyKey string    `json:"idempotency_key,omitempty"`
 	Data           any       `json:"data"`
 }
@@ -1,8 +1,34 @@
 package domain
 
+import "math/bits"
+
 // Keep pricing logic centralized.
-// For MVP: recompute totals from items + applied promotions.
-// Later: integrate pricing engine / tax rules / shipping rules.
+// ComputePricing is the single source of truth for cart totals: handlers and
+// workers load items + applied promotions and persist what it returns.
+
+// PricingVersion identifies the rule set implemented by ComputePricing and is
+// stored in cart_totals.pricing_version. Bump it whenever the rules change so
+// stored totals can be traced back to the rules that produced them.
+//
+//	1: plain sums, tax truncated on the undiscounted line value.
+//	2: discount allocated pro rata to lines, tax rounded half-up per line
+//	   on the discounted line value.
+//	3: gift card / wallet amounts are tenders applied after tax and
+//	   shipping instead of discounts.
+const PricingVersion = 3
+
+// Promotion types. Coupons are discounts; gift cards and wallets are stored
+// value tenders that pay part of the grand total.
+const (
+	PromoTypeCoupon   = "COUPON"
+	PromoTypeGiftCard = "GIFT_CARD"
+	PromoTypeWallet   = "WALLET"
+)
+
+// IsStoredValue reports whether a promotion type is backed by a balance.
+func IsStoredValue(promoType string) bool {
+	return promoType == PromoTypeGiftCard || promoType == PromoTypeWallet
+}
 
 type PricingSummary struct {
 	SubtotalPaise   int64
@@ -10,4 +36,146 @@ type PricingSummary struct {
 	ShippingPaise   int64
 	DiscountPaise   int64
 	GrandTotalPaise int64
+
+	// StoredValuePaise is the part of the total paid by gift cards / wallets.
+	// GrandTotalPaise is what is left to pay after it.
+	StoredValuePaise int64
+
+	// SavingsPaise is the informational MRP saving (MRP - unit price) across
+	// lines. It does not take part in the grand total.
+	SavingsPaise int64
+
+	Lines []LinePricing
+}
+
+// LinePricing is the per-line breakdown behind a PricingSummary, kept so the
+// totals can be audited line by line.
+type LinePricing struct {
+	SKU            string
+	VariantID      string
+	Qty            int
+	UnitPricePaise int64
+	SubtotalPaise  int64
+	DiscountPaise  int64
+	TaxPaise       int64
+}
+
+// ComputePricing prices a cart. All amounts are integer paise and the
+// function is pure: the same input always yields the same summary.
+//
+// Rules (PricingVersion 3):
+//  1. Line subtotal = unit price * qty. Lines without a unit price count as 0.
+//  2. Cart discount = sum of APPLIED coupon promotions, capped at the subtotal.
+//  3. The discount is allocated to lines in proportion to their subtotal;
+//     leftover paise go to the lines with the largest remainders, earlier
+//     lines first on ties.
+//  4. Line tax = (line subtotal - line discount) * tax_rate_bps / 10000,
+//     rounded half-up.
+//  5. Shipping < 0 is treated as 0.
+//  6. Stored value = sum of APPLIED gift card / wallet promotions, capped at
+//     subtotal - discount + tax + shipping.
+//  7. Grand total = subtotal - discount + tax + shipping - stored value.
+func ComputePricing(items []CartItem, promos []CartPromotion, shippingPaise int64) PricingSummary {
+	var sum PricingSummary
+	sum.Lines = make([]LinePricing, len(items))
+
+	for i, it := range items {
+		line := LinePricing{SKU: it.SKU, VariantID: it.VariantID, Qty: it.Qty}
+		if it.UnitPricePaise != nil && it.Qty > 0 {
+			line.UnitPricePaise = *it.UnitPricePaise
+			line.SubtotalPaise = *it.UnitPricePaise * int64(it.Qty)
+			if it.MRPPaise != nil && *it.MRPPaise > *it.UnitPricePaise {
+				sum.SavingsPaise += (*it.MRPPaise - *it.UnitPricePaise) * int64(it.Qty)
+			}
+		}
+		sum.SubtotalPaise += line.SubtotalPaise
+		sum.Lines[i] = line
+	}
+
+	var storedValue int64
+	for _, p := range promos {
+		if p.Status != "APPLIED" || p.DiscountPaise <= 0 {
+			continue
+		}
+		if IsStoredValue(p.PromoType) {
+			storedValue += p.DiscountPaise
+		} else {
+			sum.DiscountPaise += p.DiscountPaise
+		}
+	}
+	if sum.DiscountPaise > sum.SubtotalPaise {
+		sum.DiscountPaise = sum.SubtotalPaise
+	}
+	allocateDiscount(sum.Lines, sum.DiscountPaise, sum.SubtotalPaise)
+
+	for i, it := range items {
+		if it.TaxRateBps == nil || *it.TaxRateBps <= 0 {
+			continue
+		}
+		taxable := sum.Lines[i].SubtotalPaise - sum.Lines[i].DiscountPaise
+		sum.Lines[i].TaxPaise = roundHalfUp(taxable, int64(*it.TaxRateBps), 10000)
+		sum.TaxPaise += sum.Lines[i].TaxPaise
+	}
+
+	if shippingPaise > 0 {
+		sum.ShippingPaise = shippingPaise
+	}
+	payable := sum.SubtotalPaise - sum.DiscountPaise + sum.TaxPaise + sum.ShippingPaise
+	sum.StoredValuePaise = min(storedValue, payable)
+	sum.GrandTotalPaise = payable - sum.StoredValuePaise
+	return sum
+}
+
+// allocateDiscount spreads discount over lines pro rata by subtotal using the
+// largest remainder method, so the line discounts add up exactly.
+func allocateDiscount(lines []LinePricing, discount, subtotal int64) {
+	if discount <= 0 || subtotal <= 0 {
+		return
+	}
+	rems := make([]uint64, len(lines))
+	allocated := int64(0)
+	for i := range lines {
+		if lines[i].SubtotalPaise <= 0 {
+			continue
+		}
+		q, r := mulDiv(uint64(discount), uint64(lines[i].SubtotalPaise), uint64(subtotal))
+		lines[i].DiscountPaise = int64(q)
+		rems[i] = r
+		allocated += int64(q)
+	}
+	for left := discount - allocated; left > 0; left-- {
+		best := -1
+		for i := range lines {
+			if lines[i].DiscountPaise >= lines[i].SubtotalPaise {
+				continue
+			}
+			if best < 0 || rems[i] > rems[best] {
+				best = i
+			}
+		}
+		if best < 0 {
+			return
+		}
+		lines[best].DiscountPaise++
+		rems[best] = 0
+	}
+}
+
+// roundHalfUp returns a*b/c rounded half-up for non-negative a, b and positive c.
+func roundHalfUp(a, b, c int64) int64 {
+	if a <= 0 || b <= 0 {
+		return 0
+	}
+	q, r := mulDiv(uint64(a), uint64(b), uint64(c))
+	if 2*r >= uint64(c) {
+		q++
+	}
+	return int64(q)
+}
+
+// mulDiv computes a*b/c with a 128-bit intermediate so large paise amounts
+// cannot overflow. The quotient must fit in 64 bits.
+func mulDiv(a, b, c uint64) (q, r uint64) {
+	hi, lo := bits.Mul64(a, b)
+	return bits.Div64(hi, lo, c)
 }
@@ -0,0 +1,178 @@
+package domain
+
+import "testing"
+
+func i64(v int64) *int64 { return &v }
+func bps(v int) *int     { return &v }
+
+func TestComputePricing(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    []CartItem
+		promos   []CartPromotion
+		shipping int64
+		want     PricingSummary
+	}{
+		{
+			name: "empty cart",
+			want: PricingSummary{},
+		},
+		{
+			name: "single line with tax",
+			items: []CartItem{
+				{SKU: "A", Qty: 2, UnitPricePaise: i64(10000), TaxRateBps: bps(1800)},
+			},
+			want: PricingSummary{SubtotalPaise: 20000, TaxPaise: 3600, GrandTotalPaise: 23600},
+		},
+		{
+			name: "tax rounds half up",
+			items: []CartItem{
+				// 1 * 25 * 5% = 1.25 -> 1 ; 1 * 30 * 5% = 1.5 -> 2
+				{SKU: "A", Qty: 1, UnitPricePaise: i64(25), TaxRateBps: bps(500)},
+				{SKU: "B", Qty: 1, UnitPricePaise: i64(30), TaxRateBps: bps(500)},
+			},
+			want: PricingSummary{SubtotalPaise: 55, TaxPaise: 3, GrandTotalPaise: 58},
+		},
+		{
+			name: "line without price counts as zero",
+			items: []CartItem{
+				{SKU: "A", Qty: 3},
+				{SKU: "B", Qty: 1, UnitPricePaise: i64(999)},
+			},
+			want: PricingSummary{SubtotalPaise: 999, GrandTotalPaise: 999},
+		},
+		{
+			name: "discount reduces taxable value pro rata",
+			items: []CartItem{
+				{SKU: "A", Qty: 1, UnitPricePaise: i64(30000), TaxRateBps: bps(1800)},
+				{SKU: "B", Qty: 1, UnitPricePaise: i64(10000), TaxRateBps: bps(500)},
+			},
+			promos: []CartPromotion{{Status: "APPLIED", DiscountPaise: 4000}},
+			// A gets 3000 off -> tax 27000*18% = 4860 ; B gets 1000 off -> 9000*5% = 450
+			want: PricingSummary{SubtotalPaise: 40000, DiscountPaise: 4000, TaxPaise: 5310, GrandTotalPaise: 41310},
+		},
+		{
+			name: "leftover paise go to largest remainder",
+			items: []CartItem{
+				{SKU: "A", Qty: 1, UnitPricePaise: i64(100)},
+				{SKU: "B", Qty: 1, UnitPricePaise: i64(100)},
+				{SKU: "C", Qty: 1, UnitPricePaise: i64(100)},
+			},
+			promos: []CartPromotion{{Status: "APPLIED", DiscountPaise: 100}},
+			want:   PricingSummary{SubtotalPaise: 300, DiscountPaise: 100, GrandTotalPaise: 200},
+		},
+		{
+			name: "discount capped at subtotal and non-applied promos ignored",
+			items: []CartItem{
+				{SKU: "A", Qty: 1, UnitPricePaise: i64(5000), TaxRateBps: bps(1200)},
+			},
+			promos: []CartPromotion{
+				{Status: "APPLIED", DiscountPaise: 4000},
+				{Status: "APPLIED", DiscountPaise: 4000},
+				{Status: "REMOVED", DiscountPaise: 100000},
+			},
+			want: PricingSummary{SubtotalPaise: 5000, DiscountPaise: 5000, GrandTotalPaise: 0},
+		},
+		{
+			name: "shipping added",
+			items: []CartItem{
+				{SKU: "A", Qty: 1, UnitPricePaise: i64(1000)},
+			},
+			shipping: 4900,
+			want:     PricingSummary{SubtotalPaise: 1000, ShippingPaise: 4900, GrandTotalPaise: 5900},
+		},
+		{
+			name: "negative shipping treated as zero",
+			items: []CartItem{
+				{SKU: "A", Qty: 1, UnitPricePaise: i64(1000)},
+			},
+			shipping: -10,
+			want:     PricingSummary{SubtotalPaise: 1000, GrandTotalPaise: 1000},
+		},
+		{
+			name: "stored value pays after tax and shipping",
+			items: []CartItem{
+				{SKU: "A", Qty: 1, UnitPricePaise: i64(10000), TaxRateBps: bps(1800)},
+			},
+			promos: []CartPromotion{
+				{Status: "APPLIED", PromoType: PromoTypeCoupon, DiscountPaise: 1000},
+				{Status: "APPLIED", PromoType: PromoTypeGiftCard, DiscountPaise: 5000},
+			},
+			shipping: 500,
+			// 9000 taxable -> 1620 tax; payable 9000+1620+500 = 11120
+			want: PricingSummary{SubtotalPaise: 10000, DiscountPaise: 1000, TaxPaise: 1620, ShippingPaise: 500,
+				StoredValuePaise: 5000, GrandTotalPaise: 6120},
+		},
+		{
+			name: "stored value capped at payable",
+			items: []CartItem{
+				{SKU: "A", Qty: 1, UnitPricePaise: i64(1000)},
+			},
+			promos: []CartPromotion{
+				{Status: "APPLIED", PromoType: PromoTypeWallet, DiscountPaise: 800},
+				{Status: "APPLIED", PromoType: PromoTypeGiftCard, DiscountPaise: 800},
+			},
+			want: PricingSummary{SubtotalPaise: 1000, StoredValuePaise: 1000, GrandTotalPaise: 0},
+		},
+		{
+			name: "mrp savings are informational",
+			items: []CartItem{
+				{SKU: "A", Qty: 2, UnitPricePaise: i64(800), MRPPaise: i64(1000)},
+				{SKU: "B", Qty: 1, UnitPricePaise: i64(800), MRPPaise: i64(500)},
+			},
+			want: PricingSummary{SubtotalPaise: 2400, GrandTotalPaise: 2400, SavingsPaise: 400},
+		},
+		{
+			name: "large amounts do not overflow",
+			items: []CartItem{
+				{SKU: "A", Qty: 999, UnitPricePaise: i64(9_000_000_000), TaxRateBps: bps(2800)},
+			},
+			promos: []CartPromotion{{Status: "APPLIED", DiscountPaise: 1_000_000_000}},
+			want: PricingSummary{
+				SubtotalPaise:   8_991_000_000_000,
+				DiscountPaise:   1_000_000_000,
+				TaxPaise:        2_517_200_000_000,
+				GrandTotalPaise: 11_507_200_000_000,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ComputePricing(tc.items, tc.promos, tc.shipping)
+			if got.SubtotalPaise != tc.want.SubtotalPaise ||
+				got.TaxPaise != tc.want.TaxPaise ||
+				got.ShippingPaise != tc.want.ShippingPaise ||
+				got.DiscountPaise != tc.want.DiscountPaise ||
+				got.GrandTotalPaise != tc.want.GrandTotalPaise ||
+				got.StoredValuePaise != tc.want.StoredValuePaise ||
+				got.SavingsPaise != tc.want.SavingsPaise {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+
+			var lineDiscount, lineTax int64
+			for _, l := range got.Lines {
+				lineDiscount += l.DiscountPaise
+				lineTax += l.TaxPaise
+			}
+			if lineDiscount != got.DiscountPaise {
+				t.Fatalf("line discounts sum to %d, want %d", lineDiscount, got.DiscountPaise)
+			}
+			if lineTax != got.TaxPaise {
+				t.Fatalf("line taxes sum to %d, want %d", lineTax, got.TaxPaise)
+			}
+		})
+	}
+}
+
+func TestAllocateDiscountRemainderOrder(t *testing.T) {
+	lines := []LinePricing{{SubtotalPaise: 100}, {SubtotalPaise: 100}, {SubtotalPaise: 100}}
+	allocateDiscount(lines, 100, 300)
+
+	want := []int64{34, 33, 33}
+	for i, l := range lines {
+		if l.DiscountPaise != want[i] {
+			t.Fatalf("line %d discount = %d, want %d", i, l.DiscountPaise, want[i])
+		}
+	}
+}
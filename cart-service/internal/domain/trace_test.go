@@ -0,0 +1,27 @@
+package domain
+
+import "testing"
+
+func TestValidTraceParent(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false},
+		{"", false},
+	}
+	for _, tc := range tests {
+		if got := ValidTraceParent(tc.in); got != tc.want {
+			t.Errorf("ValidTraceParent(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+	if tp := NewTraceParent(); !ValidTraceParent(tp) {
+		t.Errorf("NewTraceParent() = %q is not valid", tp)
+	}
+}
@@ -0,0 +1,178 @@
+// Package events is the catalog of events cart-service produces and
+// consumes. Every event type is "<Name>.v<N>" and has a JSON Schema in
+// schemas/<Name>.v<N>.json; producers validate against it before writing to
+// the outbox, and consumers upcast older versions to the current shape.
+//
+// Changing an event: additive, optional fields keep the version. Anything
+// else adds a new schema file with the next version, moves the constant
+// below to it and registers an upcaster from the previous version.
+package events
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Current versions of the events cart-service emits.
+const (
+	CartItemAdded                = "CartItemAdded.v2"
+	CartItemQtyUpdated           = "CartItemQtyUpdated.v1"
+	CartItemRemoved              = "CartItemRemoved.v1"
+	CartDestinationSet           = "CartDestinationSet.v1"
+	CartPromotionApplied         = "CartPromotionApplied.v1"
+	CartPromotionRemoved         = "CartPromotionRemoved.v1"
+	CartMerged                   = "CartMerged.v1"
+	CartCheckedOut               = "CartCheckedOut.v1"
+	CartCheckoutFailed           = "CartCheckoutFailed.v1"
+	CartAbandoned                = "CartAbandoned.v1"
+	CartItemsRepriced            = "CartItemsRepriced.v1"
+	CartItemsAvailabilityChanged = "CartItemsAvailabilityChanged.v1"
+)
+
+// Current versions of the events cart-service consumes.
+const (
+	CatalogPriceChanged   = "CatalogPriceChanged.v1"
+	InventoryStockChanged = "InventoryStockChanged.v1"
+)
+
+var (
+	// ErrUnknownType means the catalog has no schema for the event type.
+	ErrUnknownType = errors.New("events: unknown event type")
+	// ErrInvalid means the payload does not match its schema.
+	ErrInvalid = errors.New("events: payload does not match schema")
+)
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+var (
+	schemas = map[string]*Schema{} // by full type, e.g. "CartItemAdded.v2"
+	latest  = map[string]int{}     // by name, e.g. "CartItemAdded" -> 2
+)
+
+func init() {
+	if err := load(schemaFiles); err != nil {
+		panic(err)
+	}
+}
+
+func load(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "schemas/*.json")
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		eventType := strings.TrimSuffix(path.Base(f), ".json")
+		name, version, ok := split(eventType)
+		if !ok {
+			return fmt.Errorf("events: %s is not named <Name>.v<N>.json", f)
+		}
+		raw, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return err
+		}
+		var s Schema
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return fmt.Errorf("events: %s: %w", f, err)
+		}
+		if err := s.check(""); err != nil {
+			return fmt.Errorf("events: %s: %w", f, err)
+		}
+		schemas[eventType] = &s
+		if version > latest[name] {
+			latest[name] = version
+		}
+	}
+	return nil
+}
+
+// Types lists every event type in the catalog, all versions included.
+func Types() []string {
+	out := make([]string, 0, len(schemas))
+	for t := range schemas {
+		out = append(out, t)
+	}
+	return out
+}
+
+// Current returns the current version of the event named like eventType,
+// which may be any version of it or an unversioned name.
+func Current(eventType string) (string, bool) {
+	name, _ := Split(eventType)
+	v, ok := latest[name]
+	if !ok {
+		return "", false
+	}
+	return Type(name, v), true
+}
+
+// Type builds "<name>.v<version>".
+func Type(name string, version int) string {
+	return name + ".v" + strconv.Itoa(version)
+}
+
+// Split parses "<Name>.v<N>". Names without a version suffix, which some
+// early producers emitted, are version 1.
+func Split(eventType string) (name string, version int) {
+	if name, version, ok := split(eventType); ok {
+		return name, version
+	}
+	return eventType, 1
+}
+
+func split(eventType string) (string, int, bool) {
+	i := strings.LastIndex(eventType, ".v")
+	if i <= 0 {
+		return "", 0, false
+	}
+	v, err := strconv.Atoi(eventType[i+2:])
+	if err != nil || v < 1 {
+		return "", 0, false
+	}
+	return eventType[:i], v, true
+}
+
+// Validate checks a JSON payload against the schema of eventType.
+func Validate(eventType string, data []byte) error {
+	s, ok := schemas[eventType]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownType, eventType)
+	}
+	v, err := decode(data)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalid, eventType, err)
+	}
+	if err := s.validate(v, ""); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalid, eventType, err)
+	}
+	return nil
+}
+
+// Marshal encodes data as the payload of eventType and validates it.
+func Marshal(eventType string, data any) (json.RawMessage, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := Validate(eventType, raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
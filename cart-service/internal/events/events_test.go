@@ -0,0 +1,152 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCatalog(t *testing.T) {
+	for _, c := range []string{
+		CartItemAdded, CartItemQtyUpdated, CartItemRemoved, CartDestinationSet,
+		CartPromotionApplied, CartPromotionRemoved, CartMerged, CartCheckedOut,
+		CartCheckoutFailed, CartAbandoned, CartItemsRepriced, CartItemsAvailabilityChanged,
+		CatalogPriceChanged, InventoryStockChanged,
+	} {
+		if cur, ok := Current(c); !ok || cur != c {
+			t.Errorf("%s: current version is %q", c, cur)
+		}
+	}
+
+	// Every superseded version can be upcast to the current one.
+	for _, eventType := range Types() {
+		name, version := Split(eventType)
+		for v := version; v < latest[name]; v++ {
+			if upcasters[Type(name, v)] == nil {
+				t.Errorf("no upcaster from %s", Type(name, v))
+			}
+		}
+	}
+}
+
+func TestLoadRejectsBadSchemas(t *testing.T) {
+	tests := map[string]string{
+		"schemas/NoVersion.json":    `{"type":"object"}`,
+		"schemas/Bad.v1.json":       `{"type":"objekt"}`,
+		"schemas/Format.v1.json":    `{"type":"string","format":"email"}`,
+		"schemas/Malformed.v1.json": `{"type":`,
+		"schemas/Required.v1.json":  `{"type":"object","required":["a"],"additionalProperties":false}`,
+	}
+	for name, body := range tests {
+		err := load(fstest.MapFS{name: {Data: []byte(body)}})
+		if err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		in      string
+		name    string
+		version int
+	}{
+		{"CartItemAdded.v2", "CartItemAdded", 2},
+		{"CartCheckedOut.v1", "CartCheckedOut", 1},
+		{"CartCheckedOut", "CartCheckedOut", 1},
+		{"Odd.vX", "Odd.vX", 1},
+		{"Odd.v0", "Odd.v0", 1},
+	}
+	for _, tc := range tests {
+		name, version := Split(tc.in)
+		if name != tc.name || version != tc.version {
+			t.Errorf("Split(%q) = %q, %d", tc.in, name, version)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	const item = `"cart_item_id":"0b7e6a52-1c1a-4b0e-9d61-3f2f1f1b2c3d","sku":"SOCK-001","variant_id":""`
+	tests := []struct {
+		name      string
+		eventType string
+		data      string
+		wantErr   string // substring; empty means valid
+	}{
+		{"valid", CartItemAdded, `{` + item + `,"qty_added":1,"qty":3,"unit_price_paise":19900,"currency":"INR"}`, ""},
+		{"null price", CartItemAdded, `{` + item + `,"qty_added":1,"qty":1,"unit_price_paise":null,"currency":"INR"}`, ""},
+		{"extra fields are allowed", CartItemAdded, `{` + item + `,"qty_added":1,"qty":1,"currency":"INR","new":true}`, ""},
+		{"v1 has no currency", "CartItemAdded.v1", `{` + item + `,"qty_added":1,"qty":1}`, ""},
+		{"missing currency", CartItemAdded, `{` + item + `,"qty_added":1,"qty":1}`, `missing required property "currency"`},
+		{"wrong type", CartItemAdded, `{` + item + `,"qty_added":"1","qty":1,"currency":"INR"}`, "/qty_added is string, want integer"},
+		{"fraction", CartItemAdded, `{` + item + `,"qty_added":1.5,"qty":1,"currency":"INR"}`, "/qty_added is number, want integer"},
+		{"below minimum", CartItemAdded, `{` + item + `,"qty_added":0,"qty":1,"currency":"INR"}`, "/qty_added 0 is less than 1"},
+		{"empty sku", CartItemAdded, `{"cart_item_id":"0b7e6a52-1c1a-4b0e-9d61-3f2f1f1b2c3d","sku":"","variant_id":"","qty_added":1,"qty":1,"currency":"INR"}`, "/sku shorter than 1"},
+		{"not a uuid", CartItemAdded, `{"cart_item_id":"42","sku":"A","variant_id":"","qty_added":1,"qty":1,"currency":"INR"}`, `/cart_item_id "42" is not a uuid`},
+		{"enum", CartPromotionRemoved, `{"cart_promo_id":"0b7e6a52-1c1a-4b0e-9d61-3f2f1f1b2c3d","promo_code":"X","promo_type":"VOUCHER"}`, "/promo_type VOUCHER is not one of"},
+		{"consumed event", InventoryStockChanged, `{"sku":"A","availability":"SOLD_OUT"}`, "/availability"},
+		{"date-time", CartAbandoned, `{"cart_id":"0b7e6a52-1c1a-4b0e-9d61-3f2f1f1b2c3d","cart_version":2,"status":"EXPIRED","owner_type":"GUEST","items":[],"expired_at":"yesterday"}`, "/expired_at"},
+		{"not an object", CartDestinationSet, `"560001"`, "/ is string, want object"},
+		{"unknown type", "CartRenamed.v1", `{}`, "unknown event type"},
+	}
+	for _, tc := range tests {
+		err := Validate(tc.eventType, []byte(tc.data))
+		switch {
+		case tc.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tc.name, err)
+		case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+			t.Errorf("%s: err = %v, want %q", tc.name, err, tc.wantErr)
+		}
+	}
+
+	if err := Validate(CartItemAdded, []byte(`{}`)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("invalid payload: err = %v, want ErrInvalid", err)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	raw, err := Marshal(CartDestinationSet, map[string]any{"pincode": "560001"})
+	if err != nil || string(raw) != `{"pincode":"560001"}` {
+		t.Errorf("Marshal = %s, %v", raw, err)
+	}
+	if _, err := Marshal(CartDestinationSet, map[string]any{"pincode": 560001}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("invalid payload: err = %v", err)
+	}
+}
+
+func TestUpcast(t *testing.T) {
+	v1 := `{"cart_item_id":"0b7e6a52-1c1a-4b0e-9d61-3f2f1f1b2c3d","sku":"SOCK-001","variant_id":"","qty_added":1,"qty":1,"unit_price_paise":12345678901234}`
+
+	for _, in := range []string{"CartItemAdded.v1", "CartItemAdded"} {
+		eventType, data, err := Upcast(in, json.RawMessage(v1))
+		if err != nil || eventType != CartItemAdded {
+			t.Fatalf("Upcast(%s) = %s, %v", in, eventType, err)
+		}
+		if err := Validate(eventType, data); err != nil {
+			t.Errorf("upcast %s does not match %s: %v", in, eventType, err)
+		}
+		var got map[string]any
+		dec := json.NewDecoder(strings.NewReader(string(data)))
+		dec.UseNumber()
+		_ = dec.Decode(&got)
+		if got["currency"] != "INR" || got["unit_price_paise"].(json.Number).String() != "12345678901234" {
+			t.Errorf("Upcast(%s) data = %s", in, data)
+		}
+	}
+
+	current := `{"sku":"A","availability":"IN_STOCK"}`
+	if eventType, data, err := Upcast("InventoryStockChanged", json.RawMessage(current)); err != nil || eventType != InventoryStockChanged || string(data) != current {
+		t.Errorf("current version: %s %s %v", eventType, data, err)
+	}
+	if _, _, err := Upcast("CartItemAdded.v9", json.RawMessage(`{}`)); !errors.Is(err, ErrNewerVersion) {
+		t.Errorf("newer version: err = %v", err)
+	}
+	if _, _, err := Upcast("ProductRenamed.v1", json.RawMessage(`{}`)); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("unknown type: err = %v", err)
+	}
+	if _, _, err := Upcast("CartItemAdded.v1", json.RawMessage(`[]`)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("non-object payload: err = %v", err)
+	}
+}
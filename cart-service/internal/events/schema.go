@@ -0,0 +1,203 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of JSON Schema the event catalog uses: type,
+// properties, required, additionalProperties (boolean), items, enum,
+// minimum, minLength and the uuid and date-time formats. Other keywords
+// ($schema, $id, title, description) are accepted and ignored.
+type Schema struct {
+	Type                 types              `json:"type"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Enum                 []any              `json:"enum"`
+	Minimum              *json.Number       `json:"minimum"`
+	MinLength            *int               `json:"minLength"`
+	Format               string             `json:"format"`
+}
+
+// types is "type" as either a single name or a list of names.
+type types []string
+
+func (t *types) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*t = types{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return fmt.Errorf("type must be a string or an array of strings")
+	}
+	*t = many
+	return nil
+}
+
+var knownTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "integer": true,
+	"number": true, "boolean": true, "null": true,
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// check reports schema mistakes that would otherwise only show up as
+// surprising validation results.
+func (s *Schema) check(path string) error {
+	for _, t := range s.Type {
+		if !knownTypes[t] {
+			return fmt.Errorf("%s: unknown type %q", path, t)
+		}
+	}
+	switch s.Format {
+	case "", "uuid", "date-time":
+	default:
+		return fmt.Errorf("%s: unsupported format %q", path, s.Format)
+	}
+	for _, name := range s.Required {
+		if s.Properties[name] == nil && s.AdditionalProperties != nil && !*s.AdditionalProperties {
+			return fmt.Errorf("%s: %q is required but not allowed", path, name)
+		}
+	}
+	for name, p := range s.Properties {
+		if err := p.check(path + "/" + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.check(path + "/items")
+	}
+	return nil
+}
+
+// validate checks v, a value decoded with json.Decoder.UseNumber, and
+// returns the first violation found.
+func (s *Schema) validate(v any, path string) error {
+	if len(s.Type) > 0 && !s.hasType(v) {
+		return violation(path, "is %s, want %s", typeOf(v), strings.Join(s.Type, " or "))
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		return violation(path, "%v is not one of %v", v, s.Enum)
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return violation(path, "missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return violation(path, "unexpected property %q", name)
+				}
+				continue
+			}
+			if err := p.validate(v[name], path+"/"+name); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(item, fmt.Sprintf("%s/%d", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		if s.MinLength != nil && len([]rune(v)) < *s.MinLength {
+			return violation(path, "shorter than %d characters", *s.MinLength)
+		}
+		switch s.Format {
+		case "uuid":
+			if !uuidPattern.MatchString(v) {
+				return violation(path, "%q is not a uuid", v)
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				return violation(path, "%q is not an RFC 3339 date-time", v)
+			}
+		}
+	case json.Number:
+		if s.Minimum != nil {
+			n, _ := v.Float64()
+			min, _ := s.Minimum.Float64()
+			if n < min {
+				return violation(path, "%s is less than %s", v, s.Minimum)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) hasType(v any) bool {
+	got := typeOf(v)
+	for _, t := range s.Type {
+		if t == got || (t == "number" && got == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func inEnum(enum []any, v any) bool {
+	for _, e := range enum {
+		if n, ok := v.(json.Number); ok {
+			if f, ok := e.(float64); ok {
+				if nf, err := n.Float64(); err == nil && nf == f {
+					return true
+				}
+			}
+			continue
+		}
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func violation(path, format string, args ...any) error {
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Errorf("%s %s", path, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,68 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrNewerVersion means the event is newer than this build knows about.
+var ErrNewerVersion = errors.New("events: event version is newer than the catalog")
+
+// upcaster rewrites a payload of one version into the next, in place.
+type upcaster func(data map[string]any) error
+
+// upcasters are keyed by the version they upgrade from.
+var upcasters = map[string]upcaster{
+	"CartItemAdded.v1": cartItemAddedV1toV2,
+}
+
+// Upcast turns a payload of any known version of an event into the current
+// version, returning the current event type with it. Unversioned names are
+// treated as version 1. Payloads already current are returned unchanged.
+func Upcast(eventType string, data json.RawMessage) (string, json.RawMessage, error) {
+	name, version := Split(eventType)
+	current, ok := latest[name]
+	switch {
+	case !ok:
+		return eventType, data, fmt.Errorf("%w: %q", ErrUnknownType, eventType)
+	case version > current:
+		return eventType, data, fmt.Errorf("%w: %s (know up to v%d)", ErrNewerVersion, eventType, current)
+	case version == current:
+		return Type(name, version), data, nil
+	}
+
+	v, err := decode(data)
+	if err != nil {
+		return eventType, data, fmt.Errorf("%w: %s: %v", ErrInvalid, eventType, err)
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return eventType, data, fmt.Errorf("%w: %s: data is not an object", ErrInvalid, eventType)
+	}
+	for ; version < current; version++ {
+		from := Type(name, version)
+		up, ok := upcasters[from]
+		if !ok {
+			return eventType, data, fmt.Errorf("events: no upcaster from %s", from)
+		}
+		if err := up(obj); err != nil {
+			return eventType, data, fmt.Errorf("events: upcasting %s: %w", from, err)
+		}
+	}
+	out, err := json.Marshal(obj)
+	if err != nil {
+		return eventType, data, err
+	}
+	return Type(name, current), out, nil
+}
+
+// cartItemAddedV1toV2 adds currency, which v2 requires now that carts can be
+// priced in any currency. Every v1 event was emitted while carts were priced
+// in INR only.
+func cartItemAddedV1toV2(data map[string]any) error {
+	if _, ok := data["currency"]; !ok {
+		data["currency"] = "INR"
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package fx converts catalog prices into a cart's currency using the rates
+// published to the fx_rates table.
+package fx
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/money"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+)
+
+var (
+	// ErrNoRate means no rate has been published for the pair.
+	ErrNoRate = errors.New("fx: no exchange rate")
+	// ErrStaleRate means the latest rate for the pair is older than allowed.
+	ErrStaleRate = errors.New("fx: exchange rate is stale")
+)
+
+// rateDecimals matches fx_rates.rate and cart_items.fx_rate (DECIMAL(24,12)).
+const rateDecimals = 12
+
+// Rate says one From buys Value To, as published at EffectiveAt.
+type Rate struct {
+	From        string
+	To          string
+	Value       *big.Rat
+	EffectiveAt time.Time
+}
+
+// Parse builds a Rate from its stored decimal form.
+func Parse(from, to, value string, at time.Time) (Rate, error) {
+	v, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok || v.Sign() <= 0 {
+		return Rate{}, fmt.Errorf("fx: invalid rate %q for %s/%s", value, from, to)
+	}
+	return Rate{From: from, To: to, Value: v, EffectiveAt: at}, nil
+}
+
+// Convert converts amount minor units of From into minor units of To.
+func (r Rate) Convert(amount int64) (int64, error) {
+	return money.Convert(amount, r.From, r.To, r.Value)
+}
+
+// String is the rate as stored: a decimal with at most 12 places.
+func (r Rate) String() string {
+	s := r.Value.FloatString(rateDecimals)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// invert returns the To->From rate, rounded to what can be stored so the
+// rate recorded on a line is exactly the one it was converted at.
+func (r Rate) invert() Rate {
+	inv, _ := new(big.Rat).SetString(new(big.Rat).Inv(r.Value).FloatString(rateDecimals))
+	return Rate{From: r.To, To: r.From, Value: inv, EffectiveAt: r.EffectiveAt}
+}
+
+// Rates looks up the rate in force for a currency pair. A pair may be
+// published in either direction; the most recent row wins.
+type Rates struct {
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+// NewRates returns a lookup that refuses rates older than maxAge; zero
+// accepts a rate of any age.
+func NewRates(maxAge time.Duration) *Rates {
+	return &Rates{maxAge: maxAge, now: time.Now}
+}
+
+// Lookup returns the rate converting from into to. Same-currency lookups
+// return 1.
+func (r *Rates) Lookup(rates repo.FXRateRepository, from, to string) (Rate, error) {
+	now := r.now().UTC()
+	if from == to {
+		return Rate{From: from, To: to, Value: big.NewRat(1, 1), EffectiveAt: now}, nil
+	}
+
+	direct, err := rates.Latest(from, to, now)
+	if err != nil {
+		return Rate{}, err
+	}
+	inverse, err := rates.Latest(to, from, now)
+	if err != nil {
+		return Rate{}, err
+	}
+	rate, err := choose(from, to, direct, inverse)
+	if err != nil {
+		return Rate{}, err
+	}
+	if r.maxAge > 0 && now.Sub(rate.EffectiveAt) > r.maxAge {
+		return Rate{}, fmt.Errorf("%w: %s/%s published %s", ErrStaleRate, from, to, rate.EffectiveAt.Format(time.RFC3339))
+	}
+	return rate, nil
+}
+
+// choose picks the newer of the from->to and to->from rows, preferring the
+// direct one when both were published at the same time.
+func choose(from, to string, direct, inverse *domain.FXRate) (Rate, error) {
+	switch {
+	case direct == nil && inverse == nil:
+		return Rate{}, fmt.Errorf("%w: %s/%s", ErrNoRate, from, to)
+	case inverse == nil || (direct != nil && !direct.EffectiveAt.Before(inverse.EffectiveAt)):
+		return Parse(from, to, direct.Rate, direct.EffectiveAt)
+	default:
+		rate, err := Parse(to, from, inverse.Rate, inverse.EffectiveAt)
+		if err != nil {
+			return Rate{}, err
+		}
+		return rate.invert(), nil
+	}
+}
@@ -0,0 +1,63 @@
+package fx
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+)
+
+func TestChoose(t *testing.T) {
+	t0 := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	usdInr := &domain.FXRate{BaseCurrency: "USD", QuoteCurrency: "INR", Rate: "83.250000000000", EffectiveAt: t0}
+	inrUsd := &domain.FXRate{BaseCurrency: "INR", QuoteCurrency: "USD", Rate: "0.012000000000", EffectiveAt: t0.Add(time.Hour)}
+
+	tests := []struct {
+		name            string
+		direct, inverse *domain.FXRate
+		want            string
+		at              time.Time
+		err             error
+	}{
+		{"direct only", usdInr, nil, "83.25", t0, nil},
+		{"inverse only", nil, inrUsd, "83.333333333333", t0.Add(time.Hour), nil},
+		{"newer inverse wins", usdInr, inrUsd, "83.333333333333", t0.Add(time.Hour), nil},
+		{"none", nil, nil, "", time.Time{}, ErrNoRate},
+	}
+	for _, tc := range tests {
+		rate, err := choose("USD", "INR", tc.direct, tc.inverse)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if rate.From != "USD" || rate.To != "INR" || rate.String() != tc.want || !rate.EffectiveAt.Equal(tc.at) {
+			t.Errorf("%s: rate = %s->%s %s at %s, want %s at %s", tc.name, rate.From, rate.To, rate, rate.EffectiveAt, tc.want, tc.at)
+		}
+	}
+
+	// The recorded (rounded) inverse is exactly the rate conversions use.
+	rate, _ := choose("USD", "INR", nil, inrUsd)
+	again, err := Parse("USD", "INR", rate.String(), rate.EffectiveAt)
+	if err != nil || again.Value.Cmp(rate.Value) != 0 {
+		t.Errorf("recorded rate %s does not round-trip: %v", rate, err)
+	}
+}
+
+func TestRateConvert(t *testing.T) {
+	rate, err := Parse("USD", "INR", "83.25", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := rate.Convert(1999); err != nil || got != 166417 {
+		t.Errorf("Convert = %d, %v; want 166417", got, err)
+	}
+	for _, bad := range []string{"", "abc", "0", "-1.5"} {
+		if _, err := Parse("USD", "INR", bad, time.Now()); err == nil {
+			t.Errorf("Parse(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Package history keeps each cart's audit trail in cart_history. Callers take
+// a Snapshot of the locked cart before changing it and Record once the change
+// (and the totals recompute it triggers) is done; Record writes one entry
+// with the lines, promotions and destination that differ, in the same
+// transaction as the change. Entries are never updated or deleted, so a cart
+// that was merged, checked out or expired keeps its whole trail.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+	"github.com/google/uuid"
+)
+
+// Actions recorded in cart_history.action.
+const (
+	ActionCreated             = "CART_CREATED"
+	ActionItemAdded           = "ITEM_ADDED"
+	ActionItemQtyUpdated      = "ITEM_QTY_UPDATED"
+	ActionItemRemoved         = "ITEM_REMOVED"
+	ActionPromotionApplied    = "PROMOTION_APPLIED"
+	ActionPromotionRemoved    = "PROMOTION_REMOVED"
+	ActionDestinationSet      = "DESTINATION_SET"
+	ActionMergedFrom          = "MERGED_FROM" // on the user cart; RelatedCartID is the guest cart
+	ActionMergedInto          = "MERGED_INTO" // on the guest cart; RelatedCartID is the user cart
+	ActionCheckoutStarted     = "CHECKOUT_STARTED"
+	ActionCheckedOut          = "CHECKED_OUT"
+	ActionCheckoutFailed      = "CHECKOUT_FAILED"
+	ActionExpired             = "EXPIRED"
+	ActionRepriced            = "ITEMS_REPRICED"
+	ActionAvailabilityChanged = "ITEMS_AVAILABILITY_CHANGED"
+)
+
+// Kinds of Change.
+const (
+	KindLine        = "LINE"
+	KindPromotion   = "PROMOTION"
+	KindDestination = "DESTINATION"
+)
+
+// Actor is who took an action: the X-Client-Id and Idempotency-Key of an API
+// request, or a worker job.
+type Actor struct {
+	ClientID       string
+	IdempotencyKey string
+}
+
+// Worker is the actor of a worker job.
+func Worker(job string) Actor { return Actor{ClientID: "cart-worker/" + job} }
+
+// Entry describes the action being recorded.
+type Entry struct {
+	Action        string
+	Actor         Actor
+	RelatedCartID []byte
+}
+
+// Change is one line, promotion or destination that differs. Before fields
+// are absent for something added and After fields for something removed.
+type Change struct {
+	Kind string `json:"kind"`
+
+	SKU                  string `json:"sku,omitempty"`
+	VariantID            string `json:"variant_id,omitempty"`
+	QtyBefore            *int   `json:"qty_before,omitempty"`
+	QtyAfter             *int   `json:"qty_after,omitempty"`
+	UnitPriceBeforePaise *int64 `json:"unit_price_before_paise,omitempty"`
+	UnitPriceAfterPaise  *int64 `json:"unit_price_after_paise,omitempty"`
+	AvailabilityBefore   string `json:"availability_before,omitempty"`
+	AvailabilityAfter    string `json:"availability_after,omitempty"`
+
+	PromoCode           string `json:"promo_code,omitempty"`
+	PromoType           string `json:"promo_type,omitempty"`
+	StatusBefore        string `json:"status_before,omitempty"`
+	StatusAfter         string `json:"status_after,omitempty"`
+	DiscountBeforePaise *int64 `json:"discount_before_paise,omitempty"`
+	DiscountAfterPaise  *int64 `json:"discount_after_paise,omitempty"`
+
+	PincodeBefore *string `json:"pincode_before,omitempty"`
+	PincodeAfter  *string `json:"pincode_after,omitempty"`
+}
+
+// Snapshot is a cart as it was before an action.
+type Snapshot struct {
+	cart       domain.Cart
+	items      []domain.CartItem
+	promos     []domain.CartPromotion
+	grandTotal int64
+}
+
+// Take snapshots cart, which the caller has locked.
+func Take(tx repo.Tx, cart *domain.Cart) (*Snapshot, error) {
+	items, err := tx.Items().List(cart.CartID)
+	if err != nil {
+		return nil, err
+	}
+	promos, err := tx.Promotions().List(cart.CartID, repo.PromotionFilter{})
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{cart: *cart, items: items, promos: promos}
+	totals, err := tx.Totals().Get(cart.CartID)
+	switch {
+	case err == nil:
+		s.grandTotal = totals.GrandTotalPaise
+	case !errors.Is(err, repo.ErrNotFound):
+		return nil, err
+	}
+	return s, nil
+}
+
+// Record appends e to the history of cartID with what changed since before;
+// a nil before records a cart that did not exist.
+func Record(tx repo.Tx, cartID []byte, before *Snapshot, e Entry) error {
+	cart, err := tx.Carts().Get(cartID)
+	if err != nil {
+		return err
+	}
+	after, err := Take(tx, cart)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		before = &Snapshot{}
+	}
+
+	changes, err := json.Marshal(diff(before, after))
+	if err != nil {
+		return err
+	}
+	return tx.History().Append(&domain.CartHistory{
+		EntryID:               domain.UUIDToBin16(uuid.New()),
+		CartID:                cartID,
+		CartVersion:           cart.Version,
+		Action:                e.Action,
+		Actor:                 e.Actor.ClientID,
+		IdempotencyKey:        e.Actor.IdempotencyKey,
+		RelatedCartID:         e.RelatedCartID,
+		StatusBefore:          string(before.cart.Status),
+		StatusAfter:           string(cart.Status),
+		GrandTotalBeforePaise: before.grandTotal,
+		GrandTotalAfterPaise:  after.grandTotal,
+		Changes:               string(changes),
+	})
+}
+
+// diff lists what differs between two snapshots of a cart: lines in cart
+// order (removed lines last), then promotions, then the destination.
+func diff(before, after *Snapshot) []Change {
+	changes := []Change{}
+
+	type lineKey struct{ sku, variant string }
+	old := map[lineKey]domain.CartItem{}
+	for _, it := range before.items {
+		old[lineKey{it.SKU, it.VariantID}] = it
+	}
+	for _, it := range after.items {
+		k := lineKey{it.SKU, it.VariantID}
+		was, existed := old[k]
+		delete(old, k)
+		if existed && was.Qty == it.Qty && eq(was.UnitPricePaise, it.UnitPricePaise) && was.Availability == it.Availability {
+			continue
+		}
+		ch := Change{Kind: KindLine, SKU: it.SKU, VariantID: it.VariantID,
+			QtyAfter: &it.Qty, UnitPriceAfterPaise: it.UnitPricePaise, AvailabilityAfter: it.Availability}
+		if existed {
+			ch.QtyBefore, ch.UnitPriceBeforePaise, ch.AvailabilityBefore = &was.Qty, was.UnitPricePaise, was.Availability
+		}
+		changes = append(changes, ch)
+	}
+	for _, it := range before.items {
+		if _, removed := old[lineKey{it.SKU, it.VariantID}]; removed {
+			changes = append(changes, Change{Kind: KindLine, SKU: it.SKU, VariantID: it.VariantID,
+				QtyBefore: &it.Qty, UnitPriceBeforePaise: it.UnitPricePaise, AvailabilityBefore: it.Availability})
+		}
+	}
+
+	oldPromos := map[string]domain.CartPromotion{}
+	for _, p := range before.promos {
+		oldPromos[string(p.CartPromoID)] = p
+	}
+	for _, p := range after.promos {
+		was, existed := oldPromos[string(p.CartPromoID)]
+		if existed && was.Status == p.Status && was.DiscountPaise == p.DiscountPaise {
+			continue
+		}
+		ch := Change{Kind: KindPromotion, PromoCode: p.PromoCode, PromoType: p.PromoType,
+			StatusAfter: p.Status, DiscountAfterPaise: &p.DiscountPaise}
+		if existed {
+			ch.StatusBefore, ch.DiscountBeforePaise = was.Status, &was.DiscountPaise
+		}
+		changes = append(changes, ch)
+	}
+
+	if before.cart.ShipPincode != after.cart.ShipPincode {
+		changes = append(changes, Change{Kind: KindDestination,
+			PincodeBefore: &before.cart.ShipPincode, PincodeAfter: &after.cart.ShipPincode})
+	}
+	return changes
+}
+
+func eq(a, b *int64) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
@@ -0,0 +1,54 @@
+package history
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+)
+
+func TestDiff(t *testing.T) {
+	price := func(p int64) *int64 { return &p }
+	sock := domain.CartItem{SKU: "SOCK-001", Qty: 2, UnitPricePaise: price(19900), Availability: "IN_STOCK"}
+	hat := domain.CartItem{SKU: "CAP-001", Qty: 1, UnitPricePaise: price(49900), Availability: "IN_STOCK"}
+	coupon := domain.CartPromotion{CartPromoID: []byte("p1"), PromoCode: "TENOFF", PromoType: "COUPON", Status: "APPLIED", DiscountPaise: 3980}
+
+	with := func(it domain.CartItem, f func(*domain.CartItem)) domain.CartItem { f(&it); return it }
+	tests := []struct {
+		name          string
+		before, after Snapshot
+		want          string
+	}{
+		{"nothing", Snapshot{items: []domain.CartItem{sock}}, Snapshot{items: []domain.CartItem{sock}}, `[]`},
+		{"added",
+			Snapshot{},
+			Snapshot{items: []domain.CartItem{sock}},
+			`[{"kind":"LINE","sku":"SOCK-001","qty_after":2,"unit_price_after_paise":19900,"availability_after":"IN_STOCK"}]`},
+		{"qty and price",
+			Snapshot{items: []domain.CartItem{sock, hat}},
+			Snapshot{items: []domain.CartItem{with(sock, func(it *domain.CartItem) { it.Qty = 3; it.UnitPricePaise = price(18900) }), hat}},
+			`[{"kind":"LINE","sku":"SOCK-001","qty_before":2,"qty_after":3,"unit_price_before_paise":19900,"unit_price_after_paise":18900,"availability_before":"IN_STOCK","availability_after":"IN_STOCK"}]`},
+		{"removed last",
+			Snapshot{items: []domain.CartItem{hat, sock}},
+			Snapshot{items: []domain.CartItem{with(sock, func(it *domain.CartItem) { it.Availability = "OUT_OF_STOCK" })}},
+			`[{"kind":"LINE","sku":"SOCK-001","qty_before":2,"qty_after":2,"unit_price_before_paise":19900,"unit_price_after_paise":19900,"availability_before":"IN_STOCK","availability_after":"OUT_OF_STOCK"},` +
+				`{"kind":"LINE","sku":"CAP-001","qty_before":1,"unit_price_before_paise":49900,"availability_before":"IN_STOCK"}]`},
+		{"promotion suspended",
+			Snapshot{promos: []domain.CartPromotion{coupon}},
+			Snapshot{promos: []domain.CartPromotion{{CartPromoID: []byte("p1"), PromoCode: "TENOFF", PromoType: "COUPON", Status: "SUSPENDED"}}},
+			`[{"kind":"PROMOTION","promo_code":"TENOFF","promo_type":"COUPON","status_before":"APPLIED","status_after":"SUSPENDED","discount_before_paise":3980,"discount_after_paise":0}]`},
+		{"destination",
+			Snapshot{},
+			Snapshot{cart: domain.Cart{ShipPincode: "560001"}},
+			`[{"kind":"DESTINATION","pincode_before":"","pincode_after":"560001"}]`},
+	}
+	for _, tc := range tests {
+		got, err := json.Marshal(diff(&tc.before, &tc.after))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("%s:\n got %s\nwant %s", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,540 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/catalog"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/inventory"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/promo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// api drives the whole router over an in-memory store.
+type api struct {
+	t     *testing.T
+	r     *gin.Engine
+	store *repo.Memory
+	keys  int
+}
+
+func newAPI(t *testing.T, stock map[string]int) *api {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	promos, err := promo.NewEngine(promo.Config{Rules: []promo.Rule{
+		{Code: "TENOFF", Kind: promo.KindPercentOff, PercentBps: 1000},
+		{Code: "ONCE", Kind: promo.KindFlatOff, FlatPaise: 5000, PerUserLimit: 1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := repo.NewMemory()
+	r := NewRouter(store, Deps{
+		Promotions: promos,
+		Inventory:  inventory.NewFake(stock),
+		Catalog: catalog.NewFile([]catalog.Product{
+			{SKU: "SOCK-001", Name: "Socks", Currency: "INR", UnitPricePaise: 19900, TaxRateBps: 1200},
+			{SKU: "CAP-001", Name: "Cap", Currency: "INR", UnitPricePaise: 49900, TaxRateBps: 1800},
+			{SKU: "GONE-001", Name: "Gone", Currency: "INR", UnitPricePaise: 100, Availability: "OUT_OF_STOCK"},
+		}),
+	})
+	return &api{t: t, r: r, store: store}
+}
+
+// do sends a request with a fresh idempotency key unless headers name one.
+func (a *api) do(method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	a.keys++
+	req.Header.Set(HClientID, "test")
+	req.Header.Set(HIdempotencyKey, fmt.Sprintf("key-%d", a.keys))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	a.r.ServeHTTP(w, req)
+	return w
+}
+
+// must is do, failing the test unless the status is want.
+func (a *api) must(want int, method, path, body string, headers ...string) map[string]any {
+	a.t.Helper()
+	w := a.do(method, path, body, headers...)
+	if w.Code != want {
+		a.t.Fatalf("%s %s = %d %s, want %d", method, path, w.Code, w.Body, want)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		a.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	return out
+}
+
+func (a *api) createCart(body string) string {
+	a.t.Helper()
+	return a.must(http.StatusOK, "POST", "/v1/carts", body)["cart_id"].(string)
+}
+
+func grandTotal(view map[string]any) float64 {
+	return view["totals"].(map[string]any)["grand_total_paise"].(float64)
+}
+
+func cartVersion(view map[string]any) int {
+	return int(view["cart"].(map[string]any)["version"].(float64))
+}
+
+func TestAPICartLifecycle(t *testing.T) {
+	a := newAPI(t, nil)
+	cartID := a.createCart(`{"owner_type":"GUEST","guest_id":"g-1","channel":"web"}`)
+	if again := a.createCart(`{"owner_type":"GUEST","guest_id":"g-1","channel":"web"}`); again != cartID {
+		t.Fatalf("second create returned cart %s, want %s", again, cartID)
+	}
+	base := "/v1/carts/" + cartID
+
+	view := a.must(http.StatusOK, "POST", base+"/items", `{"sku":"SOCK-001","qty":2}`)
+	// 2 x 199.00 + 12% tax.
+	if got := grandTotal(view); got != 44576 {
+		t.Errorf("grand total after add = %v, want 44576", got)
+	}
+	a.must(http.StatusOK, "POST", base+"/items", `{"sku":"CAP-001","qty":1}`)
+	a.must(http.StatusUnprocessableEntity, "POST", base+"/items", `{"sku":"GONE-001","qty":1}`)
+	a.must(http.StatusUnprocessableEntity, "POST", base+"/items", `{"sku":"NOPE","qty":1}`)
+
+	view = a.must(http.StatusOK, "PATCH", base+"/items/SOCK-001", `{"qty":1}`)
+	view = a.must(http.StatusOK, "DELETE", base+"/items/CAP-001", ``)
+	items := view["items"].([]any)
+	if len(items) != 1 || items[0].(map[string]any)["qty"].(float64) != 1 {
+		t.Errorf("items = %v, want one sock", items)
+	}
+
+	view = a.must(http.StatusOK, "POST", base+"/promotions", `{"promo_code":"tenoff"}`)
+	// 199.00 - 10% = 179.10, + 12% tax on the discounted line.
+	if d := view["totals"].(map[string]any)["discount_paise"].(float64); d != 1990 {
+		t.Errorf("discount = %v, want 1990", d)
+	}
+	view = a.must(http.StatusOK, "DELETE", base+"/promotions/TENOFF", ``)
+	if d := view["totals"].(map[string]any)["discount_paise"].(float64); d != 0 {
+		t.Errorf("discount after removal = %v", d)
+	}
+
+	view = a.must(http.StatusOK, "PUT", base+"/destination", `{"pincode":"560001"}`)
+	if p := view["cart"].(map[string]any)["ship_pincode"]; p != "560001" {
+		t.Errorf("ship_pincode = %v", p)
+	}
+
+	got := a.must(http.StatusOK, "GET", base, ``)
+	if cartVersion(got) != cartVersion(view) || grandTotal(got) != grandTotal(view) {
+		t.Errorf("GET = %v, want the last mutation's view %v", got["cart"], view["cart"])
+	}
+	if len(a.store.Outbox()) == 0 {
+		t.Error("no events were written to the outbox")
+	}
+}
+
+func TestAPICreateCartCurrency(t *testing.T) {
+	a := newAPI(t, nil)
+	cartID := a.createCart(`{"owner_type":"GUEST","guest_id":"g-1","channel":"web","currency":"INR"}`)
+	if again := a.createCart(`{"owner_type":"GUEST","guest_id":"g-1","channel":"web"}`); again != cartID {
+		t.Errorf("create without a currency returned %s, want the ACTIVE cart %s", again, cartID)
+	}
+	got := a.must(http.StatusConflict, "POST", "/v1/carts", `{"owner_type":"GUEST","guest_id":"g-1","channel":"web","currency":"usd"}`)
+	if got["cart_id"] != cartID || got["currency"] != "INR" {
+		t.Errorf("409 = %v, want the ACTIVE INR cart", got)
+	}
+}
+
+func TestAPIPreconditions(t *testing.T) {
+	a := newAPI(t, nil)
+	base := "/v1/carts/" + a.createCart(`{"owner_type":"GUEST","guest_id":"g-1"}`)
+
+	w := a.do("POST", base+"/items", `{"sku":"SOCK-001","qty":1}`)
+	etag := w.Header().Get(HETag)
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("add = %d with ETag %q", w.Code, etag)
+	}
+
+	a.must(http.StatusOK, "PATCH", base+"/items/SOCK-001", `{"qty":2}`, HIfMatch, etag)
+	// The cart moved on: the old ETag no longer matches.
+	stale := a.must(http.StatusPreconditionFailed, "PATCH", base+"/items/SOCK-001", `{"qty":3}`, HIfMatch, etag)
+	if stale["cart"] == nil {
+		t.Errorf("412 without the current cart: %v", stale)
+	}
+
+	// Setting the quantity a line already has changes nothing: no new
+	// version, ETag, history entry or event.
+	w = a.do("GET", base, ``)
+	etag, events := w.Header().Get(HETag), len(a.store.Outbox())
+	history := a.must(http.StatusOK, "GET", base+"/history", ``)["entries"].([]any)
+	if w = a.do("PATCH", base+"/items/SOCK-001", `{"qty":2}`, HIfMatch, etag); w.Code != http.StatusOK || w.Header().Get(HETag) != etag {
+		t.Errorf("unchanged qty = %d with ETag %q, want 200 with %q", w.Code, w.Header().Get(HETag), etag)
+	}
+	if len(a.store.Outbox()) != events || len(a.must(http.StatusOK, "GET", base+"/history", ``)["entries"].([]any)) != len(history) {
+		t.Error("unchanged qty wrote an event or a history entry")
+	}
+
+	w = a.do("GET", base, ``, HIfNoneMatch, a.do("GET", base, ``).Header().Get(HETag))
+	if w.Code != http.StatusNotModified {
+		t.Errorf("GET with current ETag = %d, want 304", w.Code)
+	}
+}
+
+func TestAPIIdempotentReplay(t *testing.T) {
+	a := newAPI(t, nil)
+	base := "/v1/carts/" + a.createCart(`{"owner_type":"GUEST","guest_id":"g-1"}`)
+
+	first := a.do("POST", base+"/items", `{"sku":"SOCK-001","qty":1}`, HIdempotencyKey, "add-once")
+	retry := a.do("POST", base+"/items", `{"sku":"SOCK-001","qty":1}`, HIdempotencyKey, "add-once")
+	if first.Code != http.StatusOK || retry.Code != http.StatusOK || retry.Body.String() != first.Body.String() {
+		t.Fatalf("retry = %d %s, want a replay of %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get(HETag) != first.Header().Get(HETag) {
+		t.Errorf("replayed ETag %q, want %q", retry.Header().Get(HETag), first.Header().Get(HETag))
+	}
+
+	view := a.must(http.StatusOK, "GET", base, ``)
+	if qty := view["items"].([]any)[0].(map[string]any)["qty"].(float64); qty != 1 {
+		t.Errorf("qty = %v after a replayed add, want 1", qty)
+	}
+
+	a.must(http.StatusConflict, "POST", base+"/items", `{"sku":"SOCK-001","qty":2}`, HIdempotencyKey, "add-once")
+	a.must(http.StatusBadRequest, "POST", base+"/items", `{"sku":"SOCK-001","qty":1}`, HIdempotencyKey, "")
+}
+
+func TestAPIMerge(t *testing.T) {
+	a := newAPI(t, nil)
+	const user = "5b0d1c2e-8f4a-4f7e-9a51-7c7f0b8e2a11"
+	userCart := a.createCart(`{"owner_type":"USER","user_id":"` + user + `","channel":"web"}`)
+	a.must(http.StatusOK, "POST", "/v1/carts/"+userCart+"/items", `{"sku":"SOCK-001","qty":1}`)
+
+	guest := "/v1/carts/" + a.createCart(`{"owner_type":"GUEST","guest_id":"g-1","channel":"web"}`)
+	a.must(http.StatusOK, "POST", guest+"/items", `{"sku":"SOCK-001","qty":2}`)
+	a.must(http.StatusOK, "POST", guest+"/items", `{"sku":"CAP-001","qty":1}`)
+
+	view := a.must(http.StatusOK, "POST", guest+"/merge", `{"user_id":"`+user+`"}`)
+	if id := view["cart"].(map[string]any)["cart_id"]; id != userCart {
+		t.Fatalf("merged into %v, want %s", id, userCart)
+	}
+	qty := map[string]float64{}
+	for _, it := range view["items"].([]any) {
+		qty[it.(map[string]any)["sku"].(string)] = it.(map[string]any)["qty"].(float64)
+	}
+	if qty["SOCK-001"] != 3 || qty["CAP-001"] != 1 {
+		t.Errorf("merged lines = %v", qty)
+	}
+
+	if status := a.must(http.StatusOK, "GET", guest, ``)["cart"].(map[string]any)["status"]; status != "MERGED" {
+		t.Errorf("guest cart status = %v", status)
+	}
+	a.must(http.StatusConflict, "POST", guest+"/items", `{"sku":"SOCK-001","qty":1}`)
+
+	// Refused merges leave no trace: no user cart is created for them.
+	const other = "0f6c2a8e-3b1d-4c5e-8f7a-9d0e1b2c3a4f"
+	a.must(http.StatusConflict, "POST", guest+"/merge", `{"user_id":"`+other+`"}`)
+	expired := a.createCart(`{"owner_type":"GUEST","guest_id":"g-2","channel":"web"}`)
+	past := time.Now().Add(-time.Minute)
+	a.update(expired, func(c *domain.Cart) { c.ExpiresAt = &past })
+	a.must(http.StatusGone, "POST", "/v1/carts/"+expired+"/merge", `{"user_id":"`+other+`"}`)
+	if id := a.activeUserCart(other, "web"); id != "" {
+		t.Errorf("refused merges created user cart %s", id)
+	}
+}
+
+// update changes a stored cart outside the API.
+func (a *api) update(cartID string, f func(*domain.Cart)) {
+	a.t.Helper()
+	err := a.store.Transaction(context.Background(), func(tx repo.Tx) error {
+		cart, err := tx.Carts().Lock(domain.UUIDToBin16(uuid.MustParse(cartID)))
+		if err != nil {
+			return err
+		}
+		f(cart)
+		return tx.Carts().Bump(cart)
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+}
+
+// stored reads a cart outside the API.
+func (a *api) stored(cartID string) domain.Cart {
+	a.t.Helper()
+	var cart *domain.Cart
+	err := a.store.Transaction(context.Background(), func(tx repo.Tx) error {
+		var err error
+		cart, err = tx.Carts().Get(domain.UUIDToBin16(uuid.MustParse(cartID)))
+		return err
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return *cart
+}
+
+// activeUserCart returns the user's ACTIVE cart on channel, or "".
+func (a *api) activeUserCart(userID, channel string) string {
+	a.t.Helper()
+	var id string
+	err := a.store.Transaction(context.Background(), func(tx repo.Tx) error {
+		cart, err := tx.Carts().FindActive("USER", domain.UUIDToBin16(uuid.MustParse(userID)), "", channel)
+		if errors.Is(err, repo.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		id = bin16String(cart.CartID)
+		return nil
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return id
+}
+
+// promoStatus is the status of code on a cart view, or "" when absent.
+func promoStatus(view map[string]any, code string) string {
+	promos, _ := view["promotions"].([]any)
+	for _, p := range promos {
+		if p := p.(map[string]any); p["promo_code"] == code {
+			return p["status"].(string)
+		}
+	}
+	return ""
+}
+
+func TestAPICheckout(t *testing.T) {
+	a := newAPI(t, map[string]int{"SOCK-001": 5, "CAP-001": 0})
+	base := "/v1/carts/" + a.createCart(`{"owner_type":"GUEST","guest_id":"g-1"}`)
+
+	a.must(http.StatusUnprocessableEntity, "POST", base+"/checkout", ``)
+
+	a.must(http.StatusOK, "POST", base+"/items", `{"sku":"CAP-001","qty":1}`)
+	refused := a.must(http.StatusUnprocessableEntity, "POST", base+"/checkout", ``)
+	if lines, _ := refused["lines"].([]any); len(lines) != 1 {
+		t.Errorf("refused lines = %v", refused["lines"])
+	}
+	if status := a.must(http.StatusOK, "GET", base, ``)["cart"].(map[string]any)["status"]; status != "ACTIVE" {
+		t.Fatalf("cart is %v after a refused checkout, want ACTIVE", status)
+	}
+
+	a.must(http.StatusOK, "DELETE", base+"/items/CAP-001", ``)
+	a.must(http.StatusOK, "POST", base+"/items", `{"sku":"SOCK-001","qty":2}`)
+	view := a.must(http.StatusOK, "POST", base+"/checkout", ``)
+	if status := view["cart"].(map[string]any)["status"]; status != "CHECKED_OUT" {
+		t.Errorf("status after checkout = %v", status)
+	}
+
+	saga := a.must(http.StatusOK, "GET", base+"/checkout", ``)["checkout"].(map[string]any)
+	if saga["status"] != "COMPLETED" {
+		t.Errorf("saga = %v", saga)
+	}
+	a.must(http.StatusConflict, "POST", base+"/items", `{"sku":"SOCK-001","qty":1}`)
+}
+
+func TestAPIHistory(t *testing.T) {
+	a := newAPI(t, nil)
+	const user = "5b0d1c2e-8f4a-4f7e-9a51-7c7f0b8e2a11"
+	userCart := a.createCart(`{"owner_type":"USER","user_id":"` + user + `","channel":"web"}`)
+
+	guestID := a.createCart(`{"owner_type":"GUEST","guest_id":"g-1","channel":"web"}`)
+	guest := "/v1/carts/" + guestID
+	a.must(http.StatusOK, "POST", guest+"/items", `{"sku":"SOCK-001","qty":2}`, HClientID, "web-app", HIdempotencyKey, "add-socks")
+	a.must(http.StatusOK, "PATCH", guest+"/items/SOCK-001", `{"qty":3}`)
+	a.must(http.StatusOK, "POST", guest+"/promotions", `{"promo_code":"TENOFF"}`)
+	a.must(http.StatusOK, "POST", guest+"/merge", `{"user_id":"`+user+`"}`)
+
+	// The merged guest cart keeps its whole history.
+	got := a.must(http.StatusOK, "GET", guest+"/history", ``)
+	entries := got["entries"].([]any)
+	wantActions := []string{"CART_CREATED", "ITEM_ADDED", "ITEM_QTY_UPDATED", "PROMOTION_APPLIED", "MERGED_INTO"}
+	if len(entries) != len(wantActions) || got["next_cursor"] != nil {
+		t.Fatalf("history = %v, want %v", got, wantActions)
+	}
+	for i, want := range wantActions {
+		if action := entries[i].(map[string]any)["action"]; action != want {
+			t.Errorf("entry %d = %v, want %s", i, action, want)
+		}
+	}
+
+	added := entries[1].(map[string]any)
+	if added["actor"] != "web-app" || added["idempotency_key"] != "add-socks" {
+		t.Errorf("add recorded actor %v key %v", added["actor"], added["idempotency_key"])
+	}
+	if added["grand_total_before_paise"].(float64) != 0 || added["grand_total_after_paise"].(float64) != 44576 {
+		t.Errorf("add recorded totals %v -> %v", added["grand_total_before_paise"], added["grand_total_after_paise"])
+	}
+	line := added["changes"].([]any)[0].(map[string]any)
+	if line["kind"] != "LINE" || line["sku"] != "SOCK-001" || line["qty_before"] != nil || line["qty_after"].(float64) != 2 {
+		t.Errorf("add recorded %v", line)
+	}
+	line = entries[2].(map[string]any)["changes"].([]any)[0].(map[string]any)
+	if line["qty_before"].(float64) != 2 || line["qty_after"].(float64) != 3 {
+		t.Errorf("qty update recorded %v", line)
+	}
+	promo := entries[3].(map[string]any)["changes"].([]any)[0].(map[string]any)
+	if promo["kind"] != "PROMOTION" || promo["promo_code"] != "TENOFF" || promo["discount_after_paise"].(float64) != 5970 {
+		t.Errorf("promotion recorded %v", promo)
+	}
+	merged := entries[4].(map[string]any)
+	if merged["related_cart_id"] != userCart || merged["status_before"] != "ACTIVE" || merged["status_after"] != "MERGED" {
+		t.Errorf("merge recorded %v", merged)
+	}
+
+	// The user cart pages through its history and shows where the lines came from.
+	base := "/v1/carts/" + userCart
+	page := a.must(http.StatusOK, "GET", base+"/history?limit=1", ``)
+	if e := page["entries"].([]any); len(e) != 1 || e[0].(map[string]any)["action"] != "CART_CREATED" {
+		t.Fatalf("first page = %v", page)
+	}
+	cursor, _ := page["next_cursor"].(string)
+	page = a.must(http.StatusOK, "GET", base+"/history?limit=1&cursor="+cursor, ``)
+	e := page["entries"].([]any)
+	if len(e) != 1 || e[0].(map[string]any)["action"] != "MERGED_FROM" || e[0].(map[string]any)["related_cart_id"] != guestID {
+		t.Fatalf("second page = %v", page)
+	}
+	if page["next_cursor"] != nil {
+		t.Errorf("next_cursor = %v after the last entry", page["next_cursor"])
+	}
+
+	a.must(http.StatusOK, "POST", base+"/checkout", ``)
+	e = a.must(http.StatusOK, "GET", base+"/history", ``)["entries"].([]any)
+	last := e[len(e)-1].(map[string]any)
+	if last["action"] != "CHECKED_OUT" || last["status_after"] != "CHECKED_OUT" {
+		t.Errorf("last entry after checkout = %v", last)
+	}
+
+	a.must(http.StatusBadRequest, "GET", base+"/history?limit=0", ``)
+	a.must(http.StatusBadRequest, "GET", base+"/history?cursor=x", ``)
+	a.must(http.StatusNotFound, "GET", "/v1/carts/"+"00000000-0000-0000-0000-000000000001/history", ``)
+}
+
+func TestAPIPerUserLimitAcrossCarts(t *testing.T) {
+	a := newAPI(t, nil)
+	const user = "5b0d1c2e-8f4a-4f7e-9a51-7c7f0b8e2a11"
+	web := "/v1/carts/" + a.createCart(`{"owner_type":"USER","user_id":"`+user+`","channel":"web"}`)
+	app := "/v1/carts/" + a.createCart(`{"owner_type":"USER","user_id":"`+user+`","channel":"app"}`)
+	for _, base := range []string{web, app} {
+		a.must(http.StatusOK, "POST", base+"/items", `{"sku":"SOCK-001","qty":1}`)
+		a.must(http.StatusOK, "POST", base+"/promotions", `{"promo_code":"ONCE"}`)
+	}
+
+	a.must(http.StatusOK, "POST", web+"/checkout", ``)
+	view := a.must(http.StatusOK, "POST", app+"/checkout", ``)
+	if got := promoStatus(view, "ONCE"); got != "SUSPENDED" {
+		t.Errorf("ONCE on the second cart is %q after the first was checked out, want SUSPENDED", got)
+	}
+	if d := view["totals"].(map[string]any)["discount_paise"].(float64); d != 0 {
+		t.Errorf("second checkout discounted %v", d)
+	}
+}
+
+func TestAPIMergeKeepsPerUserLimit(t *testing.T) {
+	a := newAPI(t, nil)
+	const user = "5b0d1c2e-8f4a-4f7e-9a51-7c7f0b8e2a11"
+	used := "/v1/carts/" + a.createCart(`{"owner_type":"USER","user_id":"`+user+`","channel":"web"}`)
+	a.must(http.StatusOK, "POST", used+"/items", `{"sku":"SOCK-001","qty":1}`)
+	a.must(http.StatusOK, "POST", used+"/promotions", `{"promo_code":"ONCE"}`)
+	a.must(http.StatusOK, "POST", used+"/checkout", ``)
+
+	// As a guest the shopper has no redemptions, so the coupon applies.
+	guest := "/v1/carts/" + a.createCart(`{"owner_type":"GUEST","guest_id":"g-1","channel":"web"}`)
+	a.must(http.StatusOK, "POST", guest+"/items", `{"sku":"SOCK-001","qty":1}`)
+	a.must(http.StatusOK, "POST", guest+"/promotions", `{"promo_code":"ONCE"}`)
+
+	view := a.must(http.StatusOK, "POST", guest+"/merge", `{"user_id":"`+user+`"}`)
+	if got := promoStatus(view, "ONCE"); got != "SUSPENDED" {
+		t.Errorf("ONCE is %q on the user cart after merge, want SUSPENDED", got)
+	}
+	if d := view["totals"].(map[string]any)["discount_paise"].(float64); d != 0 {
+		t.Errorf("merged cart discounted %v", d)
+	}
+
+	// The suspended coupon gives no discount, so another one takes its place
+	// within the one coupon a cart may stack, on apply and on every reprice.
+	merged := "/v1/carts/" + view["cart"].(map[string]any)["cart_id"].(string)
+	a.must(http.StatusOK, "POST", merged+"/promotions", `{"promo_code":"TENOFF"}`)
+	view = a.must(http.StatusOK, "POST", merged+"/items", `{"sku":"CAP-001","qty":1}`)
+	if once, ten := promoStatus(view, "ONCE"), promoStatus(view, "TENOFF"); once != "SUSPENDED" || ten != "APPLIED" {
+		t.Errorf("after repricing ONCE is %q and TENOFF %q, want SUSPENDED and APPLIED", once, ten)
+	}
+}
+
+// No sequence of handler calls moves a cart out of a terminal state: every
+// call is refused with 409 or 410 and leaves the status and version alone.
+func TestAPITerminalCartsRefuseEveryChange(t *testing.T) {
+	a := newAPI(t, nil)
+	const user = "5b0d1c2e-8f4a-4f7e-9a51-7c7f0b8e2a11"
+	past := time.Now().Add(-time.Minute)
+	expire := func(body string) string {
+		id := a.createCart(body)
+		a.must(http.StatusOK, "POST", "/v1/carts/"+id+"/items", `{"sku":"SOCK-001","qty":1}`)
+		a.update(id, func(c *domain.Cart) { c.ExpiresAt = &past })
+		// Asking for the owner's cart closes the expired one.
+		a.createCart(body)
+		return id
+	}
+
+	merged := a.createCart(`{"owner_type":"GUEST","guest_id":"g-1","channel":"web"}`)
+	a.must(http.StatusOK, "POST", "/v1/carts/"+merged+"/items", `{"sku":"SOCK-001","qty":1}`)
+	a.must(http.StatusOK, "POST", "/v1/carts/"+merged+"/merge", `{"user_id":"`+user+`"}`)
+	checkedOut := a.createCart(`{"owner_type":"GUEST","guest_id":"g-2","channel":"web"}`)
+	a.must(http.StatusOK, "POST", "/v1/carts/"+checkedOut+"/items", `{"sku":"SOCK-001","qty":1}`)
+	a.must(http.StatusOK, "POST", "/v1/carts/"+checkedOut+"/checkout", ``)
+	carts := map[domain.CartStatus]string{
+		domain.CartMerged:     merged,
+		domain.CartCheckedOut: checkedOut,
+		domain.CartExpired:    expire(`{"owner_type":"GUEST","guest_id":"g-3","channel":"web"}`),
+		domain.CartAbandoned:  expire(`{"owner_type":"USER","user_id":"` + user + `","channel":"app"}`),
+	}
+	for status, id := range carts {
+		if got := a.stored(id).Status; got != status || !got.Terminal() {
+			t.Fatalf("set-up cart is %s, want terminal %s", got, status)
+		}
+	}
+
+	type call struct{ method, path, body string }
+	calls := []call{
+		{"POST", "/items", `{"sku":"CAP-001","qty":1}`},
+		{"PATCH", "/items/SOCK-001", `{"qty":3}`},
+		{"DELETE", "/items/SOCK-001", ``},
+		{"POST", "/promotions", `{"promo_code":"TENOFF"}`},
+		{"DELETE", "/promotions/TENOFF", ``},
+		{"PUT", "/destination", `{"pincode":"560001"}`},
+		{"POST", "/merge", `{"user_id":"` + user + `"}`},
+		{"POST", "/checkout", ``},
+	}
+	prop := func(ops [8]uint8) bool {
+		for status, id := range carts {
+			before := a.stored(id)
+			for _, op := range ops {
+				cl := calls[int(op)%len(calls)]
+				w := a.do(cl.method, "/v1/carts/"+id+cl.path, cl.body)
+				if w.Code != http.StatusConflict && w.Code != http.StatusGone {
+					t.Logf("%s %s on a %s cart = %d %s", cl.method, cl.path, status, w.Code, w.Body)
+					return false
+				}
+			}
+			if after := a.stored(id); after.Status != before.Status || after.Version != before.Version {
+				t.Logf("%s cart moved to %s v%d", status, after.Status, after.Version)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(prop, &quick.Config{MaxCount: 25}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,332 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/history"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/idempotency"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/lifecycle"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/money"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/outbox"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Shared building blocks for handlers that mutate a cart inside one transaction.
+
+const maxLineQty = 999
+
+var errCartExpired = errors.New("cart has expired")
+
+type httpResult struct {
+	status int
+	body   any
+}
+
+// cartEvent is an outbox event produced by a cart mutation. mutateCart adds
+// cart_id, client_id and the new cart_version to Data before enqueueing it.
+type cartEvent struct {
+	Type string
+	Data gin.H
+}
+
+// cartMutation applies one change to a locked, ACTIVE cart. It either returns
+// the answer for a change not made (a rejection, or the current view when
+// there is nothing to change; recorded against the idempotency key, cart left
+// untouched) or the events describing what changed.
+type cartMutation func(tx repo.Tx, cart *domain.Cart) (events []cartEvent, reject *httpResult, err error)
+
+// mutateCart runs fn with the standard mutation envelope: cart row lock,
+// ACTIVE and If-Match checks, version bump, totals recompute, outbox events,
+// the history entry for action and the cart view response. The response is
+// recorded against the request's idempotency claim in the same transaction as
+// the change.
+func (h *Handlers) mutateCart(c *gin.Context, cartID []byte, action string, fn cartMutation) {
+	expectedVersion, ok := ifMatchFromRequest(c)
+	if !ok {
+		return
+	}
+	clientID := c.GetHeader(HClientID)
+	idemKey := c.GetHeader(HIdempotencyKey)
+	trace := traceParent(c)
+
+	statusCode := http.StatusOK
+	var respBody any
+
+	err := withTx(c.Request.Context(), h.store, func(tx repo.Tx) error {
+		finish := func(r *httpResult) error {
+			statusCode, respBody = r.status, r.body
+			return completeClaim(c, tx, cartID, statusCode, respBody)
+		}
+
+		cart, err := tx.Carts().Lock(cartID)
+		switch {
+		case errors.Is(err, repo.ErrNotFound):
+			return finish(&httpResult{http.StatusNotFound, gin.H{"error": "cart not found"}})
+		case err != nil:
+			return err
+		case lifecycle.IsExpired(cart, time.Now()):
+			return finish(&httpResult{http.StatusGone, gin.H{"error": "cart has expired"}})
+		}
+		if err := cart.Edit(); err != nil {
+			return finish(transitionConflict(err))
+		}
+		if err := checkCartVersion(cart, expectedVersion); err != nil {
+			return err
+		}
+		before, err := history.Take(tx, cart)
+		if err != nil {
+			return err
+		}
+
+		events, reject, err := fn(tx, cart)
+		if err != nil {
+			return err
+		}
+		if reject != nil {
+			return finish(reject)
+		}
+
+		// Every change keeps the cart alive for another TTL.
+		cart.ExpiresAt = h.ttl.ExpiresAt(cart.OwnerType, time.Now())
+		if err := bumpCartVersion(tx, cart); err != nil {
+			return err
+		}
+		if err := h.repricer.Recompute(tx, cart); err != nil {
+			return err
+		}
+		if err := history.Record(tx, cartID, before, history.Entry{
+			Action: action,
+			Actor:  history.Actor{ClientID: clientID, IdempotencyKey: idemKey},
+		}); err != nil {
+			return err
+		}
+		for _, ev := range events {
+			if ev.Data == nil {
+				ev.Data = gin.H{}
+			}
+			ev.Data["cart_id"] = bin16String(cartID)
+			ev.Data["client_id"] = clientID
+			ev.Data["cart_version"] = cart.Version
+			if err := enqueueEvent(tx, cartID, ev.Type, idemKey, trace, ev.Data); err != nil {
+				return err
+			}
+		}
+
+		view, err := loadCartView(tx, cartID)
+		if err != nil {
+			return err
+		}
+		return finish(&httpResult{http.StatusOK, view})
+	})
+	if errors.Is(err, errCartPrecondition) {
+		respondPreconditionFailed(c, h.store, cartID)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setCartETag(c, respBody)
+	c.JSON(statusCode, respBody)
+}
+
+// transitionConflict is the 409 answer to a state change the cart state
+// machine refuses; the body carries the cart's current status.
+func transitionConflict(err error) *httpResult {
+	body := gin.H{"error": err.Error()}
+	var te *domain.TransitionError
+	if errors.As(err, &te) {
+		body["status"] = te.From
+	}
+	return &httpResult{http.StatusConflict, body}
+}
+
+// completeClaim records the response against the request's idempotency claim
+// inside tx, so a retry after commit replays it instead of re-running the
+// change. Routes outside the idempotency middleware have no claim.
+func completeClaim(c *gin.Context, tx repo.Tx, resourceID []byte, status int, body any) error {
+	claim := idempotency.FromContext(c)
+	if claim == nil {
+		return nil
+	}
+	claim.SetResourceID(resourceID)
+	headers := map[string]string{}
+	if etag := cartViewETag(body); etag != "" {
+		headers[HETag] = etag
+	}
+	return claim.CompleteTx(tx, status, body, headers)
+}
+
+const HTraceParent = "traceparent"
+
+// traceParent returns the request's W3C traceparent, or starts a new trace
+// when the caller sent none, so every event carries one.
+func traceParent(c *gin.Context) string {
+	if tp := c.GetHeader(HTraceParent); domain.ValidTraceParent(tp) {
+		return tp
+	}
+	return domain.NewTraceParent()
+}
+
+// enqueueEvent writes a cart event to cart_outbox within the caller's transaction.
+func enqueueEvent(tx repo.Tx, cartID []byte, eventType, idemKey, trace string, data any) error {
+	cartUUID, err := domain.Bin16ToUUID(cartID)
+	if err != nil {
+		return err
+	}
+	return outbox.Enqueue(tx.Outbox(), cartID, domain.EventEnvelope{
+		EventType:      eventType,
+		CorrelationID:  cartUUID.String(),
+		TraceParent:    trace,
+		IdempotencyKey: idemKey,
+		Data:           data,
+	})
+}
+
+// loadCartView renders the cart, its items, promotions and totals the way GetCart returns them.
+func loadCartView(tx repo.Tx, cartID []byte) (gin.H, error) {
+	cart, err := tx.Carts().Get(cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := tx.Items().List(cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	promos, err := tx.Promotions().List(cartID, repo.PromotionFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := tx.Totals().Get(cartID)
+	if errors.Is(err, repo.ErrNotFound) {
+		totals, err = &domain.CartTotals{CartID: cartID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cartUUID, err := domain.Bin16ToUUID(cart.CartID)
+	if err != nil {
+		return nil, err
+	}
+
+	itemResp := make([]gin.H, 0, len(items))
+	for _, it := range items {
+		itemResp = append(itemResp, gin.H{
+			"cart_item_id":     bin16String(it.CartItemID),
+			"sku":              it.SKU,
+			"variant_id":       it.VariantID,
+			"qty":              it.Qty,
+			"product_name":     it.ProductName,
+			"image_url":        it.ImageURL,
+			"currency":         it.Currency,
+			"unit_price_paise": it.UnitPricePaise,
+			"mrp_paise":        it.MRPPaise,
+			"tax_rate_bps":     it.TaxRateBps,
+			"product_meta":     it.ProductMeta,
+			"availability":     it.Availability,
+			"added_at":         it.AddedAt,
+			"updated_at":       it.UpdatedAt,
+
+			"list_currency":         it.ListCurrency,
+			"list_unit_price_paise": it.ListUnitPricePaise,
+			"fx_rate":               it.FXRate,
+			"fx_rate_at":            it.FXRateAt,
+		})
+	}
+
+	promoResp := make([]gin.H, 0, len(promos))
+	for _, p := range promos {
+		promoResp = append(promoResp, gin.H{
+			"cart_promo_id":  bin16String(p.CartPromoID),
+			"promo_code":     p.PromoCode,
+			"promo_type":     p.PromoType,
+			"discount_paise": p.DiscountPaise,
+			"promo_meta":     p.PromoMeta,
+			"status":         p.Status,
+			"applied_at":     p.AppliedAt,
+		})
+	}
+
+	minorUnits, _ := money.MinorUnits(cart.Currency)
+
+	return gin.H{
+		"cart": gin.H{
+			"cart_id":    cartUUID.String(),
+			"owner_type": cart.OwnerType,
+			"user_id":    bin16String(cart.UserID),
+			"guest_id":   cart.GuestID,
+			"channel":    cart.Channel,
+			"status":     cart.Status,
+			"currency":   cart.Currency,
+			"locale":     cart.Locale,
+			"version":    cart.Version,
+			"created_at": cart.CreatedAt,
+			"updated_at": cart.UpdatedAt,
+			"expires_at": cart.ExpiresAt,
+
+			"ship_pincode": cart.ShipPincode,
+		},
+		"items": itemResp,
+		"totals": gin.H{
+			// Amounts are in minor units of currency: 10^minor_units of them make one unit.
+			"currency":           cart.Currency,
+			"minor_units":        minorUnits,
+			"grand_total":        money.Format(totals.GrandTotalPaise, cart.Currency),
+			"subtotal_paise":     totals.SubtotalPaise,
+			"tax_paise":          totals.TaxPaise,
+			"shipping_paise":     totals.ShippingPaise,
+			"discount_paise":     totals.DiscountPaise,
+			"stored_value_paise": totals.StoredValuePaise,
+			"shipping_rule":      totals.ShippingRule,
+			"shipping_detail":    jsonOrNil(totals.ShippingMeta),
+			"grand_total_paise":  totals.GrandTotalPaise,
+			"pricing_version":    totals.PricingVersion,
+			"computed_at":        totals.ComputedAt,
+		},
+		"promotions": promoResp,
+	}, nil
+}
+
+// readCartView is loadCartView in a transaction of its own, so the cart and
+// its lines, promotions and totals are read together.
+func readCartView(ctx context.Context, store repo.Store, cartID []byte) (gin.H, error) {
+	var view gin.H
+	err := withTx(ctx, store, func(tx repo.Tx) error {
+		var err error
+		view, err = loadCartView(tx, cartID)
+		return err
+	})
+	return view, err
+}
+
+// jsonOrNil embeds a stored JSON column as-is, or null when empty.
+func jsonOrNil(s string) any {
+	if s == "" {
+		return nil
+	}
+	return json.RawMessage(s)
+}
+
+// bin16String renders a BINARY(16) column as a UUID string, or "" when unset.
+func bin16String(b []byte) string {
+	if len(b) != 16 {
+		return ""
+	}
+	u, err := domain.Bin16ToUUID(b)
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
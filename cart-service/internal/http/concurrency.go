@@ -0,0 +1,119 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Optimistic concurrency: the cart version is exposed as a strong ETag and
+// mutations may send it back in If-Match.
+
+const (
+	HETag        = "ETag"
+	HIfMatch     = "If-Match"
+	HIfNoneMatch = "If-None-Match"
+)
+
+// errCartPrecondition rolls back a mutation whose If-Match no longer matches
+// the cart version. Nothing (not even the idempotency row) is persisted, so
+// the client can refresh and retry with the same key.
+var errCartPrecondition = errors.New("cart version precondition failed")
+
+func cartETag(version int) string { return `"` + strconv.Itoa(version) + `"` }
+
+// parseIfMatch returns the expected cart version, or nil when the header is
+// absent or "*".
+func parseIfMatch(raw string) (*int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "*" {
+		return nil, nil
+	}
+	raw = strings.TrimPrefix(raw, "W/")
+	v, err := strconv.Atoi(strings.Trim(raw, `"`))
+	if err != nil {
+		return nil, errors.New("invalid If-Match header")
+	}
+	return &v, nil
+}
+
+// ifMatchFromRequest parses If-Match and answers 400 when it is malformed.
+func ifMatchFromRequest(c *gin.Context) (*int, bool) {
+	expected, err := parseIfMatch(c.GetHeader(HIfMatch))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return expected, true
+}
+
+// checkCartVersion fails with errCartPrecondition when the locked cart is not
+// at the version the client expects.
+func checkCartVersion(cart *domain.Cart, expected *int) error {
+	if expected != nil && *expected != cart.Version {
+		return errCartPrecondition
+	}
+	return nil
+}
+
+// bumpCartVersion saves the locked cart's status, destination and expiry and
+// increments its version.
+func bumpCartVersion(tx repo.Tx, cart *domain.Cart) error {
+	err := tx.Carts().Bump(cart)
+	if errors.Is(err, repo.ErrVersionConflict) {
+		return errCartPrecondition
+	}
+	return err
+}
+
+// respondPreconditionFailed answers 412 with the cart as it is now.
+func respondPreconditionFailed(c *gin.Context, store repo.Store, cartID []byte) {
+	view, err := readCartView(c.Request.Context(), store, cartID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	view["error"] = "cart has been modified; refresh and retry"
+	setCartETag(c, view)
+	c.JSON(http.StatusPreconditionFailed, view)
+}
+
+// setCartETag sets the ETag header when body is a cart view (fresh or replayed).
+func setCartETag(c *gin.Context, body any) {
+	if etag := cartViewETag(body); etag != "" {
+		c.Header(HETag, etag)
+	}
+}
+
+// cartViewETag returns the ETag of a cart view body, or "" for other bodies.
+func cartViewETag(body any) string {
+	m, ok := body.(map[string]any)
+	if !ok {
+		if h, isH := body.(gin.H); isH {
+			m = h
+		} else {
+			return ""
+		}
+	}
+	cart, ok := m["cart"].(map[string]any)
+	if !ok {
+		if h, isH := m["cart"].(gin.H); isH {
+			cart = h
+		} else {
+			return ""
+		}
+	}
+	switch v := cart["version"].(type) {
+	case int:
+		return cartETag(v)
+	case float64:
+		return cartETag(int(v))
+	}
+	return ""
+}
@@ -1,24 +1,61 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/catalog"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/checkout"
 	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/events"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/fx"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/history"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/lifecycle"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/money"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/pricing"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/promo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 type Handlers struct {
-	db *gorm.DB
+	store    repo.Store
+	promos   *promo.Engine
+	repricer *pricing.Repricer
+	ttl      lifecycle.TTL
+	checkout *checkout.Saga
+	products catalog.Port
+	rates    *fx.Rates
+	currency string
 }
 
-func NewHandlers(db *gorm.DB) *Handlers { return &Handlers{db: db} }
+func NewHandlers(store repo.Store, deps Deps) *Handlers {
+	repricer := pricing.NewRepricer(deps.Promotions, deps.Shipping, deps.FX)
+	products := deps.Catalog
+	if products == nil {
+		products = catalog.NewFile(nil)
+	}
+	currency := money.Normalize(deps.Currency)
+	if currency == "" {
+		currency = "INR"
+	}
+	return &Handlers{
+		store:    store,
+		promos:   deps.Promotions,
+		repricer: repricer,
+		ttl:      deps.CartTTL,
+		checkout: checkout.NewSaga(store, deps.Inventory, repricer, deps.Checkout),
+		products: products,
+		rates:    deps.FX,
+		currency: currency,
+	}
+}
 
 // ---- Requests ----
 
@@ -30,16 +67,17 @@ type CreateCartReq struct {
 	Currency  string `json:"currency"`
 }
 
+// AddItemReq names what to add; price, tax and product data come from the
+// catalog. The price fields exist only so a client still sending them gets a
+// 400 instead of silently being charged something else.
 type AddItemReq struct {
-	SKU            string `json:"sku" binding:"required"`
-	VariantID      string `json:"variant_id"`
-	Qty            int    `json:"qty" binding:"required,min=1,max=999"`
-	ProductName    string `json:"product_name"`
-	ImageURL       string `json:"image_url"`
+	SKU       string `json:"sku" binding:"required"`
+	VariantID string `json:"variant_id"`
+	Qty       int    `json:"qty" binding:"required,min=1,max=999"`
+
 	UnitPricePaise *int64 `json:"unit_price_paise"`
 	MRPPaise       *int64 `json:"mrp_paise"`
 	TaxRateBps     *int   `json:"tax_rate_bps"`
-	ProductMeta    any    `json:"product_meta"`
 }
 
 type UpdateQtyReq struct {
@@ -51,7 +89,7 @@ type ApplyPromotionReq struct {
 	PromoType string `json:"promo_type"` // COUPON/GIFT_CARD/WALLET
 }
 
-// ---- Handlers (minimal stubs) ----
+// ---- Handlers ----
 
 func (h *Handlers) CreateOrGetActiveCart(c *gin.Context) {
 	var req CreateCartReq
@@ -68,14 +106,18 @@ func (h *Handlers) CreateOrGetActiveCart(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "guest_id is required for GUEST owner_type"})
 		return
 	}
-
-	clientID := c.GetHeader(HClientID)
-	idemKey := c.GetHeader(HIdempotencyKey)
-	reqHash, err := domain.HashRequest(req)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+	req.Currency = money.Normalize(req.Currency)
+	// Carts hold one currency: an owner asking for another one than their
+	// ACTIVE cart's gets 409 rather than that cart.
+	currencyRequested := req.Currency != ""
+	if req.Currency == "" {
+		req.Currency = h.currency
+	}
+	if !money.Known(req.Currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported currency", "currency": req.Currency})
 		return
 	}
+
 	var userBin []byte
 	if req.OwnerType == "USER" {
 		userUUID, parseErr := uuid.Parse(req.UserID)
@@ -94,142 +136,101 @@ func (h *Handlers) CreateOrGetActiveCart(c *gin.Context) {
 	}
 
 	statusCode := http.StatusOK
-	var resp createCartResp
-
-	err = withTx(h.db, func(tx *gorm.DB) error {
-		var idemRow domain.CartIdempotency
-		idemErr := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("client_id=? AND idempotency_key=?", clientID, idemKey).
-			First(&idemRow).Error
-		if idemErr == nil {
-			if idemRow.RequestHash != reqHash {
-				statusCode = http.StatusConflict
-				resp = createCartResp{}
-				return nil
+	var respBody any
+
+	actor := history.Actor{ClientID: c.GetHeader(HClientID), IdempotencyKey: c.GetHeader(HIdempotencyKey)}
+
+	err := withTx(c.Request.Context(), h.store, func(tx repo.Tx) error {
+		carts := tx.Carts()
+		now := time.Now().UTC()
+		created := false
+		cart, findErr := carts.FindActive(req.OwnerType, userBin, req.GuestID, req.Channel)
+		if findErr == nil && lifecycle.IsExpired(cart, now) {
+			// The expiry job has not reached it yet: close it and start afresh.
+			locked, err := carts.Lock(cart.CartID)
+			if err != nil {
+				return err
 			}
-			if idemRow.State == "COMPLETED" && idemRow.ResponseBody != "" && idemRow.HTTPStatus != nil {
-				statusCode = int(*idemRow.HTTPStatus)
-				_ = json.Unmarshal([]byte(idemRow.ResponseBody), &resp)
-				return nil
+			if _, err := lifecycle.Expire(tx, locked, now, actor); err != nil {
+				return err
 			}
-		} else if !errors.Is(idemErr, gorm.ErrRecordNotFound) {
-			return idemErr
+			findErr = repo.ErrNotFound
 		}
-
-		if errors.Is(idemErr, gorm.ErrRecordNotFound) {
-			newIdem := domain.CartIdempotency{
-				ClientID:       clientID,
-				IdempotencyKey: idemKey,
-				Endpoint:       c.FullPath(),
-				RequestHash:    reqHash,
-				ResponseBody:   "{}",
-				State:          "IN_PROGRESS",
-				ExpiresAt:      idemExpire(24 * time.Hour),
-			}
-			if createErr := tx.Create(&newIdem).Error; createErr != nil {
-				refetchErr := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-					Where("client_id=? AND idempotency_key=?", clientID, idemKey).
-					First(&idemRow).Error
-				if refetchErr != nil {
-					return createErr
-				}
-				if idemRow.RequestHash != reqHash {
-					statusCode = http.StatusConflict
-					return nil
-				}
-				if idemRow.State == "COMPLETED" && idemRow.ResponseBody != "" && idemRow.HTTPStatus != nil {
-					statusCode = int(*idemRow.HTTPStatus)
-					_ = json.Unmarshal([]byte(idemRow.ResponseBody), &resp)
-					return nil
-				}
-			}
-		}
-
-		query := tx.Where("owner_type=? AND channel=? AND status='ACTIVE'", req.OwnerType, req.Channel)
-		if req.OwnerType == "USER" {
-			query = query.Where("user_id=?", userBin)
-		} else {
-			query = query.Where("guest_id=?", req.GuestID)
-		}
-
-		var cart domain.Cart
-		findErr := query.First(&cart).Error
 		if findErr != nil {
-			if !errors.Is(findErr, gorm.ErrRecordNotFound) {
+			if !errors.Is(findErr, repo.ErrNotFound) {
 				return findErr
 			}
 			cartUUID := uuid.New()
-			cart = domain.Cart{
+			cart = &domain.Cart{
 				CartID:    domain.UUIDToBin16(cartUUID),
 				OwnerType: req.OwnerType,
 				UserID:    userBin,
 				GuestID:   req.GuestID,
 				Channel:   req.Channel,
-				Status:    "ACTIVE",
+				Status:    domain.CartActive,
 				Currency:  req.Currency,
 				Version:   1,
+				ExpiresAt: h.ttl.ExpiresAt(req.OwnerType, now),
 			}
-			if createErr := tx.Create(&cart).Error; createErr != nil {
-				refetchErr := query.First(&cart).Error
+			createErr := carts.Create(cart)
+			if createErr != nil {
+				existing, refetchErr := carts.FindActive(req.OwnerType, userBin, req.GuestID, req.Channel)
 				if refetchErr != nil {
 					return createErr
 				}
+				cart = existing
 			}
+			created = createErr == nil
+		}
+		if currencyRequested && cart.Currency != req.Currency {
+			statusCode, respBody = http.StatusConflict, gin.H{
+				"error":    "owner already has an ACTIVE cart in another currency",
+				"cart_id":  bin16String(cart.CartID),
+				"currency": cart.Currency,
+			}
+			return completeClaim(c, tx, cart.CartID, statusCode, respBody)
 		}
 
-		cartTotals := domain.CartTotals{
-			CartID:          cart.CartID,
-			SubtotalPaise:   0,
-			TaxPaise:        0,
-			ShippingPaise:   0,
-			DiscountPaise:   0,
-			GrandTotalPaise: 0,
-			PricingVersion:  1,
+		_, err := tx.Totals().Get(cart.CartID)
+		if errors.Is(err, repo.ErrNotFound) {
+			err = tx.Totals().Save(&domain.CartTotals{
+				CartID:          cart.CartID,
+				SubtotalPaise:   0,
+				TaxPaise:        0,
+				ShippingPaise:   0,
+				DiscountPaise:   0,
+				GrandTotalPaise: 0,
+				PricingVersion:  domain.PricingVersion,
+			})
 		}
-		if err := tx.FirstOrCreate(&cartTotals, domain.CartTotals{CartID: cart.CartID}).Error; err != nil {
+		if err != nil {
 			return err
 		}
+		if created {
+			if err := history.Record(tx, cart.CartID, nil, history.Entry{Action: history.ActionCreated, Actor: actor}); err != nil {
+				return err
+			}
+		}
 
 		cartUUID, convErr := domain.Bin16ToUUID(cart.CartID)
 		if convErr != nil {
 			return convErr
 		}
-		resp = createCartResp{
+		respBody = createCartResp{
 			CartID:   cartUUID.String(),
-			Status:   cart.Status,
+			Status:   string(cart.Status),
 			Currency: cart.Currency,
 			Channel:  cart.Channel,
 		}
 
-		if statusCode != http.StatusOK {
-			return tx.Model(&domain.CartIdempotency{}).
-				Where("client_id=? AND idempotency_key=?", clientID, idemKey).
-				Updates(map[string]any{
-					"state":         "COMPLETED",
-					"http_status":   int16(statusCode),
-					"response_body": mustJSON(gin.H{"error": "idempotency key reused with different request payload"}),
-				}).Error
-		}
-
-		return tx.Model(&domain.CartIdempotency{}).
-			Where("client_id=? AND idempotency_key=?", clientID, idemKey).
-			Updates(map[string]any{
-				"resource_id":   cart.CartID,
-				"state":         "COMPLETED",
-				"http_status":   int16(http.StatusOK),
-				"response_body": mustJSON(resp),
-			}).Error
+		return completeClaim(c, tx, cart.CartID, statusCode, respBody)
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	if statusCode == http.StatusConflict {
-		c.JSON(http.StatusConflict, gin.H{"error": "idempotency key reused with different request payload"})
-		return
-	}
-	c.JSON(statusCode, resp)
+	c.JSON(statusCode, respBody)
 }
 
 func (h *Handlers) GetCart(c *gin.Context) {
@@ -238,9 +239,9 @@ func (h *Handlers) GetCart(c *gin.Context) {
 		return
 	}
 
-	var cart domain.Cart
-	if err := h.db.Where("cart_id = ?", cartID).First(&cart).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+	view, err := readCartView(c.Request.Context(), h.store, cartID)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "cart not found"})
 			return
 		}
@@ -248,333 +249,272 @@ func (h *Handlers) GetCart(c *gin.Context) {
 		return
 	}
 
-	var items []domain.CartItem
-	if err := h.db.Where("cart_id = ?", cartID).Order("added_at asc").Find(&items).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if cv, ok := view["cart"].(gin.H); ok {
+		status, _ := cv["status"].(domain.CartStatus)
+		expiresAt, _ := cv["expires_at"].(*time.Time)
+		if lifecycle.IsExpired(&domain.Cart{Status: status, ExpiresAt: expiresAt}, time.Now()) {
+			view["error"] = "cart has expired"
+			c.JSON(http.StatusGone, view)
+			return
+		}
+	}
+
+	setCartETag(c, view)
+	if inm := c.GetHeader(HIfNoneMatch); inm != "" && inm == c.Writer.Header().Get(HETag) {
+		c.Status(http.StatusNotModified)
 		return
 	}
+	c.JSON(http.StatusOK, view)
+}
 
-	var promos []domain.CartPromotion
-	if err := h.db.Where("cart_id = ?", cartID).Order("applied_at asc").Find(&promos).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+func (h *Handlers) AddItem(c *gin.Context) {
+	cartID, ok := parseBin16FromParam(c, "cartId")
+	if !ok {
 		return
 	}
 
-	totals := domain.CartTotals{CartID: cartID}
-	if err := h.db.Where("cart_id = ?", cartID).First(&totals).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var req AddItemReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	cartUUID, err := domain.Bin16ToUUID(cart.CartID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if req.UnitPricePaise != nil || req.MRPPaise != nil || req.TaxRateBps != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unit_price_paise, mrp_paise and tax_rate_bps are set by the catalog and must not be sent"})
 		return
 	}
 
-	userID := ""
-	if len(cart.UserID) == 16 {
-		if u, convErr := domain.Bin16ToUUID(cart.UserID); convErr == nil {
-			userID = u.String()
-		}
+	p, err := h.products.Lookup(c.Request.Context(), req.SKU, req.VariantID)
+	switch {
+	case errors.Is(err, catalog.ErrNotFound):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "unknown sku", "sku": req.SKU, "variant_id": req.VariantID})
+		return
+	case err != nil:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "catalog unavailable"})
+		return
+	case p.Availability == domain.AvailabilityOutOfStock:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "sku is out of stock", "sku": req.SKU, "variant_id": req.VariantID})
+		return
+	}
+	availability := p.Availability
+	if availability == "" {
+		availability = domain.AvailabilityInStock
+	}
+	productMeta := "{}"
+	if len(p.Meta) > 0 {
+		productMeta = string(p.Meta)
 	}
+	tax := p.TaxRateBps
 
-	itemResp := make([]gin.H, 0, len(items))
-	for _, it := range items {
-		itemID := ""
-		if len(it.CartItemID) == 16 {
-			if u, convErr := domain.Bin16ToUUID(it.CartItemID); convErr == nil {
-				itemID = u.String()
-			}
+	h.mutateCart(c, cartID, history.ActionItemAdded, func(tx repo.Tx, cart *domain.Cart) ([]cartEvent, *httpResult, error) {
+		price, res, err := h.priceInCartCurrency(tx, cart, p)
+		if res != nil || err != nil {
+			return nil, res, err
 		}
-		itemResp = append(itemResp, gin.H{
-			"cart_item_id":     itemID,
-			"sku":              it.SKU,
-			"variant_id":       it.VariantID,
-			"qty":              it.Qty,
-			"product_name":     it.ProductName,
-			"image_url":        it.ImageURL,
-			"currency":         it.Currency,
-			"unit_price_paise": it.UnitPricePaise,
-			"mrp_paise":        it.MRPPaise,
-			"tax_rate_bps":     it.TaxRateBps,
-			"product_meta":     it.ProductMeta,
-			"availability":     it.Availability,
-			"added_at":         it.AddedAt,
-			"updated_at":       it.UpdatedAt,
-		})
-	}
 
-	promoResp := make([]gin.H, 0, len(promos))
-	for _, p := range promos {
-		promoID := ""
-		if len(p.CartPromoID) == 16 {
-			if u, convErr := domain.Bin16ToUUID(p.CartPromoID); convErr == nil {
-				promoID = u.String()
+		item, err := tx.Items().Lock(cartID, req.SKU, req.VariantID)
+		switch {
+		case err == nil:
+			if item.Qty+req.Qty > maxLineQty {
+				return nil, &httpResult{http.StatusUnprocessableEntity, gin.H{"error": "line quantity cannot exceed 999"}}, nil
+			}
+			item.Qty += req.Qty
+			item.ProductName, item.ImageURL = p.Name, p.ImageURL
+			item.UnitPricePaise, item.MRPPaise, item.TaxRateBps = price.unit, price.mrp, &tax
+			item.ProductMeta, item.Availability = productMeta, availability
+			item.ListCurrency, item.ListUnitPricePaise, item.ListMRPPaise = p.Currency, &p.UnitPricePaise, p.MRPPaise
+			item.FXRate, item.FXRateAt = price.rate, price.rateAt
+			if err := tx.Items().Update(item); err != nil {
+				return nil, nil, err
+			}
+		case errors.Is(err, repo.ErrNotFound):
+			item = &domain.CartItem{
+				CartItemID:     domain.UUIDToBin16(uuid.New()),
+				CartID:         cartID,
+				SKU:            req.SKU,
+				VariantID:      req.VariantID,
+				Qty:            req.Qty,
+				ProductName:    p.Name,
+				ImageURL:       p.ImageURL,
+				Currency:       cart.Currency,
+				UnitPricePaise: price.unit,
+				MRPPaise:       price.mrp,
+				TaxRateBps:     &tax,
+				ProductMeta:    productMeta,
+				Availability:   availability,
+
+				ListCurrency:       p.Currency,
+				ListUnitPricePaise: &p.UnitPricePaise,
+				ListMRPPaise:       p.MRPPaise,
+				FXRate:             price.rate,
+				FXRateAt:           price.rateAt,
 			}
+			if err := tx.Items().Create(item); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, err
 		}
-		promoResp = append(promoResp, gin.H{
-			"cart_promo_id":  promoID,
-			"promo_code":     p.PromoCode,
-			"promo_type":     p.PromoType,
-			"discount_paise": p.DiscountPaise,
-			"promo_meta":     p.PromoMeta,
-			"status":         p.Status,
-			"applied_at":     p.AppliedAt,
-		})
-	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"cart": gin.H{
-			"cart_id":    cartUUID.String(),
-			"owner_type": cart.OwnerType,
-			"user_id":    userID,
-			"guest_id":   cart.GuestID,
-			"channel":    cart.Channel,
-			"status":     cart.Status,
-			"currency":   cart.Currency,
-			"locale":     cart.Locale,
-			"version":    cart.Version,
-			"created_at": cart.CreatedAt,
-			"updated_at": cart.UpdatedAt,
-			"expires_at": cart.ExpiresAt,
-		},
-		"items": itemResp,
-		"totals": gin.H{
-			"subtotal_paise":    totals.SubtotalPaise,
-			"tax_paise":         totals.TaxPaise,
-			"shipping_paise":    totals.ShippingPaise,
-			"discount_paise":    totals.DiscountPaise,
-			"grand_total_paise": totals.GrandTotalPaise,
-			"pricing_version":   totals.PricingVersion,
-			"computed_at":       totals.ComputedAt,
-		},
-		"promotions": promoResp,
+		return []cartEvent{{Type: events.CartItemAdded, Data: gin.H{
+			"cart_item_id":     bin16String(item.CartItemID),
+			"sku":              item.SKU,
+			"variant_id":       item.VariantID,
+			"qty_added":        req.Qty,
+			"qty":              item.Qty,
+			"unit_price_paise": item.UnitPricePaise,
+			"currency":         item.Currency,
+		}}}, nil, nil
 	})
 }
 
-func (h *Handlers) AddItem(c *gin.Context) {
-	var req AddItemReq
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// linePrice is a catalog price in the cart's currency, with the rate it was
+// converted at when the catalog sells in another currency.
+type linePrice struct {
+	unit, mrp *int64
+	rate      *string
+	rateAt    *time.Time
+}
+
+// priceInCartCurrency converts p's price into the cart's currency at the
+// current rate, or explains with a 422 why it cannot.
+func (h *Handlers) priceInCartCurrency(tx repo.Tx, cart *domain.Cart, p catalog.Product) (linePrice, *httpResult, error) {
+	unit := p.UnitPricePaise
+	lp := linePrice{unit: &unit, mrp: p.MRPPaise}
+	if p.Currency == cart.Currency {
+		return lp, nil, nil
+	}
+	if h.rates == nil || !money.Known(p.Currency) {
+		return linePrice{}, &httpResult{http.StatusUnprocessableEntity, gin.H{"error": "sku is not sold in the cart currency", "currency": p.Currency}}, nil
+	}
+
+	rate, err := h.rates.Lookup(tx.FXRates(), p.Currency, cart.Currency)
+	switch {
+	case errors.Is(err, fx.ErrNoRate), errors.Is(err, fx.ErrStaleRate):
+		return linePrice{}, &httpResult{http.StatusUnprocessableEntity, gin.H{
+			"error": "no current exchange rate", "from": p.Currency, "to": cart.Currency,
+		}}, nil
+	case err != nil:
+		return linePrice{}, nil, err
+	}
+	if unit, err = rate.Convert(p.UnitPricePaise); err != nil {
+		return linePrice{}, nil, err
 	}
-	// TODO: implement idempotent add + totals recompute + optional CartUpdated outbox
-	c.JSON(http.StatusOK, gin.H{"todo": "AddItem"})
+	if p.MRPPaise != nil {
+		mrp, err := rate.Convert(*p.MRPPaise)
+		if err != nil {
+			return linePrice{}, nil, err
+		}
+		lp.mrp = &mrp
+	}
+	r, at := rate.String(), rate.EffectiveAt
+	lp.rate, lp.rateAt = &r, &at
+	return lp, nil, nil
 }
 
 func (h *Handlers) UpdateQty(c *gin.Context) {
-	var req UpdateQtyReq
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	cartID, ok := parseBin16FromParam(c, "cartId")
+	if !ok {
 		return
 	}
-	// TODO: implement idempotent qty update + totals recompute
-	c.JSON(http.StatusOK, gin.H{"todo": "UpdateQty"})
-}
 
-func (h *Handlers) RemoveItem(c *gin.Context) {
-	// TODO: implement idempotent remove + totals recompute
-	c.JSON(http.StatusOK, gin.H{"todo": "RemoveItem"})
-}
-
-func (h *Handlers) ApplyPromotion(c *gin.Context) {
-	var req ApplyPromotionReq
+	var req UpdateQtyReq
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	// TODO: apply promo + totals recompute
-	c.JSON(http.StatusOK, gin.H{"todo": "ApplyPromotion"})
-}
 
-func (h *Handlers) Checkout(c *gin.Context) {
-	cartID := c.Param("cartId")
-	cartIDBin, ok := parseBin16FromParam(c, "cartId")
-	if !ok {
-		return
-	}
-	clientID := c.GetHeader("X-Client-Id")
-	idemKey := c.GetHeader("Idempotency-Key")
-
-	err := h.db.Transaction(func(tx *gorm.DB) error {
-		// 1) load cart + items + totals + promos (FOR UPDATE is ideal)
-		// 2) mark cart status = CHECKED_OUT
-		// 3) insert outbox event
-
-		ev := domain.EventEnvelope{
-			EventID:        uuid.NewString(),
-			EventType:      "CartCheckedOut.v1",
-			Producer:       "cart-service",
-			OccurredAt:     time.Now().UTC(),
-			CorrelationID:  cartID,
-			IdempotencyKey: idemKey,
-			Data: map[string]any{
-				"cart_id":   cartID,
-				"client_id": clientID,
-				// include items + totals snapshot here
-			},
+	sku := c.Param("sku")
+	variantID := c.Query("variant_id")
+
+	h.mutateCart(c, cartID, history.ActionItemQtyUpdated, func(tx repo.Tx, cart *domain.Cart) ([]cartEvent, *httpResult, error) {
+		item, err := tx.Items().Lock(cartID, sku, variantID)
+		if errors.Is(err, repo.ErrNotFound) {
+			return nil, &httpResult{http.StatusNotFound, gin.H{"error": "item not found in cart"}}, nil
 		}
-		payloadBytes, _ := json.Marshal(ev)
-
-		ob := domain.CartOutbox{
-			OutboxID:      domain.UUIDToBin16(uuid.New()),
-			AggregateType: "CART",
-			AggregateID:   cartIDBin, // BINARY(16) cart_id
-			EventType:     ev.EventType,
-			Payload:       string(payloadBytes),
-			Status:        "NEW",
+		if err != nil {
+			return nil, nil, err
 		}
-		if err := tx.Create(&ob).Error; err != nil {
-			return err
+
+		if item.Qty == req.Qty {
+			// Nothing changes, so the version and the clients' ETags stay.
+			view, err := loadCartView(tx, cartID)
+			if err != nil {
+				return nil, nil, err
+			}
+			return nil, &httpResult{http.StatusOK, view}, nil
 		}
-		return nil
-	})
 
-	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
-		return
-	}
-	c.JSON(200, gin.H{"status": "CHECKED_OUT"})
+		oldQty := item.Qty
+		item.Qty = req.Qty
+		if err := tx.Items().Update(item); err != nil {
+			return nil, nil, err
+		}
+
+		return []cartEvent{{Type: events.CartItemQtyUpdated, Data: gin.H{
+			"cart_item_id": bin16String(item.CartItemID),
+			"sku":          item.SKU,
+			"variant_id":   item.VariantID,
+			"old_qty":      oldQty,
+			"qty":          req.Qty,
+		}}}, nil, nil
+	})
 }
 
-func (h *Handlers) NOCheckout(c *gin.Context) {
+// RemoveItem deletes a cart line. With ?qty=N only N units are removed; the
+// line is deleted once no units remain.
+func (h *Handlers) RemoveItem(c *gin.Context) {
 	cartID, ok := parseBin16FromParam(c, "cartId")
 	if !ok {
 		return
 	}
 
-	clientID := c.GetHeader(HClientID)
-	idemKey := c.GetHeader(HIdempotencyKey)
-	reqHash, err := domain.HashRequest(struct {
-		CartID string `json:"cart_id"`
-	}{
-		CartID: c.Param("cartId"),
-	})
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
-		return
+	sku := c.Param("sku")
+	variantID := c.Query("variant_id")
+	removeQty := 0
+	if raw := c.Query("qty"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxLineQty {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "qty must be between 1 and 999"})
+			return
+		}
+		removeQty = n
 	}
 
-	statusCode := http.StatusOK
-	respBody := any(gin.H{"cart_id": c.Param("cartId"), "status": "CHECKED_OUT"})
-
-	err = withTx(h.db, func(tx *gorm.DB) error {
-		var idemRow domain.CartIdempotency
-		idemErr := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("client_id=? AND idempotency_key=?", clientID, idemKey).
-			First(&idemRow).Error
-		if idemErr == nil {
-			if idemRow.RequestHash != reqHash {
-				statusCode = http.StatusConflict
-				respBody = gin.H{"error": "idempotency key reused with different request payload"}
-				return nil
-			}
-			if idemRow.State == "COMPLETED" && idemRow.ResponseBody != "" && idemRow.HTTPStatus != nil {
-				statusCode = int(*idemRow.HTTPStatus)
-				var replay any
-				if unmarshalErr := json.Unmarshal([]byte(idemRow.ResponseBody), &replay); unmarshalErr == nil {
-					respBody = replay
-				}
-				return nil
-			}
-		} else if !errors.Is(idemErr, gorm.ErrRecordNotFound) {
-			return idemErr
+	h.mutateCart(c, cartID, history.ActionItemRemoved, func(tx repo.Tx, cart *domain.Cart) ([]cartEvent, *httpResult, error) {
+		item, err := tx.Items().Lock(cartID, sku, variantID)
+		if errors.Is(err, repo.ErrNotFound) {
+			return nil, &httpResult{http.StatusNotFound, gin.H{"error": "item not found in cart"}}, nil
 		}
-
-		if errors.Is(idemErr, gorm.ErrRecordNotFound) {
-			newIdem := domain.CartIdempotency{
-				ClientID:       clientID,
-				IdempotencyKey: idemKey,
-				Endpoint:       c.FullPath(),
-				RequestHash:    reqHash,
-				ResponseBody:   "{}",
-				State:          "IN_PROGRESS",
-				ExpiresAt:      idemExpire(24 * time.Hour),
-			}
-			if createErr := tx.Create(&newIdem).Error; createErr != nil {
-				refetchErr := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-					Where("client_id=? AND idempotency_key=?", clientID, idemKey).
-					First(&idemRow).Error
-				if refetchErr != nil {
-					return createErr
-				}
-				if idemRow.RequestHash != reqHash {
-					statusCode = http.StatusConflict
-					respBody = gin.H{"error": "idempotency key reused with different request payload"}
-					return nil
-				}
-				if idemRow.State == "COMPLETED" && idemRow.ResponseBody != "" && idemRow.HTTPStatus != nil {
-					statusCode = int(*idemRow.HTTPStatus)
-					var replay any
-					if unmarshalErr := json.Unmarshal([]byte(idemRow.ResponseBody), &replay); unmarshalErr == nil {
-						respBody = replay
-					}
-					return nil
-				}
-			}
+		if err != nil {
+			return nil, nil, err
 		}
 
-		var cart domain.Cart
-		cartErr := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("cart_id = ?", cartID).
-			First(&cart).Error
-		if errors.Is(cartErr, gorm.ErrRecordNotFound) {
-			statusCode = http.StatusNotFound
-			respBody = gin.H{"error": "cart not found"}
-		} else if cartErr != nil {
-			return cartErr
-		} else {
-			switch cart.Status {
-			case "ACTIVE":
-				updateErr := tx.Model(&domain.Cart{}).
-					Where("cart_id=? AND version=?", cartID, cart.Version).
-					Updates(map[string]any{
-						"status":  "CHECKED_OUT",
-						"version": cart.Version + 1,
-					}).Error
-				if updateErr != nil {
-					return updateErr
-				}
+		oldQty := item.Qty
+		newQty := 0
+		if removeQty > 0 && removeQty < oldQty {
+			newQty = oldQty - removeQty
+		}
 
-				outbox := domain.CartOutbox{
-					OutboxID:      domain.UUIDToBin16(uuid.New()),
-					AggregateType: "Cart",
-					AggregateID:   cartID,
-					EventType:     "CartCheckedOut",
-					Payload: mustJSON(gin.H{
-						"cart_id":        c.Param("cartId"),
-						"status":         "CHECKED_OUT",
-						"checked_out_at": time.Now().UTC().Format(time.RFC3339Nano),
-					}),
-					Status: "NEW",
-				}
-				if createErr := tx.Create(&outbox).Error; createErr != nil {
-					return createErr
-				}
-			case "CHECKED_OUT":
-				// Idempotent success for already checked out cart: no duplicate outbox event.
-			default:
-				statusCode = http.StatusConflict
-				respBody = gin.H{"error": "cart is not in ACTIVE state"}
+		data := gin.H{
+			"cart_item_id": bin16String(item.CartItemID),
+			"sku":          item.SKU,
+			"variant_id":   item.VariantID,
+			"old_qty":      oldQty,
+			"qty":          newQty,
+		}
+		if newQty == 0 {
+			if err := tx.Items().Delete(item.CartItemID); err != nil {
+				return nil, nil, err
 			}
+			return []cartEvent{{Type: events.CartItemRemoved, Data: data}}, nil, nil
 		}
-
-		return tx.Model(&domain.CartIdempotency{}).
-			Where("client_id=? AND idempotency_key=?", clientID, idemKey).
-			Updates(map[string]any{
-				"resource_id":   cartID,
-				"state":         "COMPLETED",
-				"http_status":   int16(statusCode),
-				"response_body": mustJSON(respBody),
-			}).Error
+		item.Qty = newQty
+		if err := tx.Items().Update(item); err != nil {
+			return nil, nil, err
+		}
+		return []cartEvent{{Type: events.CartItemQtyUpdated, Data: data}}, nil, nil
 	})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(statusCode, respBody)
 }
 
 // ---- helpers ----
@@ -594,10 +534,8 @@ func parseBin16FromParam(c *gin.Context, param string) ([]byte, bool) {
 	return domain.UUIDToBin16(u), true
 }
 
-// Example for idempotency expire
-func idemExpire(d time.Duration) time.Time { return time.Now().Add(d) }
-
-// Example: use tx.Transaction(...) in your real methods
-func withTx(db *gorm.DB, fn func(tx *gorm.DB) error) error {
-	return db.Transaction(fn)
+// withTx runs fn as one unit of work of store, abandoned when ctx (the
+// request's) is cancelled.
+func withTx(ctx context.Context, store repo.Store, fn func(tx repo.Tx) error) error {
+	return store.Transaction(ctx, fn)
 }
@@ -0,0 +1,223 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/checkout"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/history"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/lifecycle"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Checkout runs the checkout saga (see package checkout). Under the cart lock
+// it checks the cart can leave ACTIVE, refuses empty carts and lines already
+// known to be out of stock, moves the cart to CHECKOUT_PENDING and records the
+// saga; after commit it reserves inventory per line. The answer is
+//
+//   - 200 with the CHECKED_OUT cart once every line is reserved; the
+//     CartCheckedOut.v1 event carries every line, the applied promotions and
+//     the final totals so order-service can create the order without calling
+//     back;
+//   - 422 listing the lines inventory refused, or 503 when inventory could not
+//     be reached; reservations made are released and the cart is ACTIVE again;
+//   - 202 when the saga could not finish now; the worker resumes it and
+//     GET /v1/carts/:cartId/checkout shows where it is.
+func (h *Handlers) Checkout(c *gin.Context) {
+	cartID, ok := parseBin16FromParam(c, "cartId")
+	if !ok {
+		return
+	}
+	expectedVersion, ok := ifMatchFromRequest(c)
+	if !ok {
+		return
+	}
+	req := checkout.Request{
+		ClientID:       c.GetHeader(HClientID),
+		IdempotencyKey: c.GetHeader(HIdempotencyKey),
+		TraceParent:    traceParent(c),
+	}
+
+	statusCode := http.StatusOK
+	var respBody any
+	var saga *domain.CheckoutSaga
+
+	err := withTx(c.Request.Context(), h.store, func(tx repo.Tx) error {
+		finish := func(r *httpResult) error {
+			statusCode, respBody = r.status, r.body
+			return completeClaim(c, tx, cartID, statusCode, respBody)
+		}
+
+		cart, err := tx.Carts().Lock(cartID)
+		switch {
+		case errors.Is(err, repo.ErrNotFound):
+			return finish(&httpResult{http.StatusNotFound, gin.H{"error": "cart not found"}})
+		case err != nil:
+			return err
+		case lifecycle.IsExpired(cart, time.Now()):
+			return finish(&httpResult{http.StatusGone, gin.H{"error": "cart has expired"}})
+		}
+		before, err := history.Take(tx, cart)
+		if err != nil {
+			return err
+		}
+		if err := cart.Transition(domain.CartCheckoutPending); err != nil {
+			return finish(transitionConflict(err))
+		}
+		if err := checkCartVersion(cart, expectedVersion); err != nil {
+			return err
+		}
+
+		items, err := tx.Items().List(cartID)
+		if err != nil {
+			return err
+		}
+		if reject := checkoutReject(items); reject != nil {
+			return finish(reject)
+		}
+
+		if err := bumpCartVersion(tx, cart); err != nil {
+			return err
+		}
+		if saga, err = h.checkout.Start(tx, cart, items, req); err != nil {
+			return err
+		}
+		return history.Record(tx, cartID, before, history.Entry{
+			Action: history.ActionCheckoutStarted,
+			Actor:  history.Actor{ClientID: req.ClientID, IdempotencyKey: req.IdempotencyKey},
+		})
+	})
+	if errors.Is(err, errCartPrecondition) {
+		respondPreconditionFailed(c, h.store, cartID)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if saga != nil {
+		// Inventory calls must not stop half way because the client went
+		// away; compensation would otherwise be left to the worker.
+		ctx := context.WithoutCancel(c.Request.Context())
+		ended, runErr := h.checkout.Run(ctx, saga.SagaID, func(tx repo.Tx, s *domain.CheckoutSaga) error {
+			r, err := checkoutResult(tx, s)
+			if err != nil {
+				return err
+			}
+			statusCode, respBody = r.status, r.body
+			return completeClaim(c, tx, cartID, statusCode, respBody)
+		})
+		if runErr != nil {
+			log.Printf("checkout: saga %s: %v\n", bin16String(saga.SagaID), runErr)
+			if ended == nil {
+				ended = saga
+			}
+			statusCode, respBody = http.StatusAccepted, gin.H{"checkout": sagaView(ended)}
+			c.Header("Location", "/v1/carts/"+c.Param("cartId")+"/checkout")
+		}
+	}
+
+	setCartETag(c, respBody)
+	c.JSON(statusCode, respBody)
+}
+
+// GetCheckout shows the cart's latest checkout saga.
+func (h *Handlers) GetCheckout(c *gin.Context) {
+	cartID, ok := parseBin16FromParam(c, "cartId")
+	if !ok {
+		return
+	}
+	var saga *domain.CheckoutSaga
+	err := withTx(c.Request.Context(), h.store, func(tx repo.Tx) error {
+		var err error
+		saga, err = tx.Sagas().Latest(cartID)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no checkout for this cart"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"checkout": sagaView(saga)})
+}
+
+// checkoutResult is the response for a saga that ended.
+func checkoutResult(tx repo.Tx, saga *domain.CheckoutSaga) (*httpResult, error) {
+	if saga.Status == checkout.StatusCompleted {
+		view, err := loadCartView(tx, saga.CartID)
+		if err != nil {
+			return nil, err
+		}
+		view["checkout"] = sagaView(saga)
+		return &httpResult{http.StatusOK, view}, nil
+	}
+
+	if saga.FailureReason != checkout.ReasonOutOfStock {
+		return &httpResult{http.StatusServiceUnavailable, gin.H{
+			"error":    "inventory is unavailable; try again",
+			"checkout": sagaView(saga),
+		}}, nil
+	}
+	lines, _ := checkout.DecodeLines(saga.Lines)
+	var rejected []gin.H
+	for _, l := range lines {
+		if l.Status == checkout.LineRejected {
+			rejected = append(rejected, gin.H{"sku": l.SKU, "variant_id": l.VariantID, "qty": l.Qty, "error": l.Error})
+		}
+	}
+	return &httpResult{http.StatusUnprocessableEntity, gin.H{
+		"error":    "some items are out of stock",
+		"lines":    rejected,
+		"checkout": sagaView(saga),
+	}}, nil
+}
+
+func sagaView(saga *domain.CheckoutSaga) gin.H {
+	lines, _ := checkout.DecodeLines(saga.Lines)
+	return gin.H{
+		"saga_id":        bin16String(saga.SagaID),
+		"cart_id":        bin16String(saga.CartID),
+		"status":         saga.Status,
+		"failure_reason": saga.FailureReason,
+		"error":          saga.Error,
+		"lines":          lines,
+		"created_at":     saga.CreatedAt,
+		"updated_at":     saga.UpdatedAt,
+		"completed_at":   saga.CompletedAt,
+	}
+}
+
+// checkoutReject refuses carts that cannot become an order: empty ones and
+// ones with out of stock lines, which are listed so the client can fix them.
+func checkoutReject(items []domain.CartItem) *httpResult {
+	if len(items) == 0 {
+		return &httpResult{http.StatusUnprocessableEntity, gin.H{"error": "cart is empty"}}
+	}
+	var unavailable []gin.H
+	for _, it := range items {
+		if it.Availability == domain.AvailabilityOutOfStock {
+			unavailable = append(unavailable, gin.H{
+				"sku":          it.SKU,
+				"variant_id":   it.VariantID,
+				"availability": it.Availability,
+			})
+		}
+	}
+	if len(unavailable) > 0 {
+		return &httpResult{http.StatusUnprocessableEntity, gin.H{
+			"error": "some items are out of stock",
+			"lines": unavailable,
+		}}
+	}
+	return nil
+}
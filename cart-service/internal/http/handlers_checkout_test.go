@@ -0,0 +1,42 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckoutReject(t *testing.T) {
+	in := domain.CartItem{SKU: "A", Availability: domain.AvailabilityInStock}
+	low := domain.CartItem{SKU: "B", Availability: domain.AvailabilityLowStock}
+	out := domain.CartItem{SKU: "C", VariantID: "red", Availability: domain.AvailabilityOutOfStock}
+
+	tests := []struct {
+		name   string
+		items  []domain.CartItem
+		status int
+		lines  int
+	}{
+		{"empty", nil, http.StatusUnprocessableEntity, 0},
+		{"in stock", []domain.CartItem{in, low}, 0, 0},
+		{"out of stock", []domain.CartItem{in, out, low}, http.StatusUnprocessableEntity, 1},
+	}
+	for _, tc := range tests {
+		r := checkoutReject(tc.items)
+		if tc.status == 0 {
+			if r != nil {
+				t.Errorf("%s: unexpected reject %v", tc.name, r.body)
+			}
+			continue
+		}
+		if r == nil || r.status != tc.status {
+			t.Fatalf("%s: got %+v, want status %d", tc.name, r, tc.status)
+		}
+		if lines, _ := r.body.(gin.H)["lines"].([]gin.H); len(lines) != tc.lines {
+			t.Errorf("%s: lines = %v, want %d", tc.name, lines, tc.lines)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// GetHistory lists a cart's history, oldest first. Pages hold up to ?limit
+// entries; pass next_cursor back as ?cursor for the next page. Carts that
+// were merged, checked out or expired keep their history.
+func (h *Handlers) GetHistory(c *gin.Context) {
+	cartID, ok := parseBin16FromParam(c, "cartId")
+	if !ok {
+		return
+	}
+	limit := defaultHistoryLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxHistoryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+			return
+		}
+		limit = n
+	}
+	var after uint64
+	if raw := c.Query("cursor"); raw != "" {
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		after = n
+	}
+
+	var entries []domain.CartHistory
+	err := withTx(c.Request.Context(), h.store, func(tx repo.Tx) error {
+		if _, err := tx.Carts().Get(cartID); err != nil {
+			return err
+		}
+		var err error
+		// One extra entry tells whether there is another page.
+		entries, err = tx.History().List(cartID, after, limit+1)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "cart not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var next any
+	if len(entries) > limit {
+		entries = entries[:limit]
+		next = strconv.FormatUint(entries[limit-1].Seq, 10)
+	}
+	out := make([]gin.H, 0, len(entries))
+	for i := range entries {
+		out = append(out, historyView(&entries[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"cart_id":     bin16String(cartID),
+		"entries":     out,
+		"next_cursor": next,
+	})
+}
+
+func historyView(e *domain.CartHistory) gin.H {
+	return gin.H{
+		"entry_id":                 bin16String(e.EntryID),
+		"cart_version":             e.CartVersion,
+		"action":                   e.Action,
+		"actor":                    e.Actor,
+		"idempotency_key":          e.IdempotencyKey,
+		"related_cart_id":          bin16String(e.RelatedCartID),
+		"status_before":            e.StatusBefore,
+		"status_after":             e.StatusAfter,
+		"grand_total_before_paise": e.GrandTotalBeforePaise,
+		"grand_total_after_paise":  e.GrandTotalAfterPaise,
+		"changes":                  jsonOrNil(e.Changes),
+		"created_at":               e.CreatedAt,
+	}
+}
@@ -0,0 +1,342 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/events"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/history"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/lifecycle"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/promo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type MergeCartReq struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+// MergeCart folds the GUEST cart :cartId into the user's ACTIVE cart for the
+// same channel (creating one if needed) when the shopper signs in.
+//
+//   - Lines of the same SKU and variant combine, capped at 999 per line.
+//   - Coupons move over and are re-validated by the repricer; gift card and
+//     wallet holds on the guest cart are released (balances belong to an
+//     account, not a cart, and can be re-applied).
+//   - The guest cart ends MERGED; CartMerged.v1 is emitted on the user cart.
+//
+// If-Match, when sent, is checked against the guest cart. The response is the
+// user cart view with its ETag.
+func (h *Handlers) MergeCart(c *gin.Context) {
+	guestID, ok := parseBin16FromParam(c, "cartId")
+	if !ok {
+		return
+	}
+	expectedVersion, ok := ifMatchFromRequest(c)
+	if !ok {
+		return
+	}
+
+	var req MergeCartReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userUUID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	userBin := domain.UUIDToBin16(userUUID)
+	idemKey := c.GetHeader(HIdempotencyKey)
+	trace := traceParent(c)
+	actor := history.Actor{ClientID: c.GetHeader(HClientID), IdempotencyKey: idemKey}
+
+	statusCode := http.StatusOK
+	var respBody any
+
+	err = withTx(c.Request.Context(), h.store, func(tx repo.Tx) error {
+		now := time.Now().UTC()
+		finish := func(r *httpResult, resourceID []byte) error {
+			statusCode, respBody = r.status, r.body
+			return completeClaim(c, tx, resourceID, statusCode, respBody)
+		}
+
+		guest, err := tx.Carts().Get(guestID)
+		if err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				return finish(&httpResult{http.StatusNotFound, gin.H{"error": "cart not found"}}, guestID)
+			}
+			return err
+		}
+		if guest.OwnerType != "GUEST" {
+			return finish(&httpResult{http.StatusConflict, gin.H{"error": "only GUEST carts can be merged"}}, guestID)
+		}
+		// Refuse a guest cart that cannot merge before userCartFor creates or
+		// expires a user cart: rejections commit, to record the response for
+		// retries. The checks are repeated under the lock below.
+		if lifecycle.IsExpired(guest, now) {
+			return finish(&httpResult{http.StatusGone, gin.H{"error": "cart has expired"}}, guestID)
+		}
+		if !guest.Status.CanTransition(domain.CartMerged) {
+			return finish(transitionConflict(&domain.TransitionError{From: guest.Status, To: domain.CartMerged}), guestID)
+		}
+
+		user, err := h.userCartFor(tx, userBin, guest, now, actor)
+		if err != nil {
+			return err
+		}
+
+		// Lock both carts in cart_id order so two merges (or a merge and a
+		// mutation of either cart) cannot deadlock.
+		first, second := guestID, user.CartID
+		if bytes.Compare(first, second) > 0 {
+			first, second = second, first
+		}
+		locked := map[string]*domain.Cart{}
+		for _, id := range [][]byte{first, second} {
+			cart, err := tx.Carts().Lock(id)
+			if err != nil {
+				return err
+			}
+			locked[string(id)] = cart
+		}
+		g, u := locked[string(guestID)], locked[string(user.CartID)]
+		guestBefore, err := history.Take(tx, g)
+		if err != nil {
+			return err
+		}
+		userBefore, err := history.Take(tx, u)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case lifecycle.IsExpired(g, now):
+			return finish(&httpResult{http.StatusGone, gin.H{"error": "cart has expired"}}, guestID)
+		case g.Currency != u.Currency:
+			return finish(&httpResult{http.StatusConflict, gin.H{"error": "carts use different currencies"}}, guestID)
+		}
+		if err := g.Transition(domain.CartMerged); err != nil {
+			return finish(transitionConflict(err), guestID)
+		}
+		// The user cart may have been checked out or expired between the
+		// lookup and the lock.
+		if err := u.Edit(); err != nil {
+			return finish(transitionConflict(err), guestID)
+		}
+		if err := checkCartVersion(g, expectedVersion); err != nil {
+			return err
+		}
+
+		lines, err := mergeItems(tx, g, u)
+		if err != nil {
+			return err
+		}
+		coupons, err := mergeCoupons(tx, h.promos, g, u)
+		if err != nil {
+			return err
+		}
+		if err := lifecycle.ReleaseStoredValue(tx, g.CartID, "cart merged"); err != nil {
+			return err
+		}
+
+		if err := bumpCartVersion(tx, g); err != nil {
+			return err
+		}
+		u.ExpiresAt = h.ttl.ExpiresAt(u.OwnerType, now)
+		if err := bumpCartVersion(tx, u); err != nil {
+			return err
+		}
+		if err := h.repricer.Recompute(tx, u); err != nil {
+			return err
+		}
+		if err := history.Record(tx, g.CartID, guestBefore, history.Entry{
+			Action: history.ActionMergedInto, Actor: actor, RelatedCartID: u.CartID,
+		}); err != nil {
+			return err
+		}
+		if err := history.Record(tx, u.CartID, userBefore, history.Entry{
+			Action: history.ActionMergedFrom, Actor: actor, RelatedCartID: g.CartID,
+		}); err != nil {
+			return err
+		}
+
+		if err := enqueueEvent(tx, u.CartID, events.CartMerged, idemKey, trace, gin.H{
+			"cart_id":             bin16String(u.CartID),
+			"cart_version":        u.Version,
+			"user_id":             userUUID.String(),
+			"source_cart_id":      bin16String(g.CartID),
+			"source_cart_version": g.Version,
+			"guest_id":            g.GuestID,
+			"lines":               lines,
+			"coupons_moved":       coupons,
+		}); err != nil {
+			return err
+		}
+
+		view, err := loadCartView(tx, u.CartID)
+		if err != nil {
+			return err
+		}
+		return finish(&httpResult{http.StatusOK, view}, u.CartID)
+	})
+	if errors.Is(err, errCartPrecondition) {
+		respondPreconditionFailed(c, h.store, guestID)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	setCartETag(c, respBody)
+	c.JSON(statusCode, respBody)
+}
+
+// userCartFor finds the user's ACTIVE cart for the guest cart's channel,
+// closing it first if it already expired, and creates one when there is none.
+func (h *Handlers) userCartFor(tx repo.Tx, userBin []byte, guest *domain.Cart, now time.Time, actor history.Actor) (*domain.Cart, error) {
+	cart, err := tx.Carts().FindActive("USER", userBin, "", guest.Channel)
+	if err == nil && lifecycle.IsExpired(cart, now) {
+		locked, lockErr := tx.Carts().Lock(cart.CartID)
+		if lockErr != nil {
+			return nil, lockErr
+		}
+		if _, expErr := lifecycle.Expire(tx, locked, now, actor); expErr != nil {
+			return nil, expErr
+		}
+		err = repo.ErrNotFound
+	}
+	if err == nil {
+		return cart, nil
+	}
+	if !errors.Is(err, repo.ErrNotFound) {
+		return nil, err
+	}
+
+	cart = &domain.Cart{
+		CartID:    domain.UUIDToBin16(uuid.New()),
+		OwnerType: "USER",
+		UserID:    userBin,
+		Channel:   guest.Channel,
+		Status:    domain.CartActive,
+		Currency:  guest.Currency,
+		Locale:    guest.Locale,
+		Version:   1,
+		ExpiresAt: h.ttl.ExpiresAt("USER", now),
+
+		ShipPincode: guest.ShipPincode,
+	}
+	if err := tx.Carts().Create(cart); err != nil {
+		return nil, err
+	}
+	if err := history.Record(tx, cart.CartID, nil, history.Entry{Action: history.ActionCreated, Actor: actor}); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// mergeItems copies the guest lines into the user cart, adding quantities of
+// lines both carts hold. It returns one entry per guest line.
+func mergeItems(tx repo.Tx, guest, user *domain.Cart) ([]gin.H, error) {
+	items, err := tx.Items().List(guest.CartID)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]gin.H, 0, len(items))
+	for _, gi := range items {
+		line := gin.H{"sku": gi.SKU, "variant_id": gi.VariantID, "guest_qty": gi.Qty}
+
+		ui, err := tx.Items().Lock(user.CartID, gi.SKU, gi.VariantID)
+		switch {
+		case err == nil:
+			qty := min(ui.Qty+gi.Qty, maxLineQty)
+			line["user_qty"], line["qty"], line["capped"] = ui.Qty, qty, ui.Qty+gi.Qty > maxLineQty
+			ui.Qty = qty
+			if err := tx.Items().Update(ui); err != nil {
+				return nil, err
+			}
+		case errors.Is(err, repo.ErrNotFound):
+			moved := gi
+			moved.CartItemID = domain.UUIDToBin16(uuid.New())
+			moved.CartID = user.CartID
+			if err := tx.Items().Create(&moved); err != nil {
+				return nil, err
+			}
+			line["user_qty"], line["qty"], line["capped"] = 0, gi.Qty, false
+		default:
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// mergeCoupons adds the guest cart's live coupons to the user cart unless it
+// already carries them. Usage by the guest says nothing about the user, so a
+// coupon the user has already used up arrives SUSPENDED. The repricer then
+// re-validates every coupon, so ones the merged cart does not qualify for end
+// up SUSPENDED too.
+func mergeCoupons(tx repo.Tx, promos *promo.Engine, guest, user *domain.Cart) ([]string, error) {
+	live := func(code string) repo.PromotionFilter {
+		return repo.PromotionFilter{
+			Types:    []string{domain.PromoTypeCoupon},
+			Code:     code,
+			Statuses: []string{"APPLIED", "SUSPENDED"},
+		}
+	}
+	coupons, err := tx.Promotions().List(guest.CartID, live(""))
+	if err != nil {
+		return nil, err
+	}
+
+	var moved []string
+	for _, p := range coupons {
+		held, err := tx.Promotions().List(user.CartID, live(p.PromoCode))
+		if err != nil {
+			return nil, err
+		}
+		if len(held) > 0 {
+			continue
+		}
+		p.CartPromoID = domain.UUIDToBin16(uuid.New())
+		p.CartID = user.CartID
+		if rule, ok := limitedRule(promos, p.PromoCode); ok {
+			uses, err := tx.Promotions().CountRedeemed(p.PromoCode, user)
+			if err != nil {
+				return nil, err
+			}
+			if uses >= rule.PerUserLimit {
+				meta := promo.Meta{Kind: rule.Kind, SuspendReason: promo.ReasonUsageLimitReached}
+				p.Status, p.DiscountPaise, p.PromoMeta = "SUSPENDED", 0, meta.JSON()
+			}
+		}
+		if err := tx.Promotions().Create(&p); err != nil {
+			return nil, err
+		}
+		moved = append(moved, p.PromoCode)
+	}
+	for _, p := range coupons {
+		p.Status, p.DiscountPaise = "REMOVED", 0
+		if err := tx.Promotions().Update(&p); err != nil {
+			return nil, err
+		}
+	}
+	return moved, nil
+}
+
+// limitedRule returns the rule for code when it has a per-user limit.
+func limitedRule(promos *promo.Engine, code string) (promo.Rule, bool) {
+	if promos == nil {
+		return promo.Rule{}, false
+	}
+	rule, ok := promos.Rule(code)
+	return rule, ok && rule.PerUserLimit > 0
+}
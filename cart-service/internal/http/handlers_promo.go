@@ -0,0 +1,216 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/events"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/history"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/promo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/storedvalue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func (h *Handlers) ApplyPromotion(c *gin.Context) {
+	cartID, ok := parseBin16FromParam(c, "cartId")
+	if !ok {
+		return
+	}
+
+	var req ApplyPromotionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.PromoCode = promo.NormalizeCode(req.PromoCode)
+	req.PromoType = strings.ToUpper(strings.TrimSpace(req.PromoType))
+	if req.PromoType == "" {
+		req.PromoType = domain.PromoTypeCoupon
+	}
+
+	h.mutateCart(c, cartID, history.ActionPromotionApplied, func(tx repo.Tx, cart *domain.Cart) ([]cartEvent, *httpResult, error) {
+		if domain.IsStoredValue(req.PromoType) {
+			return h.applyStoredValue(tx, cart, req)
+		}
+		if req.PromoType != domain.PromoTypeCoupon {
+			return nil, promoRejected(req.PromoCode, "UNSUPPORTED_PROMO_TYPE"), nil
+		}
+		if h.promos == nil {
+			return nil, promoRejected(req.PromoCode, promo.ReasonNotFound), nil
+		}
+
+		items, err := tx.Items().List(cartID)
+		if err != nil {
+			return nil, nil, err
+		}
+		promos, err := tx.Promotions().List(cartID, repo.PromotionFilter{Types: []string{domain.PromoTypeCoupon}})
+		if err != nil {
+			return nil, nil, err
+		}
+		uses, err := tx.Promotions().CountRedeemed(req.PromoCode, cart)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		res := h.promos.Evaluate(promo.Input{
+			Code:     req.PromoCode,
+			Now:      time.Now().UTC(),
+			Currency: cart.Currency,
+			Items:    items,
+			Others:   promo.Others(promos, nil),
+			UserUses: uses,
+		})
+		if !res.Accepted {
+			return nil, promoRejected(req.PromoCode, res.Reason), nil
+		}
+
+		row := domain.CartPromotion{
+			CartPromoID:   domain.UUIDToBin16(uuid.New()),
+			CartID:        cartID,
+			PromoCode:     req.PromoCode,
+			PromoType:     req.PromoType,
+			DiscountPaise: res.DiscountPaise,
+			PromoMeta:     res.Meta.JSON(),
+			Status:        "APPLIED",
+		}
+		if err := tx.Promotions().Create(&row); err != nil {
+			return nil, nil, err
+		}
+
+		return []cartEvent{{Type: events.CartPromotionApplied, Data: gin.H{
+			"cart_promo_id":  bin16String(row.CartPromoID),
+			"promo_code":     row.PromoCode,
+			"promo_type":     row.PromoType,
+			"discount_paise": row.DiscountPaise,
+		}}}, nil, nil
+	})
+}
+
+// applyStoredValue places a hold on a gift card or wallet for whatever is
+// still payable on the cart and records it as a cart promotion.
+func (h *Handlers) applyStoredValue(tx repo.Tx, cart *domain.Cart, req ApplyPromotionReq) ([]cartEvent, *httpResult, error) {
+	var acc *domain.StoredValueAccount
+	var err error
+	if req.PromoType == domain.PromoTypeWallet {
+		if cart.OwnerType != "USER" {
+			return nil, promoRejected(req.PromoCode, "WALLET_REQUIRES_USER"), nil
+		}
+		req.PromoCode = domain.PromoTypeWallet
+		acc, err = storedvalue.LockWallet(tx.StoredValue(), cart.UserID)
+	} else {
+		acc, err = storedvalue.LockGiftCard(tx.StoredValue(), req.PromoCode)
+	}
+	if reason := storedvalue.Reason(err); reason != "" {
+		return nil, promoRejected(req.PromoCode, promo.Reason(reason)), nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existing, err := tx.Promotions().List(cart.CartID, repo.PromotionFilter{
+		Types:    []string{req.PromoType},
+		Code:     req.PromoCode,
+		Statuses: []string{"APPLIED"},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(existing) > 0 {
+		return nil, promoRejected(req.PromoCode, promo.ReasonAlreadyApplied), nil
+	}
+
+	priced, err := h.repricer.Price(tx, cart)
+	if err != nil {
+		return nil, nil, err
+	}
+	if priced.Summary.GrandTotalPaise <= 0 {
+		return nil, promoRejected(req.PromoCode, "NOTHING_TO_PAY"), nil
+	}
+
+	promoID := domain.UUIDToBin16(uuid.New())
+	hold, err := storedvalue.Hold(tx.StoredValue(), acc, cart, promoID, priced.Summary.GrandTotalPaise, time.Now().UTC())
+	if reason := storedvalue.Reason(err); reason != "" {
+		return nil, promoRejected(req.PromoCode, promo.Reason(reason)), nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	row := domain.CartPromotion{
+		CartPromoID:   promoID,
+		CartID:        cart.CartID,
+		PromoCode:     req.PromoCode,
+		PromoType:     req.PromoType,
+		DiscountPaise: hold.AmountPaise,
+		PromoMeta: mustJSON(gin.H{
+			"account_id": bin16String(acc.AccountID),
+			"hold_id":    bin16String(hold.HoldID),
+		}),
+		Status: "APPLIED",
+	}
+	if err := tx.Promotions().Create(&row); err != nil {
+		return nil, nil, err
+	}
+
+	return []cartEvent{{Type: events.CartPromotionApplied, Data: gin.H{
+		"cart_promo_id":  bin16String(row.CartPromoID),
+		"promo_code":     row.PromoCode,
+		"promo_type":     row.PromoType,
+		"discount_paise": row.DiscountPaise,
+	}}}, nil, nil
+}
+
+// RemovePromotion takes a coupon, gift card or wallet off the cart and
+// releases any balance held for it.
+func (h *Handlers) RemovePromotion(c *gin.Context) {
+	cartID, ok := parseBin16FromParam(c, "cartId")
+	if !ok {
+		return
+	}
+	code := promo.NormalizeCode(c.Param("code"))
+
+	h.mutateCart(c, cartID, history.ActionPromotionRemoved, func(tx repo.Tx, cart *domain.Cart) ([]cartEvent, *httpResult, error) {
+		rows, err := tx.Promotions().List(cartID, repo.PromotionFilter{
+			Code:     code,
+			Statuses: []string{"APPLIED", "SUSPENDED"},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rows) == 0 {
+			return nil, &httpResult{http.StatusNotFound, gin.H{"error": "promotion not applied to cart"}}, nil
+		}
+
+		out := make([]cartEvent, 0, len(rows))
+		for _, p := range rows {
+			if domain.IsStoredValue(p.PromoType) {
+				if err := storedvalue.ReleasePromotion(tx.StoredValue(), p.CartPromoID, "promotion removed"); err != nil {
+					return nil, nil, err
+				}
+			}
+			p.Status, p.DiscountPaise = "REMOVED", 0
+			if err := tx.Promotions().Update(&p); err != nil {
+				return nil, nil, err
+			}
+			out = append(out, cartEvent{Type: events.CartPromotionRemoved, Data: gin.H{
+				"cart_promo_id": bin16String(p.CartPromoID),
+				"promo_code":    p.PromoCode,
+				"promo_type":    p.PromoType,
+			}})
+		}
+		return out, nil, nil
+	})
+}
+
+func promoRejected(code string, reason promo.Reason) *httpResult {
+	return &httpResult{http.StatusUnprocessableEntity, gin.H{
+		"error":      "promotion rejected",
+		"reason":     reason,
+		"promo_code": code,
+	}}
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/events"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/history"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+
+	"github.com/gin-gonic/gin"
+)
+
+var pincodeRe = regexp.MustCompile(`^[1-9][0-9]{5}$`)
+
+type SetDestinationReq struct {
+	Pincode string `json:"pincode" binding:"required"`
+}
+
+// SetDestination records the delivery pincode used to quote shipping.
+func (h *Handlers) SetDestination(c *gin.Context) {
+	cartID, ok := parseBin16FromParam(c, "cartId")
+	if !ok {
+		return
+	}
+
+	var req SetDestinationReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !pincodeRe.MatchString(req.Pincode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pincode must be 6 digits"})
+		return
+	}
+
+	h.mutateCart(c, cartID, history.ActionDestinationSet, func(tx repo.Tx, cart *domain.Cart) ([]cartEvent, *httpResult, error) {
+		// Saved with the version bump that follows.
+		cart.ShipPincode = req.Pincode
+		return []cartEvent{{Type: events.CartDestinationSet, Data: gin.H{
+			"pincode": req.Pincode,
+		}}}, nil, nil
+	})
+}
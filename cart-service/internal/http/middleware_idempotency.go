@@ -1,24 +1,20 @@
 package http
 
 import (
-	"net/http"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/idempotency"
 
 	"github.com/gin-gonic/gin"
 )
 
 const (
-	HClientID       = "X-Client-Id"
-	HIdempotencyKey = "Idempotency-Key"
+	HClientID       = idempotency.HeaderClientID
+	HIdempotencyKey = idempotency.HeaderIdempotencyKey
 )
 
-func RequireIdempotencyHeaders() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		if c.GetHeader(HClientID) == "" || c.GetHeader(HIdempotencyKey) == "" {
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-				"error": "missing X-Client-Id or Idempotency-Key",
-			})
-			return
-		}
-		c.Next()
-	}
+// Idempotent makes a route replay its first response for retries that carry
+// the same X-Client-Id and Idempotency-Key, for ttl (24h when zero).
+func Idempotent(store *idempotency.Store, ttl time.Duration) gin.HandlerFunc {
+	return idempotency.Middleware(store, idempotency.Options{TTL: ttl})
 }
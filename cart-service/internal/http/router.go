@@ -1,27 +1,57 @@
 package http
 
 import (
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/catalog"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/checkout"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/fx"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/idempotency"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/inventory"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/lifecycle"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/promo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/shipping"
+
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
-func NewRouter(db *gorm.DB) *gin.Engine {
+// Deps are the collaborators the HTTP layer needs besides the store.
+type Deps struct {
+	Promotions     *promo.Engine
+	Shipping       *shipping.Calculator // nil means shipping is free
+	CartTTL        lifecycle.TTL        // zero means carts never expire
+	Currency       string               // for carts created without one; empty means INR
+	FX             *fx.Rates            // nil means items must be priced in the cart's currency
+	Inventory      inventory.Port       // nil means stock is not reserved at checkout
+	Catalog        catalog.Port         // nil means no SKU can be added
+	Checkout       checkout.Options
+	IdempotencyTTL time.Duration // how long retried keys replay; zero means 24h
+}
+
+func NewRouter(store repo.Store, deps Deps) *gin.Engine {
 	r := gin.New()
 	r.Use(gin.Recovery())
 
-	h := NewHandlers(db)
+	h := NewHandlers(store, deps)
+	idem := Idempotent(idempotency.NewStore(store), deps.IdempotencyTTL)
 
 	v1 := r.Group("/v1")
 	{
-		v1.POST("/carts", RequireIdempotencyHeaders(), h.CreateOrGetActiveCart)
+		v1.POST("/carts", idem, h.CreateOrGetActiveCart)
 		v1.GET("/carts/:cartId", h.GetCart)
 
-		v1.POST("/carts/:cartId/items", RequireIdempotencyHeaders(), h.AddItem)
-		v1.PATCH("/carts/:cartId/items/:sku", RequireIdempotencyHeaders(), h.UpdateQty)
-		v1.DELETE("/carts/:cartId/items/:sku", RequireIdempotencyHeaders(), h.RemoveItem)
+		v1.POST("/carts/:cartId/items", idem, h.AddItem)
+		v1.PATCH("/carts/:cartId/items/:sku", idem, h.UpdateQty)
+		v1.DELETE("/carts/:cartId/items/:sku", idem, h.RemoveItem)
 
-		v1.POST("/carts/:cartId/promotions", RequireIdempotencyHeaders(), h.ApplyPromotion)
-		v1.POST("/carts/:cartId/checkout", RequireIdempotencyHeaders(), h.Checkout)
+		v1.POST("/carts/:cartId/promotions", idem, h.ApplyPromotion)
+		v1.DELETE("/carts/:cartId/promotions/:code", idem, h.RemovePromotion)
+		v1.PUT("/carts/:cartId/destination", idem, h.SetDestination)
+		v1.POST("/carts/:cartId/merge", idem, h.MergeCart)
+		v1.POST("/carts/:cartId/checkout", idem, h.Checkout)
+		v1.GET("/carts/:cartId/checkout", h.GetCheckout)
+		v1.GET("/carts/:cartId/history", h.GetHistory)
 	}
 
 	return r
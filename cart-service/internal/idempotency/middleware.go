@@ -0,0 +1,203 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HeaderClientID       = "X-Client-Id"
+	HeaderIdempotencyKey = "Idempotency-Key"
+	HeaderReplayed       = "Idempotent-Replayed"
+
+	claimKey = "idempotency.claim"
+
+	// finishTimeout bounds recording or forgetting a claim once the handler
+	// returned, even when the request was cancelled meanwhile.
+	finishTimeout = 5 * time.Second
+)
+
+type Options struct {
+	// TTL is how long a completed response is replayed. Default 24h.
+	TTL time.Duration
+	// StaleAfter is how long an IN_PROGRESS row blocks retries before it is
+	// considered abandoned and taken over. Default 30s.
+	StaleAfter time.Duration
+	// CaptureHeaders are response headers stored and replayed with the body.
+	// Default ETag and Location.
+	CaptureHeaders []string
+	// Forget reports statuses that must not be recorded, so the same key can
+	// be retried. Default: 412 and every 5xx.
+	Forget func(status int) bool
+}
+
+func (o *Options) defaults() {
+	if o.TTL <= 0 {
+		o.TTL = 24 * time.Hour
+	}
+	if o.StaleAfter <= 0 {
+		o.StaleAfter = 30 * time.Second
+	}
+	if o.CaptureHeaders == nil {
+		o.CaptureHeaders = []string{"ETag", "Location"}
+	}
+	if o.Forget == nil {
+		o.Forget = func(status int) bool {
+			return status >= 500 || status == http.StatusPreconditionFailed
+		}
+	}
+}
+
+// Middleware makes a gin route idempotent on (X-Client-Id, Idempotency-Key).
+// The first request runs and its response is stored; retries with the same
+// request replay it, retries with a different request get 409 and retries
+// while the first is still running get 425.
+func Middleware(store *Store, opts Options) gin.HandlerFunc {
+	opts.defaults()
+	return func(c *gin.Context) {
+		clientID := c.GetHeader(HeaderClientID)
+		key := c.GetHeader(HeaderIdempotencyKey)
+		if clientID == "" || key == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "missing X-Client-Id or Idempotency-Key",
+			})
+			return
+		}
+
+		hash, err := requestHash(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+			return
+		}
+
+		outcome, claim, resp, err := store.Begin(c.Request.Context(), clientID, key, c.FullPath(), hash, time.Now(), opts.StaleAfter, opts.TTL)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		switch outcome {
+		case Mismatch:
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "idempotency key reused with different request payload",
+			})
+			return
+		case InProgress:
+			c.Header("Retry-After", strconv.Itoa(int(opts.StaleAfter.Seconds())))
+			c.AbortWithStatusJSON(http.StatusTooEarly, gin.H{
+				"error": "a request with this idempotency key is still in progress",
+			})
+			return
+		case Replay:
+			for k, v := range resp.Headers {
+				c.Header(k, v)
+			}
+			c.Header(HeaderReplayed, "true")
+			if len(resp.Body) == 0 || string(resp.Body) == "null" {
+				c.AbortWithStatus(resp.Status)
+				return
+			}
+			c.Data(resp.Status, "application/json; charset=utf-8", resp.Body)
+			c.Abort()
+			return
+		}
+
+		w := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Set(claimKey, claim)
+		c.Next()
+
+		status := w.Status()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), finishTimeout)
+		defer cancel()
+		switch {
+		case claim.completedInTx && status < 500:
+			// Recorded by the handler in its own transaction.
+		case opts.Forget(status):
+			err = store.forget(ctx, claim)
+		default:
+			body := w.buf.String()
+			if !json.Valid(w.buf.Bytes()) {
+				body = "null"
+			}
+			err = store.complete(ctx, claim, status, body, captured(w, opts.CaptureHeaders))
+		}
+		if err != nil {
+			log.Printf("idempotency: finishing %s/%s: %v\n", clientID, key, err)
+		}
+	}
+}
+
+// FromContext returns the claim held by the current request, or nil when the
+// route is not behind the middleware.
+func FromContext(c *gin.Context) *Claim {
+	v, ok := c.Get(claimKey)
+	if !ok {
+		return nil
+	}
+	claim, _ := v.(*Claim)
+	return claim
+}
+
+// requestHash fingerprints method, path, query and body. JSON bodies are
+// canonicalised so key order and whitespace do not matter.
+func requestHash(c *gin.Context) (string, error) {
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return "", err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+
+	var body any = string(raw)
+	if len(bytes.TrimSpace(raw)) > 0 {
+		var parsed any
+		if json.Unmarshal(raw, &parsed) == nil {
+			body = parsed
+		}
+	}
+	return domain.HashRequest(struct {
+		Method string `json:"method"`
+		Path   string `json:"path"`
+		Query  string `json:"query"`
+		Body   any    `json:"body"`
+	}{
+		Method: c.Request.Method,
+		Path:   c.Request.URL.Path,
+		Query:  c.Request.URL.RawQuery,
+		Body:   body,
+	})
+}
+
+func captured(w *captureWriter, names []string) map[string]string {
+	out := map[string]string{}
+	for _, n := range names {
+		if v := w.Header().Get(n); v != "" {
+			out[n] = v
+		}
+	}
+	return out
+}
+
+type captureWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.buf.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.buf.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
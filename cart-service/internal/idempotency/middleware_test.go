@@ -0,0 +1,132 @@
+package idempotency
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+
+	"github.com/gin-gonic/gin"
+)
+
+func hashOf(t *testing.T, method, target, body string) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+	h, err := requestHash(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest, _ := io.ReadAll(c.Request.Body)
+	if string(rest) != body {
+		t.Fatalf("body not restored: %q", rest)
+	}
+	return h
+}
+
+func TestRequestHash(t *testing.T) {
+	base := hashOf(t, http.MethodPost, "/v1/carts/1/items", `{"sku":"A","qty":1}`)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		same   bool
+	}{
+		{"key order and whitespace ignored", http.MethodPost, "/v1/carts/1/items", "{ \"qty\": 1,\n \"sku\": \"A\" }", true},
+		{"different body", http.MethodPost, "/v1/carts/1/items", `{"sku":"A","qty":2}`, false},
+		{"different path", http.MethodPost, "/v1/carts/2/items", `{"sku":"A","qty":1}`, false},
+		{"different query", http.MethodPost, "/v1/carts/1/items?variant_id=x", `{"sku":"A","qty":1}`, false},
+		{"different method", http.MethodPut, "/v1/carts/1/items", `{"sku":"A","qty":1}`, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := hashOf(t, tc.method, tc.target, tc.body)
+			if (got == base) != tc.same {
+				t.Fatalf("same=%v, want %v", got == base, tc.same)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRequiresHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/x", Middleware(nil, Options{}), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/x", strings.NewReader("{}"))
+	req.Header.Set(HeaderClientID, "web")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", w.Code)
+	}
+}
+
+func TestCaptureWriter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	var captured string
+	r.Use(func(c *gin.Context) {
+		w := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		captured = w.buf.String()
+	})
+	r.GET("/x", func(c *gin.Context) { c.JSON(http.StatusCreated, gin.H{"ok": true}) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/x", nil))
+	if w.Code != http.StatusCreated || captured != `{"ok":true}` || w.Body.String() != captured {
+		t.Fatalf("code=%d captured=%q body=%q", w.Code, captured, w.Body.String())
+	}
+}
+
+// ctxStore records the contexts transactions ran under.
+type ctxStore struct {
+	repo.Store
+	ctxs []string
+}
+
+func (s *ctxStore) Transaction(ctx context.Context, fn func(tx repo.Tx) error) error {
+	var desc []string
+	if ctx.Done() != nil {
+		desc = append(desc, "cancellable")
+	}
+	if _, ok := ctx.Deadline(); ok {
+		desc = append(desc, "bounded")
+	}
+	if ctx.Err() != nil {
+		desc = append(desc, "cancelled")
+	}
+	s.ctxs = append(s.ctxs, strings.Join(desc, " "))
+	return s.Store.Transaction(ctx, fn)
+}
+
+func TestMiddlewareContexts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &ctxStore{Store: repo.NewMemory()}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := gin.New()
+	r.POST("/x", Middleware(NewStore(store), Options{}), func(c *gin.Context) {
+		cancel() // the client went away while the handler ran
+		c.Status(http.StatusServiceUnavailable)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/x", strings.NewReader("{}")).WithContext(ctx)
+	req.Header.Set(HeaderClientID, "web")
+	req.Header.Set(HeaderIdempotencyKey, "k")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Begin runs under the request; forgetting the claim outlives its
+	// cancellation but not the finish timeout.
+	if got, want := strings.Join(store.ctxs, ", "), "cancellable, cancellable bounded"; got != want {
+		t.Fatalf("transactions ran under %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,175 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+)
+
+// Row states in cart_idempotency.
+const (
+	StateInProgress = "IN_PROGRESS"
+	StateCompleted  = "COMPLETED"
+)
+
+// Outcome of trying to claim an idempotency key.
+type Outcome int
+
+const (
+	// Proceed: the caller holds the key and must run the request.
+	Proceed Outcome = iota
+	// Replay: the request already completed; answer with the stored response.
+	Replay
+	// Mismatch: the key was used before with a different request.
+	Mismatch
+	// InProgress: another request holds the key and its lease is still fresh.
+	InProgress
+)
+
+// ErrLeaseLost means the claim was taken over by another request after its
+// lease went stale; the caller must not commit its work.
+var ErrLeaseLost = errors.New("idempotency lease lost")
+
+// Store keeps idempotency keys in cart_idempotency.
+type Store struct {
+	store repo.Store
+}
+
+func NewStore(store repo.Store) *Store {
+	return &Store{store: store}
+}
+
+// Claim is a lease on an idempotency key held by the running request.
+type Claim struct {
+	ClientID string
+	Key      string
+	LockedAt time.Time
+
+	store         *Store
+	resourceID    []byte
+	completedInTx bool
+}
+
+// Response is a stored response ready to be replayed.
+type Response struct {
+	Status  int
+	Body    []byte
+	Headers map[string]string
+}
+
+// Begin claims (clientID, key) for a request whose fingerprint is hash. Rows
+// stuck IN_PROGRESS for longer than staleAfter are taken over.
+func (s *Store) Begin(ctx context.Context, clientID, key, endpoint, hash string, now time.Time, staleAfter, ttl time.Duration) (Outcome, *Claim, *Response, error) {
+	now = now.UTC().Truncate(time.Millisecond)
+	claim := &Claim{ClientID: clientID, Key: key, LockedAt: now, store: s}
+
+	var outcome Outcome
+	var resp *Response
+	err := s.store.Transaction(ctx, func(tx repo.Tx) error {
+		keys := tx.Idempotency()
+		row, err := keys.Lock(clientID, key)
+		if errors.Is(err, repo.ErrNotFound) {
+			createErr := keys.Create(&domain.CartIdempotency{
+				ClientID:       clientID,
+				IdempotencyKey: key,
+				Endpoint:       endpoint,
+				RequestHash:    hash,
+				ResponseBody:   "{}",
+				State:          StateInProgress,
+				LockedAt:       &now,
+				ExpiresAt:      now.Add(ttl),
+			})
+			if createErr == nil {
+				outcome = Proceed
+				return nil
+			}
+			// Lost the insert race: wait for the winner and evaluate its row.
+			if row, err = keys.Lock(clientID, key); err != nil {
+				return createErr
+			}
+		} else if err != nil {
+			return err
+		}
+
+		switch {
+		case row.RequestHash != hash:
+			outcome = Mismatch
+		case row.State == StateCompleted:
+			outcome = Replay
+			resp = &Response{Body: []byte(row.ResponseBody), Headers: map[string]string{}}
+			if row.HTTPStatus != nil {
+				resp.Status = int(*row.HTTPStatus)
+			}
+			if row.ResponseHeaders != "" {
+				_ = json.Unmarshal([]byte(row.ResponseHeaders), &resp.Headers)
+			}
+		case row.LockedAt != nil && now.Sub(*row.LockedAt) < staleAfter:
+			outcome = InProgress
+		default:
+			// The previous holder crashed or stalled; take the lease over.
+			outcome = Proceed
+			return keys.Relock(clientID, key, now)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if outcome != Proceed {
+		claim = nil
+	}
+	return outcome, claim, resp, nil
+}
+
+// SetResourceID records the resource the request created or changed.
+func (c *Claim) SetResourceID(id []byte) { c.resourceID = id }
+
+// CompleteTx stores the response inside the handler's own transaction, so the
+// business change and the idempotency record commit (or roll back) together.
+func (c *Claim) CompleteTx(tx repo.Tx, status int, body any, headers map[string]string) error {
+	ok, err := tx.Idempotency().Complete(c.ClientID, c.Key, c.LockedAt, completion(c.resourceID, status, mustJSON(body), headers))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLeaseLost
+	}
+	c.completedInTx = true
+	return nil
+}
+
+func (s *Store) complete(ctx context.Context, c *Claim, status int, body string, headers map[string]string) error {
+	return s.store.Transaction(ctx, func(tx repo.Tx) error {
+		ok, err := tx.Idempotency().Complete(c.ClientID, c.Key, c.LockedAt, completion(c.resourceID, status, body, headers))
+		if err == nil && !ok {
+			err = ErrLeaseLost
+		}
+		return err
+	})
+}
+
+// forget deletes the claim so the client can retry with the same key.
+func (s *Store) forget(ctx context.Context, c *Claim) error {
+	return s.store.Transaction(ctx, func(tx repo.Tx) error {
+		return tx.Idempotency().Delete(c.ClientID, c.Key, c.LockedAt)
+	})
+}
+
+func completion(resourceID []byte, status int, body string, headers map[string]string) domain.CartIdempotency {
+	code := int16(status)
+	return domain.CartIdempotency{
+		ResourceID:      resourceID,
+		HTTPStatus:      &code,
+		ResponseBody:    body,
+		ResponseHeaders: mustJSON(headers),
+	}
+}
+
+func mustJSON(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,116 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Fake is an in-memory Port for tests and for running without
+// inventory-service. Stock is keyed by "sku" or "sku/variant"; the variant
+// entry wins when both exist. A nil stock map means unlimited stock.
+type Fake struct {
+	mu    sync.Mutex
+	stock map[string]int
+	held  map[string]Request
+
+	// Fail, when set, is consulted before each Reserve; a non-nil error is
+	// returned as is, to simulate inventory-service failing.
+	Fail func(Request) error
+}
+
+func NewFake(stock map[string]int) *Fake {
+	var s map[string]int
+	if stock != nil {
+		s = make(map[string]int, len(stock))
+		for k, v := range stock {
+			s[k] = v
+		}
+	}
+	return &Fake{stock: s, held: map[string]Request{}}
+}
+
+func (f *Fake) Reserve(_ context.Context, req Request) (Reservation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.held[req.Key]; ok {
+		return f.reservation(req), nil
+	}
+	if f.Fail != nil {
+		if err := f.Fail(req); err != nil {
+			return Reservation{}, err
+		}
+	}
+	if f.stock != nil {
+		k := stockKey(f.stock, req.SKU, req.VariantID)
+		if f.stock[k] < req.Qty {
+			return Reservation{}, fmt.Errorf("%w: %s has %d, want %d", ErrInsufficientStock, req.SKU, f.stock[k], req.Qty)
+		}
+		f.stock[k] -= req.Qty
+	}
+	f.held[req.Key] = req
+	return f.reservation(req), nil
+}
+
+func (f *Fake) Release(_ context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	req, ok := f.held[key]
+	if !ok {
+		return nil
+	}
+	if f.stock != nil {
+		f.stock[stockKey(f.stock, req.SKU, req.VariantID)] += req.Qty
+	}
+	delete(f.held, key)
+	return nil
+}
+
+// Available is the unreserved stock of a SKU / variant; -1 when unlimited.
+func (f *Fake) Available(sku, variantID string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stock == nil {
+		return -1
+	}
+	return f.stock[stockKey(f.stock, sku, variantID)]
+}
+
+// Held lists the keys currently reserved.
+func (f *Fake) Held() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.held))
+	for k := range f.held {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (f *Fake) reservation(req Request) Reservation {
+	id := uuid.NewSHA1(uuid.NameSpaceOID, []byte(req.Key)).String()
+	var exp time.Time
+	if req.TTL > 0 {
+		exp = time.Now().UTC().Add(req.TTL)
+	}
+	return Reservation{ID: id, ExpiresAt: exp}
+}
+
+func stockKey(stock map[string]int, sku, variantID string) string {
+	if variantID != "" {
+		if k := sku + "/" + variantID; hasKey(stock, k) {
+			return k
+		}
+	}
+	return sku
+}
+
+func hasKey(m map[string]int, k string) bool {
+	_, ok := m[k]
+	return ok
+}
@@ -0,0 +1,115 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTPClient is the Port backed by inventory-service:
+//
+//	POST   {base}/v1/reservations        201/200 reserved, 409 insufficient stock
+//	DELETE {base}/v1/reservations/{key}  204/200 released, 404 unknown key
+type HTTPClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewHTTPClient(baseURL string, timeout time.Duration) *HTTPClient {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &HTTPClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+type reserveBody struct {
+	ReservationKey string `json:"reservation_key"`
+	CartID         string `json:"cart_id,omitempty"`
+	SKU            string `json:"sku"`
+	VariantID      string `json:"variant_id,omitempty"`
+	Qty            int    `json:"qty"`
+	TTLSeconds     int64  `json:"ttl_seconds,omitempty"`
+}
+
+type reserveResp struct {
+	ReservationID string    `json:"reservation_id"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+func (h *HTTPClient) Reserve(ctx context.Context, req Request) (Reservation, error) {
+	payload, err := json.Marshal(reserveBody{
+		ReservationKey: req.Key,
+		CartID:         req.CartID,
+		SKU:            req.SKU,
+		VariantID:      req.VariantID,
+		Qty:            req.Qty,
+		TTLSeconds:     int64(req.TTL / time.Second),
+	})
+	if err != nil {
+		return Reservation{}, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.baseURL+"/v1/reservations", bytes.NewReader(payload))
+	if err != nil {
+		return Reservation{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Idempotency-Key", req.Key)
+
+	resp, err := h.client.Do(httpReq)
+	if err != nil {
+		return Reservation{}, fmt.Errorf("inventory: reserve %s: %w", req.SKU, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		var out reserveResp
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return Reservation{}, fmt.Errorf("inventory: reserve %s: decode response: %w", req.SKU, err)
+		}
+		return Reservation{ID: out.ReservationID, ExpiresAt: out.ExpiresAt}, nil
+	case http.StatusConflict:
+		return Reservation{}, fmt.Errorf("%w: %s %s", ErrInsufficientStock, req.SKU, errorMessage(resp.Body))
+	default:
+		return Reservation{}, fmt.Errorf("inventory: reserve %s: unexpected status %d %s", req.SKU, resp.StatusCode, errorMessage(resp.Body))
+	}
+}
+
+func (h *HTTPClient) Release(ctx context.Context, key string) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodDelete, h.baseURL+"/v1/reservations/"+url.PathEscape(key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("inventory: release %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("inventory: release %s: unexpected status %d %s", key, resp.StatusCode, errorMessage(resp.Body))
+	}
+}
+
+// errorMessage reads the "error" field of a JSON error body, if any.
+func errorMessage(body io.Reader) string {
+	var e struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(body, 4<<10)).Decode(&e); err != nil || e.Error == "" {
+		return ""
+	}
+	return "(" + e.Error + ")"
+}
@@ -0,0 +1,120 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+	f := NewFake(map[string]int{"A": 3, "B": 5, "B/red": 1})
+
+	if _, err := f.Reserve(ctx, Request{Key: "k1", SKU: "A", Qty: 2}); err != nil {
+		t.Fatal(err)
+	}
+	// Same key again: no double reservation.
+	if _, err := f.Reserve(ctx, Request{Key: "k1", SKU: "A", Qty: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Available("A", ""); got != 1 {
+		t.Errorf("A available = %d, want 1", got)
+	}
+	if _, err := f.Reserve(ctx, Request{Key: "k2", SKU: "A", Qty: 2}); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("over-reserve err = %v", err)
+	}
+
+	// Variant stock is tracked separately from the SKU when configured.
+	if _, err := f.Reserve(ctx, Request{Key: "k3", SKU: "B", VariantID: "red", Qty: 2}); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("variant err = %v", err)
+	}
+	if _, err := f.Reserve(ctx, Request{Key: "k4", SKU: "B", VariantID: "blue", Qty: 2}); err != nil {
+		t.Errorf("variant falls back to sku: %v", err)
+	}
+
+	if err := f.Release(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Release(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Release(ctx, "never"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Available("A", ""); got != 3 {
+		t.Errorf("A available after release = %d, want 3", got)
+	}
+
+	unlimited := NewFake(nil)
+	if _, err := unlimited.Reserve(ctx, Request{Key: "x", SKU: "ANY", Qty: 999}); err != nil {
+		t.Errorf("unlimited: %v", err)
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	exp := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	var released []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/reservations":
+			var body reserveBody
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if r.Header.Get("Idempotency-Key") != body.ReservationKey || body.TTLSeconds != 900 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			switch body.SKU {
+			case "GONE":
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(`{"error":"out of stock"}`))
+			case "BROKEN":
+				w.WriteHeader(http.StatusBadGateway)
+			default:
+				w.WriteHeader(http.StatusCreated)
+				_ = json.NewEncoder(w).Encode(reserveResp{ReservationID: "r-" + body.ReservationKey, ExpiresAt: exp})
+			}
+		case r.Method == http.MethodDelete:
+			released = append(released, r.URL.Path)
+			if r.URL.Path == "/v1/reservations/unknown" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := NewHTTPClient(srv.URL+"/", time.Second)
+
+	res, err := c.Reserve(ctx, Request{Key: "s1:0", SKU: "A", Qty: 1, TTL: 15 * time.Minute})
+	if err != nil || res.ID != "r-s1:0" || !res.ExpiresAt.Equal(exp) {
+		t.Errorf("reserve = %+v, %v", res, err)
+	}
+	if _, err := c.Reserve(ctx, Request{Key: "s1:1", SKU: "GONE", Qty: 1, TTL: 15 * time.Minute}); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("409 err = %v", err)
+	}
+	_, err = c.Reserve(ctx, Request{Key: "s1:2", SKU: "BROKEN", Qty: 1, TTL: 15 * time.Minute})
+	if err == nil || errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("502 err = %v", err)
+	}
+
+	if err := c.Release(ctx, "s1:0"); err != nil {
+		t.Errorf("release: %v", err)
+	}
+	if err := c.Release(ctx, "unknown"); err != nil {
+		t.Errorf("release unknown: %v", err)
+	}
+	if len(released) != 2 || released[0] != "/v1/reservations/s1:0" {
+		t.Errorf("released = %v", released)
+	}
+}
@@ -0,0 +1,37 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Port is how checkout holds stock. Every reservation is named by a Key the
+// caller chooses, which makes both calls idempotent: reserving a key again
+// returns the existing reservation, and releasing a key that was never
+// reserved (or was already released) succeeds. A checkout that does not know
+// whether a Reserve went through can therefore always release by key.
+type Port interface {
+	Reserve(ctx context.Context, req Request) (Reservation, error)
+	Release(ctx context.Context, key string) error
+}
+
+// Request asks for Qty units of one SKU / variant for TTL.
+type Request struct {
+	Key       string
+	CartID    string
+	SKU       string
+	VariantID string
+	Qty       int
+	TTL       time.Duration
+}
+
+// Reservation is stock held for a request until ExpiresAt.
+type Reservation struct {
+	ID        string
+	ExpiresAt time.Time
+}
+
+// ErrInsufficientStock means the reservation was refused: the stock is not
+// there. Any other Reserve error leaves the outcome unknown.
+var ErrInsufficientStock = errors.New("inventory: insufficient stock")
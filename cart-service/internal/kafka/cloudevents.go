@@ -0,0 +1,30 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/pkg/cloudevents"
+)
+
+// PublishEvent writes e as a CloudEvent in the given mode.
+func (p *Producer) PublishEvent(ctx context.Context, key string, e cloudevents.Event, mode cloudevents.Mode) error {
+	value, ceHeaders, err := cloudevents.Encode(e, mode)
+	if err != nil {
+		return err
+	}
+	headers := make([]Header, len(ceHeaders))
+	for i, h := range ceHeaders {
+		headers[i] = Header{Key: h.Key, Value: string(h.Value)}
+	}
+	return p.Publish(ctx, key, value, headers...)
+}
+
+// CloudEvent decodes msg as a CloudEvent in either mode. Messages that are
+// not CloudEvents return cloudevents.ErrNotCloudEvent.
+func (m Message) CloudEvent() (cloudevents.Event, error) {
+	headers := make([]cloudevents.Header, 0, len(m.Headers))
+	for k, v := range m.Headers {
+		headers = append(headers, cloudevents.Header{Key: k, Value: []byte(v)})
+	}
+	return cloudevents.Decode(headers, m.Value)
+}
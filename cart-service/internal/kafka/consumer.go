@@ -1,6 +1,110 @@
 package kafka
 
-// Placeholder for consuming events (optional for cart-service).
-// If you consume external events (catalog price update, inventory signals),
-// implement processed_events idempotency.
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
 
+	"github.com/segmentio/kafka-go"
+)
+
+// Message is a consumed record with the fields handlers care about.
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+}
+
+// HandlerFunc processes one message. Returning an error retries the message
+// until it succeeds or the consumer stops, so a database outage delays events
+// instead of losing them; returning ErrSkip commits it without effect.
+type HandlerFunc func(ctx context.Context, msg Message) error
+
+// ErrSkip marks a message that can never be processed (bad JSON, unknown
+// shape); it is logged and committed instead of retried.
+var ErrSkip = errors.New("skip message")
+
+type Consumer struct {
+	r *kafka.Reader
+	// Retries wait backoff, doubling up to maxBackoff.
+	backoff, maxBackoff time.Duration
+}
+
+func NewConsumer(brokers []string, groupID string, topics ...string) *Consumer {
+	return &Consumer{
+		r: kafka.NewReader(kafka.ReaderConfig{
+			Brokers:     brokers,
+			GroupID:     groupID,
+			GroupTopics: topics,
+			StartOffset: kafka.FirstOffset,
+			MinBytes:    1,
+			MaxBytes:    10e6,
+		}),
+		backoff:    200 * time.Millisecond,
+		maxBackoff: 30 * time.Second,
+	}
+}
+
+func (c *Consumer) Close() error { return c.r.Close() }
+
+// Run fetches messages until ctx is done and commits each one after handle
+// returns. Offsets are committed only after the handler's transaction, so a
+// crash redelivers the message and the handler must be idempotent.
+func (c *Consumer) Run(ctx context.Context, handle HandlerFunc) error {
+	for {
+		m, err := c.r.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		msg := Message{
+			Topic:     m.Topic,
+			Partition: m.Partition,
+			Offset:    m.Offset,
+			Key:       m.Key,
+			Value:     m.Value,
+			Headers:   make(map[string]string, len(m.Headers)),
+		}
+		for _, h := range m.Headers {
+			msg.Headers[h.Key] = string(h.Value)
+		}
+
+		c.handleWithRetry(ctx, handle, msg)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := c.r.CommitMessages(ctx, m); err != nil {
+			return err
+		}
+	}
+}
+
+// handleWithRetry returns once handle succeeds or skips msg, or ctx is done.
+// Later messages of the partition wait behind a failing one, keeping their
+// order.
+func (c *Consumer) handleWithRetry(ctx context.Context, handle HandlerFunc, msg Message) {
+	backoff := c.backoff
+	for attempt := 1; ; attempt++ {
+		err := handle(ctx, msg)
+		if err == nil {
+			return
+		}
+		if errors.Is(err, ErrSkip) {
+			log.Printf("consumer: skipping %s/%d@%d: %v\n", msg.Topic, msg.Partition, msg.Offset, err)
+			return
+		}
+		log.Printf("consumer: %s/%d@%d attempt %d: %v; retrying in %s\n", msg.Topic, msg.Partition, msg.Offset, attempt, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, c.maxBackoff)
+	}
+}
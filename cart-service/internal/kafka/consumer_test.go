@@ -0,0 +1,51 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHandleWithRetry(t *testing.T) {
+	c := &Consumer{backoff: time.Millisecond, maxBackoff: 4 * time.Millisecond}
+	failing := errors.New("lock wait timeout")
+
+	tests := []struct {
+		name  string
+		fails int   // attempts returning err before success
+		err   error // nil: fail forever with failing
+		calls int
+	}{
+		{"succeeds", 0, nil, 1},
+		{"retries past any attempt limit", 12, failing, 13},
+		{"skip is not retried", 1, fmt.Errorf("%w: bad json", ErrSkip), 1},
+	}
+	for _, tc := range tests {
+		calls := 0
+		c.handleWithRetry(context.Background(), func(context.Context, Message) error {
+			calls++
+			if calls <= tc.fails {
+				return tc.err
+			}
+			return nil
+		}, Message{})
+		if calls != tc.calls {
+			t.Errorf("%s: handler ran %d times, want %d", tc.name, calls, tc.calls)
+		}
+	}
+
+	// Only stopping the consumer ends the retries of a failing message.
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	c.handleWithRetry(ctx, func(context.Context, Message) error {
+		if calls++; calls == 20 {
+			cancel()
+		}
+		return failing
+	}, Message{})
+	if calls != 20 {
+		t.Errorf("handler ran %d times, want 20 (until ctx was cancelled)", calls)
+	}
+}
@@ -26,10 +26,20 @@ func NewProducer(brokers []string, topic string) *Producer {
 
 func (p *Producer) Close() error { return p.w.Close() }
 
-func (p *Producer) Publish(ctx context.Context, key string, value []byte) error {
-	return p.w.WriteMessages(ctx, kafka.Message{
+// Header is a Kafka record header.
+type Header struct {
+	Key   string
+	Value string
+}
+
+func (p *Producer) Publish(ctx context.Context, key string, value []byte, headers ...Header) error {
+	msg := kafka.Message{
 		Key:   []byte(key),
 		Value: value,
 		Time:  time.Now(),
-	})
+	}
+	for _, h := range headers {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: h.Key, Value: []byte(h.Value)})
+	}
+	return p.w.WriteMessages(ctx, msg)
 }
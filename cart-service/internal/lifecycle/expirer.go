@@ -0,0 +1,86 @@
+package lifecycle
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/history"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Metrics are published under "cart_lifecycle" on /debug/vars.
+var metrics = expvar.NewMap("cart_lifecycle")
+
+// Expirer periodically closes ACTIVE carts past expires_at. Replicas can run
+// side by side: each batch locks its carts with SKIP LOCKED.
+type Expirer struct {
+	db        *gorm.DB
+	interval  time.Duration
+	batchSize int
+	now       func() time.Time
+}
+
+func NewExpirer(db *gorm.DB, interval time.Duration, batchSize int) *Expirer {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return &Expirer{db: db, interval: interval, batchSize: batchSize, now: time.Now}
+}
+
+func (e *Expirer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			for {
+				n, err := e.expireBatch(ctx)
+				if err != nil {
+					metrics.Add("errors", 1)
+					log.Printf("lifecycle: %v\n", err)
+				}
+				if err != nil || n < e.batchSize || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+// expireBatch closes up to batchSize expired carts in one transaction.
+func (e *Expirer) expireBatch(ctx context.Context) (int, error) {
+	now := e.now().UTC()
+	var n int
+	err := e.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var carts []domain.Cart
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = 'ACTIVE' AND expires_at IS NOT NULL AND expires_at <= ?", now).
+			Order("expires_at ASC").
+			Limit(e.batchSize).
+			Find(&carts).Error; err != nil {
+			return err
+		}
+		for i := range carts {
+			status, err := Expire(repo.GormTx(tx), &carts[i], now, history.Worker("expirer"))
+			if err != nil {
+				return err
+			}
+			metrics.Add(string(status), 1)
+		}
+		n = len(carts)
+		return nil
+	})
+	return n, err
+}
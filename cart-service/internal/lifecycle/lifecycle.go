@@ -0,0 +1,168 @@
+package lifecycle
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/events"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/history"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/outbox"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/storedvalue"
+)
+
+// Carts live for a TTL that depends on the owner and slides forward on every
+// mutation. Once it passes the cart is closed:
+//
+//   - USER carts with items become ABANDONED: the owner is known and can be
+//     reminded, so marketing gets a CartAbandoned.v1 with the contents.
+//   - GUEST carts and empty carts become EXPIRED. Guest carts with items still
+//     emit CartAbandoned.v1 (guest_id lets a channel re-target the device).
+//
+// Either way held gift card / wallet balance goes back to the account.
+
+const (
+	StatusAbandoned = domain.CartAbandoned
+	StatusExpired   = domain.CartExpired
+
+	EventCartAbandoned = events.CartAbandoned
+)
+
+// TTL is the idle lifetime of a cart per owner type.
+type TTL struct {
+	Guest time.Duration
+	User  time.Duration
+}
+
+// ExpiresAt is when a cart of ownerType touched at now expires; nil when the
+// TTL for that owner is not configured.
+func (t TTL) ExpiresAt(ownerType string, now time.Time) *time.Time {
+	d := t.User
+	if ownerType == "GUEST" {
+		d = t.Guest
+	}
+	if d <= 0 {
+		return nil
+	}
+	at := now.UTC().Add(d)
+	return &at
+}
+
+// IsExpired reports whether cart is past its expiry, whether or not the
+// expiry job has closed it yet.
+func IsExpired(cart *domain.Cart, now time.Time) bool {
+	switch cart.Status {
+	case StatusAbandoned, StatusExpired:
+		return true
+	case domain.CartActive:
+		return cart.ExpiresAt != nil && !now.Before(*cart.ExpiresAt)
+	}
+	return false
+}
+
+// Expire closes a locked ACTIVE cart whose TTL has passed, recording actor
+// in its history. It returns the status the cart moved to.
+func Expire(tx repo.Tx, cart *domain.Cart, now time.Time, actor history.Actor) (domain.CartStatus, error) {
+	before, err := history.Take(tx, cart)
+	if err != nil {
+		return "", err
+	}
+	items, err := tx.Items().List(cart.CartID)
+	if err != nil {
+		return "", err
+	}
+	status := StatusExpired
+	if cart.OwnerType == "USER" && len(items) > 0 {
+		status = StatusAbandoned
+	}
+
+	if err := cart.Transition(status); err != nil {
+		return "", err
+	}
+
+	if err := ReleaseStoredValue(tx, cart.CartID, "cart "+string(status)); err != nil {
+		return "", err
+	}
+	if err := tx.Carts().Bump(cart); err != nil {
+		return "", err
+	}
+	if err := history.Record(tx, cart.CartID, before, history.Entry{Action: history.ActionExpired, Actor: actor}); err != nil {
+		return "", err
+	}
+
+	if len(items) == 0 {
+		return status, nil
+	}
+	return status, enqueueAbandoned(tx, cart, items, now)
+}
+
+// ReleaseStoredValue gives back the gift card and wallet balance held for a
+// cart leaving ACTIVE other than by checkout, marking those promotions
+// RELEASED.
+func ReleaseStoredValue(tx repo.Tx, cartID []byte, reason string) error {
+	if err := storedvalue.ReleaseCart(tx.StoredValue(), cartID, reason); err != nil {
+		return err
+	}
+	promos, err := tx.Promotions().List(cartID, repo.PromotionFilter{
+		Types:    []string{domain.PromoTypeGiftCard, domain.PromoTypeWallet},
+		Statuses: []string{"APPLIED"},
+	})
+	if err != nil {
+		return err
+	}
+	for i := range promos {
+		promos[i].Status = "RELEASED"
+		if err := tx.Promotions().Update(&promos[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func enqueueAbandoned(tx repo.Tx, cart *domain.Cart, items []domain.CartItem, now time.Time) error {
+	cartUUID, err := domain.Bin16ToUUID(cart.CartID)
+	if err != nil {
+		return err
+	}
+	totals, err := tx.Totals().Get(cart.CartID)
+	if errors.Is(err, repo.ErrNotFound) {
+		totals, err = &domain.CartTotals{}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	lines := make([]map[string]any, 0, len(items))
+	for _, it := range items {
+		lines = append(lines, map[string]any{
+			"sku":              it.SKU,
+			"variant_id":       it.VariantID,
+			"qty":              it.Qty,
+			"product_name":     it.ProductName,
+			"image_url":        it.ImageURL,
+			"unit_price_paise": it.UnitPricePaise,
+		})
+	}
+	data := map[string]any{
+		"cart_id":           cartUUID.String(),
+		"cart_version":      cart.Version,
+		"status":            cart.Status,
+		"owner_type":        cart.OwnerType,
+		"guest_id":          cart.GuestID,
+		"channel":           cart.Channel,
+		"currency":          cart.Currency,
+		"items":             lines,
+		"grand_total_paise": totals.GrandTotalPaise,
+		"last_activity_at":  cart.UpdatedAt,
+		"expired_at":        now.UTC(),
+	}
+	if u, err := domain.Bin16ToUUID(cart.UserID); err == nil {
+		data["user_id"] = u.String()
+	}
+	return outbox.Enqueue(tx.Outbox(), cart.CartID, domain.EventEnvelope{
+		EventType:     EventCartAbandoned,
+		CorrelationID: cartUUID.String(),
+		Data:          data,
+	})
+}
@@ -0,0 +1,46 @@
+package lifecycle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+)
+
+func TestTTLExpiresAt(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	ttl := TTL{Guest: 72 * time.Hour, User: 30 * 24 * time.Hour}
+
+	if got := ttl.ExpiresAt("GUEST", now); got == nil || !got.Equal(now.Add(72*time.Hour)) {
+		t.Errorf("guest: %v", got)
+	}
+	if got := ttl.ExpiresAt("USER", now); got == nil || !got.Equal(now.Add(30*24*time.Hour)) {
+		t.Errorf("user: %v", got)
+	}
+	if got := (TTL{}).ExpiresAt("USER", now); got != nil {
+		t.Errorf("no ttl: %v", got)
+	}
+}
+
+func TestIsExpired(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	tests := []struct {
+		name string
+		cart domain.Cart
+		want bool
+	}{
+		{"active without expiry", domain.Cart{Status: "ACTIVE"}, false},
+		{"active before expiry", domain.Cart{Status: "ACTIVE", ExpiresAt: &future}, false},
+		{"active past expiry", domain.Cart{Status: "ACTIVE", ExpiresAt: &past}, true},
+		{"abandoned", domain.Cart{Status: StatusAbandoned, ExpiresAt: &future}, true},
+		{"expired", domain.Cart{Status: StatusExpired}, true},
+		{"checked out past expiry", domain.Cart{Status: "CHECKED_OUT", ExpiresAt: &past}, false},
+	}
+	for _, tc := range tests {
+		if got := IsExpired(&tc.cart, now); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,376 @@
+// Package migrate applies cart-service's schema migrations: ordered SQL
+// files embedded in the binary as sql/<version>_<name>.up.sql and
+// sql/<version>_<name>.down.sql.
+//
+// Applied versions are recorded in schema_migrations. Runners take a MySQL
+// advisory lock first, so several pods starting at once (Kubernetes init
+// containers) apply each migration exactly once; the others wait and then
+// find nothing left to do.
+//
+// MySQL commits DDL as it runs, so a migration cannot be rolled back when
+// one of its statements fails. Its row stays marked dirty and every runner
+// refuses to go on until someone repairs the schema and runs Force.
+//
+// Changing the schema: add the next version with both files. Never edit a
+// migration that has been released.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var embedded embed.FS
+
+var (
+	// ErrDirty means a migration failed part way and the schema needs repair.
+	ErrDirty = errors.New("migrate: schema is dirty")
+	// ErrLocked means another runner held the lock for the whole LockTimeout.
+	ErrLocked = errors.New("migrate: timed out waiting for the migration lock")
+	// ErrUnknownVersion means the database is at a version this build does
+	// not have, so it cannot be migrated down.
+	ErrUnknownVersion = errors.New("migrate: database version is unknown to this build")
+)
+
+// Migration is one schema change and its inverse.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrations returns the embedded migrations, oldest first.
+func Migrations() ([]Migration, error) {
+	sub, err := fs.Sub(embedded, "sql")
+	if err != nil {
+		return nil, err
+	}
+	return Load(sub)
+}
+
+// Load reads the migrations in the root of fsys. Every version needs both an
+// up and a down file, and versions must be unique.
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, file := range names {
+		version, name, dir, err := parseName(file)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migrate: version %d is both %q and %q", version, m.Name, name)
+		}
+		body := &m.Up
+		if dir == "down" {
+			body = &m.Down
+		}
+		if *body != "" {
+			return nil, fmt.Errorf("migrate: duplicate %s migration for version %d", dir, version)
+		}
+		*body = string(raw)
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migrate: version %d (%s) needs non-empty up and down files", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// parseName splits "0007_checkout_sagas.up.sql" into 7, "checkout_sagas", "up".
+func parseName(file string) (int, string, string, error) {
+	base := strings.TrimSuffix(path.Base(file), ".sql")
+	var dir string
+	switch {
+	case strings.HasSuffix(base, ".up"):
+		dir = "up"
+	case strings.HasSuffix(base, ".down"):
+		dir = "down"
+	default:
+		return 0, "", "", fmt.Errorf("migrate: %s is neither .up.sql nor .down.sql", file)
+	}
+	base = strings.TrimSuffix(base, "."+dir)
+	num, name, ok := strings.Cut(base, "_")
+	version, err := strconv.Atoi(num)
+	if !ok || name == "" || err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("migrate: %s is not named <version>_<name>.%s.sql", file, dir)
+	}
+	return version, name, dir, nil
+}
+
+// statements splits a migration into the statements it runs one by one: a
+// statement ends with a line ending in ";". Lines starting with "--" are
+// comments.
+func statements(script string) []string {
+	var out []string
+	var cur strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		cur.WriteString(line)
+		cur.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			out = append(out, strings.TrimSuffix(strings.TrimSpace(cur.String()), ";"))
+			cur.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(cur.String()); rest != "" {
+		out = append(out, rest)
+	}
+	return out
+}
+
+type Options struct {
+	// LockName is the advisory lock runners share; at most 64 characters.
+	LockName string
+	// LockTimeout is how long to wait for another runner to finish.
+	LockTimeout time.Duration
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	opts       Options
+}
+
+// New returns a Migrator applying migrations, oldest first, to db.
+func New(db *sql.DB, migrations []Migration, opts Options) *Migrator {
+	if opts.LockName == "" {
+		opts.LockName = "cart-service.schema_migrations"
+	}
+	if opts.LockTimeout <= 0 {
+		opts.LockTimeout = 5 * time.Minute
+	}
+	return &Migrator{db: db, migrations: migrations, opts: opts}
+}
+
+const createSchemaTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version bigint NOT NULL,
+  name varchar(255) NOT NULL,
+  dirty tinyint(1) NOT NULL DEFAULT 0,
+  applied_at datetime(3) NOT NULL,
+  PRIMARY KEY (version)
+)`
+
+// State is what schema_migrations records.
+type State struct {
+	Version int // latest applied; 0 when none
+	Dirty   bool
+}
+
+// Version reports the schema's current version.
+func (m *Migrator) Version(ctx context.Context) (State, error) {
+	var st State
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		var err error
+		st, err = state(ctx, conn)
+		return err
+	})
+	return st, err
+}
+
+// Up applies every migration newer than the schema and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		st, err := clean(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if mig.Version <= st.Version {
+				continue
+			}
+			if err := m.apply(ctx, conn, mig); err != nil {
+				return err
+			}
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the latest steps migrations and returns them, latest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		st, err := clean(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for ; steps > 0 && st.Version > 0; steps-- {
+			mig, ok := m.find(st.Version)
+			if !ok {
+				return fmt.Errorf("%w: %d", ErrUnknownVersion, st.Version)
+			}
+			if err := m.revert(ctx, conn, mig); err != nil {
+				return err
+			}
+			reverted = append(reverted, mig)
+			if st, err = state(ctx, conn); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Force records version as applied and clean without running anything, for
+// use once a failed migration has been repaired by hand. Version 0 records
+// nothing applied.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	if version != 0 {
+		if _, ok := m.find(version); !ok {
+			return fmt.Errorf("migrate: no migration with version %d", version)
+		}
+	}
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		if _, err := conn.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			if err := record(ctx, conn, mig, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+	return Migration{}, false
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	if err := record(ctx, conn, mig, true); err != nil {
+		return err
+	}
+	if err := run(ctx, conn, mig.Up); err != nil {
+		return fmt.Errorf("migrate: %d_%s up: %w", mig.Version, mig.Name, err)
+	}
+	_, err := conn.ExecContext(ctx, `UPDATE schema_migrations SET dirty = 0 WHERE version = ?`, mig.Version)
+	return err
+}
+
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	if _, err := conn.ExecContext(ctx, `UPDATE schema_migrations SET dirty = 1 WHERE version = ?`, mig.Version); err != nil {
+		return err
+	}
+	if err := run(ctx, conn, mig.Down); err != nil {
+		return fmt.Errorf("migrate: %d_%s down: %w", mig.Version, mig.Name, err)
+	}
+	_, err := conn.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, mig.Version)
+	return err
+}
+
+func record(ctx context.Context, conn *sql.Conn, mig Migration, dirty bool) error {
+	_, err := conn.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, dirty, applied_at) VALUES (?, ?, ?, ?)`,
+		mig.Version, mig.Name, dirty, time.Now().UTC())
+	return err
+}
+
+func run(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, stmt := range statements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func state(ctx context.Context, conn *sql.Conn) (State, error) {
+	var version sql.NullInt64
+	var dirty int
+	err := conn.QueryRowContext(ctx,
+		`SELECT MAX(version), COALESCE(SUM(dirty), 0) FROM schema_migrations`).Scan(&version, &dirty)
+	if err != nil {
+		return State{}, err
+	}
+	return State{Version: int(version.Int64), Dirty: dirty > 0}, nil
+}
+
+// clean returns the state, failing with ErrDirty if a migration is half done.
+func clean(ctx context.Context, conn *sql.Conn) (State, error) {
+	st, err := state(ctx, conn)
+	if err == nil && st.Dirty {
+		err = fmt.Errorf("%w at version %d: repair it, then run force", ErrDirty, st.Version)
+	}
+	return st, err
+}
+
+// locked runs fn on one connection holding the advisory lock, with
+// schema_migrations created. GET_LOCK belongs to the session, so everything
+// runs on that connection.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`,
+		m.opts.LockName, int(m.opts.LockTimeout.Seconds())).Scan(&got); err != nil {
+		return err
+	}
+	if !got.Valid || got.Int64 != 1 {
+		return ErrLocked
+	}
+	defer func() {
+		_, relErr := conn.ExecContext(context.Background(), `DO RELEASE_LOCK(?)`, m.opts.LockName)
+		if relErr != nil {
+			// Do not hand a session still holding the lock back to the pool.
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+			if err == nil {
+				err = relErr
+			}
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, createSchemaTable); err != nil {
+		return err
+	}
+	return fn(conn)
+}
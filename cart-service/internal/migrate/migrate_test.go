@@ -0,0 +1,116 @@
+package migrate
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+
+	"gorm.io/gorm/schema"
+)
+
+// The embedded migrations create every table the models map, with every
+// column the models read and write.
+func TestMigrationsMatchModels(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var up strings.Builder
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d_%s: versions must run 1, 2, 3 ... without gaps", m.Version, m.Name)
+		}
+		up.WriteString(m.Up)
+	}
+
+	tables := map[string]string{}
+	re := regexp.MustCompile("(?s)CREATE TABLE `(\\w+)` \\((.*?)\n\\) ENGINE")
+	for _, match := range re.FindAllStringSubmatch(up.String(), -1) {
+		tables[match[1]] = match[2]
+	}
+
+	cache := &sync.Map{}
+	for _, model := range []any{
+		domain.Cart{}, domain.CartItem{}, domain.CartPromotion{}, domain.FXRate{}, domain.CartTotals{},
+		domain.CartIdempotency{}, domain.CartOutbox{}, domain.CartOutboxArchive{}, domain.ProcessedEvent{},
+		domain.StoredValueAccount{}, domain.StoredValueHold{}, domain.StoredValueLedgerEntry{}, domain.CheckoutSaga{},
+		domain.CartHistory{},
+	} {
+		s, err := schema.Parse(model, cache, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, ok := tables[s.Table]
+		if !ok {
+			t.Errorf("no migration creates %s", s.Table)
+			continue
+		}
+		for _, col := range s.DBNames {
+			if !strings.Contains(body, "\n  `"+col+"` ") {
+				t.Errorf("%s.%s is not created", s.Table, col)
+			}
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_b.up.sql":   {Data: []byte("CREATE TABLE b (id int);")},
+		"0002_b.down.sql": {Data: []byte("DROP TABLE b;")},
+		"0001_a.up.sql":   {Data: []byte("CREATE TABLE a (id int);")},
+		"0001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+	}
+	got, err := Load(fsys)
+	if err != nil || len(got) != 2 || got[0].Version != 1 || got[1].Name != "b" || got[1].Down != "DROP TABLE b;" {
+		t.Fatalf("Load = %+v, %v", got, err)
+	}
+
+	tests := map[string]fstest.MapFS{
+		"no down": {"0001_a.up.sql": {Data: []byte("x;")}},
+		"empty up": {
+			"0001_a.up.sql":   {Data: []byte(" \n")},
+			"0001_a.down.sql": {Data: []byte("x;")},
+		},
+		"two names for one version": {
+			"0001_a.up.sql":   {Data: []byte("x;")},
+			"0001_a.down.sql": {Data: []byte("x;")},
+			"0001_b.up.sql":   {Data: []byte("x;")},
+			"0001_b.down.sql": {Data: []byte("x;")},
+		},
+		"no direction":  {"0001_a.sql": {Data: []byte("x;")}},
+		"no version":    {"a.up.sql": {Data: []byte("x;")}},
+		"version zero":  {"0000_a.up.sql": {Data: []byte("x;")}},
+		"no name":       {"0001_.up.sql": {Data: []byte("x;")}},
+		"not a version": {"one_a.up.sql": {Data: []byte("x;")}},
+	}
+	for name, fsys := range tests {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
+
+func TestStatements(t *testing.T) {
+	script := `-- Two tables.
+
+CREATE TABLE a (
+  -- the key
+  id int
+);
+DROP TABLE b;
+SELECT 1`
+	got := statements(script)
+	want := []string{"CREATE TABLE a (\n  id int\n)", "DROP TABLE b", "SELECT 1"}
+	if len(got) != len(want) {
+		t.Fatalf("statements = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statement %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
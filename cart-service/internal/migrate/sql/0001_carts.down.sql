@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS `cart_totals`;
+DROP TABLE IF EXISTS `cart_promotions`;
+DROP TABLE IF EXISTS `cart_items`;
+DROP TABLE IF EXISTS `carts`;
@@ -0,0 +1,84 @@
+-- Carts, their lines, promotions and totals.
+
+CREATE TABLE `carts` (
+  `cart_id` binary(16) NOT NULL,
+  `owner_type` varchar(16) NOT NULL,
+  `user_id` binary(16) DEFAULT NULL,
+  `guest_id` varchar(64) DEFAULT NULL,
+  `channel` varchar(32) NOT NULL DEFAULT '',
+  `status` varchar(24) NOT NULL,
+  `currency` char(3) NOT NULL,
+  `locale` varchar(16) DEFAULT NULL,
+  `version` int NOT NULL,
+  `ship_pincode` varchar(16) DEFAULT NULL,
+  `created_at` datetime(3) NOT NULL,
+  `updated_at` datetime(3) NOT NULL,
+  `expires_at` datetime(3) DEFAULT NULL,
+  -- An owner has at most one ACTIVE cart per channel; a concurrent create
+  -- fails on this key and re-reads the winner.
+  `active_owner_key` varbinary(128) GENERATED ALWAYS AS (
+    IF(`status` = 'ACTIVE',
+       CONCAT(`owner_type`, ':', `channel`, ':', IF(`owner_type` = 'USER', `user_id`, `guest_id`)),
+       NULL)
+  ) VIRTUAL,
+  PRIMARY KEY (`cart_id`),
+  UNIQUE KEY `uk_carts_active_owner` (`active_owner_key`),
+  KEY `idx_carts_user` (`user_id`),
+  KEY `idx_carts_guest` (`guest_id`),
+  KEY `idx_carts_status_expires` (`status`, `expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE `cart_items` (
+  `cart_item_id` binary(16) NOT NULL,
+  `cart_id` binary(16) NOT NULL,
+  `sku` varchar(64) NOT NULL,
+  `variant_id` varchar(64) NOT NULL DEFAULT '',
+  `qty` int NOT NULL,
+  `product_name` varchar(255) DEFAULT NULL,
+  `image_url` varchar(1024) DEFAULT NULL,
+  `currency` char(3) NOT NULL,
+  `unit_price_paise` bigint DEFAULT NULL,
+  `mrp_paise` bigint DEFAULT NULL,
+  `tax_rate_bps` int DEFAULT NULL,
+  `product_meta` json DEFAULT NULL,
+  `list_currency` char(3) DEFAULT NULL,
+  `list_unit_price_paise` bigint DEFAULT NULL,
+  `list_mrp_paise` bigint DEFAULT NULL,
+  `fx_rate` decimal(24,12) DEFAULT NULL,
+  `fx_rate_at` datetime(3) DEFAULT NULL,
+  `availability` varchar(16) NOT NULL DEFAULT 'IN_STOCK',
+  `added_at` datetime(3) NOT NULL,
+  `updated_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`cart_item_id`),
+  UNIQUE KEY `uk_cart_items_line` (`cart_id`, `sku`, `variant_id`),
+  KEY `idx_cart_items_sku` (`sku`, `variant_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE `cart_promotions` (
+  `cart_promo_id` binary(16) NOT NULL,
+  `cart_id` binary(16) NOT NULL,
+  `promo_code` varchar(64) NOT NULL,
+  `promo_type` varchar(16) NOT NULL,
+  `discount_paise` bigint NOT NULL,
+  `promo_meta` json DEFAULT NULL,
+  `status` varchar(16) NOT NULL,
+  `applied_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`cart_promo_id`),
+  KEY `idx_cart_promotions_cart` (`cart_id`, `applied_at`),
+  KEY `idx_cart_promotions_code` (`promo_code`, `status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE `cart_totals` (
+  `cart_id` binary(16) NOT NULL,
+  `subtotal_paise` bigint NOT NULL,
+  `tax_paise` bigint NOT NULL,
+  `shipping_paise` bigint NOT NULL,
+  `discount_paise` bigint NOT NULL,
+  `stored_value_paise` bigint NOT NULL DEFAULT 0,
+  `grand_total_paise` bigint NOT NULL,
+  `shipping_rule` varchar(64) DEFAULT NULL,
+  `shipping_meta` json DEFAULT NULL,
+  `pricing_version` int NOT NULL,
+  `computed_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`cart_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,18 @@
+-- Responses of idempotent requests, replayed for retries with the same key.
+
+CREATE TABLE `cart_idempotency` (
+  `client_id` varchar(64) NOT NULL,
+  `idempotency_key` varchar(128) NOT NULL,
+  `endpoint` varchar(255) NOT NULL,
+  `request_hash` varchar(64) NOT NULL,
+  `resource_id` binary(16) DEFAULT NULL,
+  `http_status` smallint DEFAULT NULL,
+  `response_body` json DEFAULT NULL,
+  `response_headers` json DEFAULT NULL,
+  `state` varchar(16) NOT NULL,
+  `locked_at` datetime(3) DEFAULT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  `created_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`client_id`, `idempotency_key`),
+  KEY `idx_cart_idempotency_expires` (`expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
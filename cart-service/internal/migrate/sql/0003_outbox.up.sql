@@ -0,0 +1,37 @@
+-- Events waiting to be published, and published rows swept out of the way.
+
+CREATE TABLE `cart_outbox` (
+  `outbox_id` binary(16) NOT NULL,
+  `aggregate_type` varchar(32) NOT NULL,
+  `aggregate_id` binary(16) NOT NULL,
+  `event_type` varchar(128) NOT NULL,
+  `payload` json NOT NULL,
+  `status` varchar(16) NOT NULL,
+  `seq` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `lease_owner` varchar(128) DEFAULT NULL,
+  `lease_until` datetime(3) DEFAULT NULL,
+  `attempts` int NOT NULL DEFAULT 0,
+  `last_error` text,
+  `next_attempt_at` datetime(3) DEFAULT NULL,
+  `created_at` datetime(3) NOT NULL,
+  `published_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`outbox_id`),
+  UNIQUE KEY `uk_cart_outbox_seq` (`seq`),
+  KEY `idx_outbox_aggregate_seq` (`aggregate_id`, `seq`),
+  KEY `idx_cart_outbox_status_next_attempt` (`status`, `next_attempt_at`),
+  KEY `idx_cart_outbox_status_published` (`status`, `published_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE `cart_outbox_archive` (
+  `outbox_id` binary(16) NOT NULL,
+  `aggregate_type` varchar(32) NOT NULL,
+  `aggregate_id` binary(16) NOT NULL,
+  `event_type` varchar(128) NOT NULL,
+  `payload` json NOT NULL,
+  `status` varchar(16) NOT NULL,
+  `created_at` datetime(3) NOT NULL,
+  `published_at` datetime(3) DEFAULT NULL,
+  `archived_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`outbox_id`),
+  KEY `idx_cart_outbox_archive_aggregate` (`aggregate_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,10 @@
+-- Consumed events, so redelivered messages are applied once.
+
+CREATE TABLE `processed_events` (
+  `event_id` binary(16) NOT NULL,
+  `consumer` varchar(64) NOT NULL,
+  `event_type` varchar(128) NOT NULL,
+  `correlation_id` binary(16) DEFAULT NULL,
+  `processed_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`event_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
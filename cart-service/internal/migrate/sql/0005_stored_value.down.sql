@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `stored_value_ledger`;
+DROP TABLE IF EXISTS `stored_value_holds`;
+DROP TABLE IF EXISTS `stored_value_accounts`;
@@ -0,0 +1,52 @@
+-- Gift card and wallet balances, holds against carts, and the ledger of
+-- every movement.
+
+CREATE TABLE `stored_value_accounts` (
+  `account_id` binary(16) NOT NULL,
+  `kind` varchar(16) NOT NULL,
+  `code` varchar(64) DEFAULT NULL,
+  `owner_user_id` binary(16) DEFAULT NULL,
+  `currency` char(3) NOT NULL,
+  `status` varchar(16) NOT NULL,
+  `balance_paise` bigint NOT NULL,
+  `held_paise` bigint NOT NULL,
+  `expires_at` datetime(3) DEFAULT NULL,
+  `created_at` datetime(3) NOT NULL,
+  `updated_at` datetime(3) NOT NULL,
+  -- One gift card per code and one wallet per user.
+  `gift_card_code` varchar(64) GENERATED ALWAYS AS (IF(`kind` = 'GIFT_CARD', `code`, NULL)) VIRTUAL,
+  `wallet_owner_id` binary(16) GENERATED ALWAYS AS (IF(`kind` = 'WALLET', `owner_user_id`, NULL)) VIRTUAL,
+  PRIMARY KEY (`account_id`),
+  UNIQUE KEY `uk_stored_value_gift_card` (`gift_card_code`),
+  UNIQUE KEY `uk_stored_value_wallet` (`wallet_owner_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE `stored_value_holds` (
+  `hold_id` binary(16) NOT NULL,
+  `account_id` binary(16) NOT NULL,
+  `cart_id` binary(16) NOT NULL,
+  `cart_promo_id` binary(16) NOT NULL,
+  `amount_paise` bigint NOT NULL,
+  `status` varchar(16) NOT NULL,
+  `created_at` datetime(3) NOT NULL,
+  `updated_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`hold_id`),
+  KEY `idx_stored_value_holds_account` (`account_id`),
+  KEY `idx_stored_value_holds_cart` (`cart_id`),
+  KEY `idx_stored_value_holds_promo` (`cart_promo_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE `stored_value_ledger` (
+  `entry_id` binary(16) NOT NULL,
+  `account_id` binary(16) NOT NULL,
+  `hold_id` binary(16) DEFAULT NULL,
+  `cart_id` binary(16) DEFAULT NULL,
+  `entry_type` varchar(16) NOT NULL,
+  `amount_paise` bigint NOT NULL,
+  `balance_after_paise` bigint NOT NULL,
+  `held_after_paise` bigint NOT NULL,
+  `reason` varchar(255) DEFAULT NULL,
+  `created_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`entry_id`),
+  KEY `idx_stored_value_ledger_account` (`account_id`, `created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,12 @@
+-- Published exchange rates: one base_currency buys rate quote_currency from
+-- effective_at until the next row for the pair.
+
+CREATE TABLE `fx_rates` (
+  `base_currency` char(3) NOT NULL,
+  `quote_currency` char(3) NOT NULL,
+  `effective_at` datetime(3) NOT NULL,
+  `rate` decimal(24,12) NOT NULL,
+  `source` varchar(64) DEFAULT NULL,
+  `created_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`base_currency`, `quote_currency`, `effective_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
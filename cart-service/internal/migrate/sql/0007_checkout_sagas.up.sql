@@ -0,0 +1,20 @@
+-- Checkout attempts and the inventory reservations each one made.
+
+CREATE TABLE `checkout_sagas` (
+  `saga_id` binary(16) NOT NULL,
+  `cart_id` binary(16) NOT NULL,
+  `status` varchar(16) NOT NULL,
+  `lines` json NOT NULL,
+  `failure_reason` varchar(32) DEFAULT NULL,
+  `error` text,
+  `client_id` varchar(64) DEFAULT NULL,
+  `idempotency_key` varchar(128) DEFAULT NULL,
+  `traceparent` varchar(64) DEFAULT NULL,
+  `lease_until` datetime(3) NOT NULL,
+  `created_at` datetime(3) NOT NULL,
+  `updated_at` datetime(3) NOT NULL,
+  `completed_at` datetime(3) DEFAULT NULL,
+  PRIMARY KEY (`saga_id`),
+  KEY `idx_saga_cart_created` (`cart_id`, `created_at`),
+  KEY `idx_saga_status_lease` (`status`, `lease_until`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
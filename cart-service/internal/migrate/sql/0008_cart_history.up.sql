@@ -0,0 +1,21 @@
+-- The audit trail of every cart: append-only, never swept.
+
+CREATE TABLE `cart_history` (
+  `entry_id` binary(16) NOT NULL,
+  `cart_id` binary(16) NOT NULL,
+  `seq` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `cart_version` int NOT NULL,
+  `action` varchar(32) NOT NULL,
+  `actor` varchar(128) NOT NULL,
+  `idempotency_key` varchar(128) DEFAULT NULL,
+  `related_cart_id` binary(16) DEFAULT NULL,
+  `status_before` varchar(24) DEFAULT NULL,
+  `status_after` varchar(24) NOT NULL,
+  `grand_total_before_paise` bigint NOT NULL,
+  `grand_total_after_paise` bigint NOT NULL,
+  `changes` json NOT NULL,
+  `created_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`entry_id`),
+  UNIQUE KEY `uk_cart_history_seq` (`seq`),
+  KEY `idx_history_cart_seq` (`cart_id`, `seq`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,3 @@
+-- Fails while two consumers have recorded the same event.
+
+ALTER TABLE `processed_events` DROP PRIMARY KEY, ADD PRIMARY KEY (`event_id`);
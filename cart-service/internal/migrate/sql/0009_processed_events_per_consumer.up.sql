@@ -0,0 +1,4 @@
+-- Each consumer records the events it processed, so several consumers can
+-- process the same event.
+
+ALTER TABLE `processed_events` DROP PRIMARY KEY, ADD PRIMARY KEY (`event_id`, `consumer`);
@@ -0,0 +1,111 @@
+// Package money knows how many minor units each supported currency has.
+//
+// Every amount in cart-service is an int64 count of the cart currency's
+// minor unit. The *_paise names predate multi-currency carts: for a USD cart
+// they hold cents, for a JPY cart whole yen and for a KWD cart fils.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ErrUnknownCurrency means the code is not a supported ISO 4217 currency.
+var ErrUnknownCurrency = errors.New("money: unknown currency")
+
+// minorUnits is the ISO 4217 exponent of each supported currency.
+var minorUnits = map[string]int{
+	"AED": 2, "AUD": 2, "BDT": 2, "CAD": 2, "CHF": 2, "CNY": 2, "EUR": 2,
+	"GBP": 2, "HKD": 2, "INR": 2, "LKR": 2, "MYR": 2, "NPR": 2, "NZD": 2,
+	"QAR": 2, "SAR": 2, "SEK": 2, "SGD": 2, "THB": 2, "USD": 2, "ZAR": 2,
+	"CLP": 0, "IDR": 2, "ISK": 0, "JPY": 0, "KRW": 0, "VND": 0,
+	"BHD": 3, "JOD": 3, "KWD": 3, "OMR": 3, "TND": 3,
+}
+
+// Normalize upper-cases and trims a currency code.
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// MinorUnits returns the number of decimal places of code.
+func MinorUnits(code string) (int, bool) {
+	n, ok := minorUnits[code]
+	return n, ok
+}
+
+// Known reports whether code is a supported currency.
+func Known(code string) bool {
+	_, ok := minorUnits[code]
+	return ok
+}
+
+// Convert converts amount minor units of from into minor units of to, where
+// one major unit of from buys rate major units of to. The result is rounded
+// half away from zero.
+func Convert(amount int64, from, to string, rate *big.Rat) (int64, error) {
+	fromExp, ok := MinorUnits(from)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, from)
+	}
+	toExp, ok := MinorUnits(to)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, to)
+	}
+	if rate == nil || rate.Sign() <= 0 {
+		return 0, fmt.Errorf("money: invalid rate %v for %s to %s", rate, from, to)
+	}
+
+	v := new(big.Rat).Mul(new(big.Rat).SetInt64(amount), rate)
+	if d := toExp - fromExp; d != 0 {
+		scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(d))), nil))
+		if d > 0 {
+			v.Mul(v, scale)
+		} else {
+			v.Quo(v, scale)
+		}
+	}
+
+	q, r := new(big.Int).QuoRem(v.Num(), v.Denom(), new(big.Int))
+	if r.Sign() != 0 && new(big.Int).Mul(r.Abs(r), big.NewInt(2)).Cmp(v.Denom()) >= 0 {
+		if v.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	if !q.IsInt64() {
+		return 0, fmt.Errorf("money: %d %s overflows when converted to %s", amount, from, to)
+	}
+	return q.Int64(), nil
+}
+
+// Format renders amount minor units of code as a decimal string in major
+// units, e.g. 123450 INR is "1234.50" and 1234 JPY is "1234". Unknown
+// currencies are assumed to have two decimals.
+func Format(amount int64, code string) string {
+	exp, ok := MinorUnits(code)
+	if !ok {
+		exp = 2
+	}
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+	digits := new(big.Int).Abs(big.NewInt(amount)).String()
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
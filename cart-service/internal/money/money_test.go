@@ -0,0 +1,76 @@
+package money
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func rat(s string) *big.Rat {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		panic(s)
+	}
+	return r
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   int64
+		from, to string
+		rate     string
+		want     int64
+	}{
+		{"usd to inr", 1999, "USD", "INR", "83.25", 166417},    // 19.99 * 83.25 = 1664.1675
+		{"inr to usd", 166417, "INR", "USD", "0.012012", 1999}, // 1664.17 * 0.012012 = 19.9900...
+		{"usd to jpy drops decimals", 1999, "USD", "JPY", "151.5", 3028},
+		{"jpy to usd adds decimals", 3028, "JPY", "USD", "0.0066", 1998}, // 19.9848
+		{"inr to kwd three decimals", 100000, "INR", "KWD", "0.0037", 3700},
+		{"half rounds away from zero", 1, "INR", "USD", "0.5", 1},
+		{"negative half", -1, "INR", "USD", "0.5", -1},
+		{"same currency", 12345, "INR", "INR", "1", 12345},
+	}
+	for _, tc := range tests {
+		got, err := Convert(tc.amount, tc.from, tc.to, rat(tc.rate))
+		if err != nil || got != tc.want {
+			t.Errorf("%s: Convert(%d) = %d, %v; want %d", tc.name, tc.amount, got, err, tc.want)
+		}
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	if _, err := Convert(100, "XXX", "INR", rat("1")); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("unknown from: %v", err)
+	}
+	if _, err := Convert(100, "INR", "XXX", rat("1")); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("unknown to: %v", err)
+	}
+	if _, err := Convert(100, "INR", "USD", rat("0")); err == nil {
+		t.Error("zero rate accepted")
+	}
+	if _, err := Convert(1<<62, "JPY", "KWD", rat("1000")); err == nil {
+		t.Error("overflow not reported")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		amount int64
+		code   string
+		want   string
+	}{
+		{123450, "INR", "1234.50"},
+		{5, "USD", "0.05"},
+		{0, "USD", "0.00"},
+		{-250, "EUR", "-2.50"},
+		{1234, "JPY", "1234"},
+		{1500, "KWD", "1.500"},
+		{7, "KWD", "0.007"},
+	}
+	for _, tc := range tests {
+		if got := Format(tc.amount, tc.code); got != tc.want {
+			t.Errorf("Format(%d, %s) = %q, want %q", tc.amount, tc.code, got, tc.want)
+		}
+	}
+}
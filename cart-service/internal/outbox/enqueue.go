@@ -0,0 +1,49 @@
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/events"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+	"github.com/google/uuid"
+)
+
+const (
+	Producer          = "cart-service"
+	AggregateTypeCart = "CART"
+)
+
+// Enqueue writes ev to out, the caller's transaction's outbox, as an event of
+// the cart cartID, filling in the event id, producer and time when unset. ev.Data
+// must match the schema of ev.EventType in the event catalog; a payload that
+// does not fails the caller's transaction rather than reaching consumers.
+func Enqueue(out repo.OutboxRepository, cartID []byte, ev domain.EventEnvelope) error {
+	data, err := events.Marshal(ev.EventType, ev.Data)
+	if err != nil {
+		return err
+	}
+	ev.Data = data
+	if ev.EventID == "" {
+		ev.EventID = uuid.NewString()
+	}
+	if ev.Producer == "" {
+		ev.Producer = Producer
+	}
+	if ev.OccurredAt.IsZero() {
+		ev.OccurredAt = time.Now().UTC()
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return out.Add(&domain.CartOutbox{
+		OutboxID:      domain.UUIDToBin16(uuid.New()),
+		AggregateType: AggregateTypeCart,
+		AggregateID:   cartID,
+		EventType:     ev.EventType,
+		Payload:       string(payload),
+		Status:        StatusNew,
+	})
+}
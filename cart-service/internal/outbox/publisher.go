@@ -2,64 +2,348 @@ package outbox
 
 import (
 	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
 	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/kafka"
+	"github.com/dhananjayksharma/golang-k8s-microservices/pkg/cloudevents"
 	"gorm.io/gorm"
 )
 
+// Outbox row states.
+const (
+	StatusNew       = "NEW"
+	StatusFailed    = "FAILED" // publish failed, retried at next_attempt_at
+	StatusPublished = "PUBLISHED"
+	StatusDead      = "DEAD" // gave up after MaxAttempts; see Requeue
+)
+
+// Metrics are published under "cart_outbox" on /debug/vars.
+var metrics = expvar.NewMap("cart_outbox")
+
+// Several publishers (goroutines and worker replicas) share the table:
+//
+//  1. A short transaction claims rows with FOR UPDATE SKIP LOCKED and stamps
+//     them with a lease, so concurrent claimers skip each other's rows.
+//  2. Only the lowest pending seq of each aggregate is claimable, and a
+//     leased row still counts as pending, so events of one cart are published
+//     one at a time and in order. DEAD rows no longer block their aggregate.
+//  3. Kafka writes happen after commit; results are written back guarded by
+//     the lease owner. A worker that dies leaves rows whose lease expires and
+//     which are then claimed again (delivery is at-least-once).
+
+type Options struct {
+	MaxAttempts int           // failed publishes before a row goes DEAD
+	BackoffBase time.Duration // delay after the first failure
+	BackoffMax  time.Duration // cap on the delay between attempts
+
+	Workers      int           // concurrent claim loops in this process
+	BatchSize    int           // rows claimed per loop iteration
+	PollInterval time.Duration // wait when there was nothing to claim
+	Lease        time.Duration // how long a claim is held before others may take it
+
+	// CloudEvents publishes rows as CloudEvents 1.0 in this mode. Empty
+	// publishes the EventEnvelope JSON as before.
+	CloudEvents cloudevents.Mode
+}
+
 type Publisher struct {
 	db       *gorm.DB
 	producer *kafka.Producer
+	opts     Options
+	id       string
 }
 
-func NewPublisher(db *gorm.DB, producer *kafka.Producer) *Publisher {
-	return &Publisher{db: db, producer: producer}
+func NewPublisher(db *gorm.DB, producer *kafka.Producer, opts Options) *Publisher {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 10
+	}
+	if opts.BackoffBase <= 0 {
+		opts.BackoffBase = time.Second
+	}
+	if opts.BackoffMax <= 0 {
+		opts.BackoffMax = 5 * time.Minute
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 50
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 200 * time.Millisecond
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = 30 * time.Second
+	}
+	host, _ := os.Hostname()
+	return &Publisher{db: db, producer: producer, opts: opts, id: fmt.Sprintf("%s-%d", host, os.Getpid())}
 }
 
+// Run starts opts.Workers claim loops and blocks until ctx is done.
 func (p *Publisher) Run(ctx context.Context) error {
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
+	var wg sync.WaitGroup
+	for i := 0; i < p.opts.Workers; i++ {
+		wg.Add(1)
+		go func(owner string) {
+			defer wg.Done()
+			p.loop(ctx, owner)
+		}(fmt.Sprintf("%s-%d", p.id, i))
+	}
+	wg.Wait()
+	return ctx.Err()
+}
 
+func (p *Publisher) loop(ctx context.Context, owner string) {
 	for {
+		n, err := p.publishBatch(ctx, owner)
+		if err != nil {
+			log.Printf("outbox: %v\n", err)
+		}
+		// A full batch means there is a backlog: go again straight away.
+		if err == nil && n == p.opts.BatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
-			_ = p.publishBatch(ctx, 50)
+			return
+		case <-time.After(p.opts.PollInterval):
+		}
+	}
+}
+
+// publishBatch claims up to BatchSize rows, publishes them and records the
+// outcome. It returns the number of rows claimed.
+func (p *Publisher) publishBatch(ctx context.Context, owner string) (int, error) {
+	rows, err := p.claim(ctx, owner)
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+
+	// Claimed rows belong to different aggregates, so they can be written
+	// concurrently without breaking per-aggregate order.
+	errs := make([]error, len(rows))
+	var wg sync.WaitGroup
+	for i := range rows {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = p.publish(ctx, rows[i])
+		}(i)
+	}
+	wg.Wait()
+
+	now := time.Now().UTC()
+	for i, r := range rows {
+		updates := map[string]any{"status": StatusPublished, "published_at": &now, "last_error": ""}
+		if errs[i] != nil {
+			updates = p.failure(r.Attempts+1, errs[i], now)
+		} else {
+			metrics.Add("published", 1)
+		}
+		updates["lease_owner"] = ""
+		updates["lease_until"] = nil
+		if err := p.db.WithContext(context.WithoutCancel(ctx)).Model(&domain.CartOutbox{}).
+			Where("outbox_id = ? AND lease_owner = ?", r.OutboxID, owner).
+			Updates(updates).Error; err != nil {
+			return len(rows), err
+		}
+	}
+	return len(rows), nil
+}
+
+func (p *Publisher) publish(ctx context.Context, r domain.CartOutbox) error {
+	key, headers := message(r)
+	if p.opts.CloudEvents == "" {
+		return p.producer.Publish(ctx, key, []byte(r.Payload), headers...)
+	}
+	return p.producer.PublishEvent(ctx, key, cloudEvent(r), p.opts.CloudEvents)
+}
+
+// message derives the Kafka key and headers of an outbox row. The key is the
+// canonical aggregate UUID so all events of a cart land on one partition;
+// headers repeat the envelope metadata so consumers can route and filter
+// without decoding the payload.
+func message(r domain.CartOutbox) (string, []kafka.Header) {
+	key := bin16String(r.AggregateID)
+
+	var ev domain.EventEnvelope
+	_ = json.Unmarshal([]byte(r.Payload), &ev)
+	if ev.EventType == "" {
+		ev.EventType = r.EventType
+	}
+	headers := []kafka.Header{
+		{Key: "content-type", Value: "application/json"},
+		{Key: "event_type", Value: ev.EventType},
+	}
+	for _, h := range []kafka.Header{
+		{Key: "event_id", Value: ev.EventID},
+		{Key: "producer", Value: ev.Producer},
+		{Key: "correlation_id", Value: ev.CorrelationID},
+		{Key: "traceparent", Value: ev.TraceParent},
+	} {
+		if h.Value != "" {
+			headers = append(headers, h)
 		}
 	}
+	return key, headers
 }
 
-func (p *Publisher) publishBatch(ctx context.Context, limit int) error {
-	return p.db.Transaction(func(tx *gorm.DB) error {
-		var rows []domain.CartOutbox
+// cloudEvent maps an outbox row onto a CloudEvent: the envelope's metadata
+// become attributes and its data the event data. The cart is the subject;
+// correlation_id and traceparent travel as extensions. Rows written before
+// the envelope existed are published whole, identified by their outbox_id.
+func cloudEvent(r domain.CartOutbox) cloudevents.Event {
+	var ev struct {
+		domain.EventEnvelope
+		Data json.RawMessage `json:"data"`
+	}
+	_ = json.Unmarshal([]byte(r.Payload), &ev)
+
+	e := cloudevents.Event{
+		ID:              ev.EventID,
+		Source:          "/" + ev.Producer,
+		Type:            ev.EventType,
+		Subject:         bin16String(r.AggregateID),
+		DataContentType: cloudevents.ContentTypeJSON,
+		Time:            ev.OccurredAt,
+		Data:            ev.Data,
+	}
+	if e.ID == "" {
+		e.ID = bin16String(r.OutboxID)
+	}
+	if ev.Producer == "" {
+		e.Source = "/" + Producer
+	}
+	if e.Type == "" {
+		e.Type = r.EventType
+		e.Data = []byte(r.Payload)
+	}
+	if e.Time.IsZero() {
+		e.Time = r.CreatedAt
+	}
+	for name, v := range map[string]string{"correlationid": ev.CorrelationID, "traceparent": ev.TraceParent} {
+		if v != "" {
+			if e.Extensions == nil {
+				e.Extensions = map[string]string{}
+			}
+			e.Extensions[name] = v
+		}
+	}
+	return e
+}
+
+func bin16String(b []byte) string {
+	if u, err := domain.Bin16ToUUID(b); err == nil {
+		return u.String()
+	}
+	return string(b)
+}
+
+// claim leases the next publishable rows to owner.
+func (p *Publisher) claim(ctx context.Context, owner string) ([]domain.CartOutbox, error) {
+	var rows []domain.CartOutbox
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
 		if err := tx.Raw(`
-			SELECT * FROM cart_outbox
-			WHERE status='NEW'
-			ORDER BY created_at ASC
+			SELECT o.* FROM cart_outbox o
+			WHERE o.status IN ('NEW','FAILED')
+			  AND (o.next_attempt_at IS NULL OR o.next_attempt_at <= ?)
+			  AND (o.lease_until IS NULL OR o.lease_until < ?)
+			  AND NOT EXISTS (
+				SELECT 1 FROM cart_outbox prev
+				WHERE prev.aggregate_id = o.aggregate_id
+				  AND prev.seq < o.seq
+				  AND prev.status IN ('NEW','FAILED')
+			  )
+			ORDER BY o.seq ASC
 			LIMIT ?
-			FOR UPDATE
-		`, limit).Scan(&rows).Error; err != nil {
+			FOR UPDATE SKIP LOCKED
+		`, now, now, p.opts.BatchSize).Scan(&rows).Error; err != nil {
 			return err
 		}
-
-		for _, r := range rows {
-			key := string(r.AggregateID)
-			if err := p.producer.Publish(ctx, key, []byte(r.Payload)); err != nil {
-				_ = tx.Model(&domain.CartOutbox{}).
-					Where("outbox_id = ?", r.OutboxID).
-					Update("status", "FAILED").Error
-				continue
-			}
-			now := time.Now()
-			if err := tx.Model(&domain.CartOutbox{}).
-				Where("outbox_id = ?", r.OutboxID).
-				Updates(map[string]any{"status": "PUBLISHED", "published_at": &now}).Error; err != nil {
-				return err
-			}
+		if len(rows) == 0 {
+			return nil
+		}
+		ids := make([][]byte, len(rows))
+		for i, r := range rows {
+			ids[i] = r.OutboxID
 		}
-		return nil
+		return tx.Model(&domain.CartOutbox{}).
+			Where("outbox_id IN ?", ids).
+			Updates(map[string]any{"lease_owner": owner, "lease_until": now.Add(p.opts.Lease)}).Error
+	})
+	return rows, err
+}
+
+// failure records a failed attempt and schedules the next one, or marks the
+// row DEAD once attempts reaches MaxAttempts.
+func (p *Publisher) failure(attempts int, err error, now time.Time) map[string]any {
+	updates := map[string]any{
+		"attempts":   attempts,
+		"last_error": truncate(err.Error(), 1000),
+	}
+	if attempts >= p.opts.MaxAttempts {
+		metrics.Add("dead", 1)
+		updates["status"] = StatusDead
+		updates["next_attempt_at"] = nil
+		return updates
+	}
+	metrics.Add("failed", 1)
+	next := now.Add(Backoff(attempts, p.opts.BackoffBase, p.opts.BackoffMax, rand.Int63n))
+	updates["status"] = StatusFailed
+	updates["next_attempt_at"] = &next
+	return updates
+}
+
+// Backoff is the delay before retry number attempts (1-based): exponential
+// from base, capped at max, with "equal jitter" so a burst of failures does
+// not retry in lockstep. randN returns a value in [0, n).
+func Backoff(attempts int, base, max time.Duration, randN func(n int64) int64) time.Duration {
+	d := base
+	for i := 1; i < attempts && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(randN(int64(half)+1))
+}
+
+// Requeue moves DEAD rows back to NEW with a fresh attempt budget. With no ids
+// every DEAD row is requeued. It returns the number of rows requeued.
+func (p *Publisher) Requeue(ctx context.Context, ids [][]byte) (int64, error) {
+	q := p.db.WithContext(ctx).Model(&domain.CartOutbox{}).Where("status = ?", StatusDead)
+	if len(ids) > 0 {
+		q = q.Where("outbox_id IN ?", ids)
+	}
+	res := q.Updates(map[string]any{
+		"status":          StatusNew,
+		"attempts":        0,
+		"next_attempt_at": nil,
+		"lease_owner":     "",
+		"lease_until":     nil,
 	})
+	return res.RowsAffected, res.Error
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
 }
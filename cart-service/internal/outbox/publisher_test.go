@@ -0,0 +1,149 @@
+package outbox
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/pkg/cloudevents"
+
+	"github.com/google/uuid"
+)
+
+func TestBackoff(t *testing.T) {
+	noJitter := func(int64) int64 { return 0 }
+	fullJitter := func(n int64) int64 { return n - 1 }
+
+	tests := []struct {
+		attempts int
+		min, max time.Duration
+	}{
+		{1, 500 * time.Millisecond, time.Second},
+		{2, time.Second, 2 * time.Second},
+		{4, 4 * time.Second, 8 * time.Second},
+		{10, 30 * time.Second, time.Minute}, // capped
+		{60, 30 * time.Second, time.Minute}, // no overflow
+	}
+	for _, tc := range tests {
+		lo := Backoff(tc.attempts, time.Second, time.Minute, noJitter)
+		hi := Backoff(tc.attempts, time.Second, time.Minute, fullJitter)
+		if lo != tc.min || hi != tc.max {
+			t.Errorf("attempt %d: got [%s, %s], want [%s, %s]", tc.attempts, lo, hi, tc.min, tc.max)
+		}
+	}
+}
+
+func TestFailureGoesDeadAfterMaxAttempts(t *testing.T) {
+	p := NewPublisher(nil, nil, Options{MaxAttempts: 3})
+	now := time.Now()
+	boom := errors.New("broker unavailable")
+
+	u := p.failure(2, boom, now)
+	if u["status"] != StatusFailed || u["attempts"] != 2 || u["last_error"] != boom.Error() {
+		t.Fatalf("attempt 2: %v", u)
+	}
+	if next, ok := u["next_attempt_at"].(*time.Time); !ok || !next.After(now) {
+		t.Fatalf("attempt 2 not rescheduled: %v", u["next_attempt_at"])
+	}
+
+	u = p.failure(3, boom, now)
+	if u["status"] != StatusDead || u["next_attempt_at"] != nil {
+		t.Fatalf("attempt 3: %v", u)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	cartID := uuid.MustParse("6f1c2a8e-0b7d-4c55-9a1e-3f2b8c9d0e11")
+	row := domain.CartOutbox{
+		AggregateID: domain.UUIDToBin16(cartID),
+		EventType:   "CartItemAdded.v1",
+		Payload: `{"event_id":"e-1","event_type":"CartItemAdded.v1","producer":"cart-service",` +
+			`"correlation_id":"6f1c2a8e-0b7d-4c55-9a1e-3f2b8c9d0e11",` +
+			`"traceparent":"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01","data":{}}`,
+	}
+
+	key, headers := message(row)
+	if key != cartID.String() {
+		t.Fatalf("key = %q, want %q", key, cartID.String())
+	}
+	want := map[string]string{
+		"content-type":   "application/json",
+		"event_type":     "CartItemAdded.v1",
+		"event_id":       "e-1",
+		"producer":       "cart-service",
+		"correlation_id": cartID.String(),
+		"traceparent":    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}
+	if len(headers) != len(want) {
+		t.Fatalf("headers = %v", headers)
+	}
+	for _, h := range headers {
+		if want[h.Key] != h.Value {
+			t.Errorf("header %s = %q, want %q", h.Key, h.Value, want[h.Key])
+		}
+	}
+}
+
+func TestMessageLegacyPayload(t *testing.T) {
+	// Rows written before the envelope carried a trace still get a key and
+	// event_type, and no empty headers.
+	row := domain.CartOutbox{
+		AggregateID: domain.UUIDToBin16(uuid.New()),
+		EventType:   "CartCheckedOut",
+		Payload:     `{"cart_id":"x","status":"CHECKED_OUT"}`,
+	}
+	_, headers := message(row)
+	if len(headers) != 2 || headers[1].Value != "CartCheckedOut" {
+		t.Fatalf("headers = %v", headers)
+	}
+}
+
+func TestCloudEvent(t *testing.T) {
+	cartID := uuid.MustParse("6f1c2a8e-0b7d-4c55-9a1e-3f2b8c9d0e11")
+	row := domain.CartOutbox{
+		OutboxID:    domain.UUIDToBin16(uuid.MustParse("0b7e6a52-1c1a-4b0e-9d61-3f2f1f1b2c3d")),
+		AggregateID: domain.UUIDToBin16(cartID),
+		EventType:   "CartItemAdded.v2",
+		Payload: `{"event_id":"e-1","event_type":"CartItemAdded.v2","producer":"cart-service",` +
+			`"occurred_at":"2026-03-01T10:30:00Z","correlation_id":"6f1c2a8e-0b7d-4c55-9a1e-3f2b8c9d0e11",` +
+			`"traceparent":"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01","data":{"sku":"SOCK-001"}}`,
+	}
+
+	e := cloudEvent(row)
+	want := cloudevents.Event{
+		ID:              "e-1",
+		Source:          "/cart-service",
+		Type:            "CartItemAdded.v2",
+		Subject:         cartID.String(),
+		DataContentType: "application/json",
+		Time:            time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC),
+		Extensions: map[string]string{
+			"correlationid": cartID.String(),
+			"traceparent":   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+		Data: []byte(`{"sku":"SOCK-001"}`),
+	}
+	if !reflect.DeepEqual(e, want) {
+		t.Fatalf("cloudEvent:\n got %+v\nwant %+v", e, want)
+	}
+
+	// Rows written before the envelope are published whole.
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	legacy := domain.CartOutbox{
+		OutboxID:    row.OutboxID,
+		AggregateID: row.AggregateID,
+		EventType:   "CartCheckedOut",
+		Payload:     `{"cart_id":"x","status":"CHECKED_OUT"}`,
+		CreatedAt:   created,
+	}
+	e = cloudEvent(legacy)
+	if e.ID != "0b7e6a52-1c1a-4b0e-9d61-3f2f1f1b2c3d" || e.Source != "/cart-service" || e.Type != "CartCheckedOut" ||
+		!e.Time.Equal(created) || string(e.Data) != legacy.Payload || e.Extensions != nil {
+		t.Errorf("legacy row: %+v", e)
+	}
+	if err := e.Validate(); err != nil {
+		t.Errorf("legacy row: %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/fx"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/promo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/shipping"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/storedvalue"
+)
+
+// Repricer re-prices a locked cart inside the caller's transaction: it
+// re-validates coupons, quotes shipping, runs domain.ComputePricing and
+// rebuilds cart_totals. Both the HTTP handlers and the worker's catalog
+// consumer use it so totals never depend on who changed the cart.
+type Repricer struct {
+	promos   *promo.Engine        // nil: coupons are left as they are
+	shipping *shipping.Calculator // nil: shipping is free
+	rates    *fx.Rates            // nil: shipping rules must be in the cart's currency
+}
+
+func NewRepricer(promos *promo.Engine, calc *shipping.Calculator, rates *fx.Rates) *Repricer {
+	return &Repricer{promos: promos, shipping: calc, rates: rates}
+}
+
+// Priced is a cart priced without persisting totals.
+type Priced struct {
+	Items    []domain.CartItem
+	Promos   []domain.CartPromotion
+	Shipping shipping.Quote
+	Summary  domain.PricingSummary
+}
+
+// Price loads the lines and live promotions, re-validates coupons, quotes
+// shipping and prices the cart. It does not persist totals, but coupons whose
+// status or discount changed on re-validation are saved.
+func (r *Repricer) Price(tx repo.Tx, cart *domain.Cart) (*Priced, error) {
+	var p Priced
+	var err error
+	if p.Items, err = tx.Items().List(cart.CartID); err != nil {
+		return nil, err
+	}
+	if p.Promos, err = tx.Promotions().List(cart.CartID, repo.PromotionFilter{Statuses: []string{"APPLIED", "SUSPENDED"}}); err != nil {
+		return nil, err
+	}
+	if err := r.revalidateCoupons(tx, cart, p.Items, p.Promos); err != nil {
+		return nil, err
+	}
+
+	// Free shipping thresholds look at the discounted value, so price once
+	// without shipping first.
+	p.Summary = domain.ComputePricing(p.Items, p.Promos, 0)
+	if r.shipping != nil {
+		q, err := r.quoteShipping(tx, cart, p.Items, p.Summary.SubtotalPaise-p.Summary.DiscountPaise)
+		if err != nil {
+			return nil, err
+		}
+		p.Shipping = q
+		p.Summary = domain.ComputePricing(p.Items, p.Promos, p.Shipping.Paise)
+	}
+	return &p, nil
+}
+
+// quoteShipping quotes in the shipping rules' currency, converting the order
+// value there and the charge and threshold back into the cart's currency.
+func (r *Repricer) quoteShipping(tx repo.Tx, cart *domain.Cart, items []domain.CartItem, orderValue int64) (shipping.Quote, error) {
+	in := shipping.Input{Items: items, Pincode: cart.ShipPincode, Channel: cart.Channel, OrderValuePaise: orderValue}
+	rulesCurrency := r.shipping.Currency()
+	if rulesCurrency == cart.Currency {
+		return r.shipping.Quote(in), nil
+	}
+	if r.rates == nil {
+		return shipping.Quote{}, fmt.Errorf("pricing: shipping rules are in %s, cart is in %s: %w", rulesCurrency, cart.Currency, fx.ErrNoRate)
+	}
+	there, err := r.rates.Lookup(tx.FXRates(), cart.Currency, rulesCurrency)
+	if err != nil {
+		return shipping.Quote{}, err
+	}
+	back, err := r.rates.Lookup(tx.FXRates(), rulesCurrency, cart.Currency)
+	if err != nil {
+		return shipping.Quote{}, err
+	}
+	if in.OrderValuePaise, err = there.Convert(orderValue); err != nil {
+		return shipping.Quote{}, err
+	}
+	q := r.shipping.Quote(in)
+	if q.Paise, err = back.Convert(q.Paise); err != nil {
+		return shipping.Quote{}, err
+	}
+	if q.ThresholdPaise, err = back.Convert(q.ThresholdPaise); err != nil {
+		return shipping.Quote{}, err
+	}
+	q.Currency = cart.Currency
+	return q, nil
+}
+
+// Recompute re-prices the cart, trims stored value holds to what is payable
+// and rebuilds cart_totals.
+func (r *Repricer) Recompute(tx repo.Tx, cart *domain.Cart) error {
+	_, err := r.Reprice(tx, cart)
+	return err
+}
+
+// Reprice is Recompute returning what it priced, for callers that need the
+// lines and promotions the totals were built from.
+func (r *Repricer) Reprice(tx repo.Tx, cart *domain.Cart) (*Priced, error) {
+	priced, err := r.Price(tx, cart)
+	if err != nil {
+		return nil, err
+	}
+	sum := priced.Summary
+	if err := trimStoredValue(tx, priced.Promos, sum.StoredValuePaise); err != nil {
+		return nil, err
+	}
+	shippingMeta, _ := json.Marshal(priced.Shipping)
+	totals := domain.CartTotals{
+		CartID:          cart.CartID,
+		SubtotalPaise:   sum.SubtotalPaise,
+		TaxPaise:        sum.TaxPaise,
+		ShippingPaise:   sum.ShippingPaise,
+		DiscountPaise:   sum.DiscountPaise,
+		GrandTotalPaise: sum.GrandTotalPaise,
+		PricingVersion:  domain.PricingVersion,
+		ComputedAt:      time.Now().UTC(),
+
+		StoredValuePaise: sum.StoredValuePaise,
+		ShippingRule:     priced.Shipping.RuleID,
+		ShippingMeta:     string(shippingMeta),
+	}
+	if err := tx.Totals().Save(&totals); err != nil {
+		return nil, err
+	}
+	return priced, nil
+}
+
+// trimStoredValue shrinks gift card / wallet holds, in the order they were
+// applied, so together they never exceed what the pricing engine let them pay.
+// A promotion trimmed to zero has its hold released.
+func trimStoredValue(tx repo.Tx, promos []domain.CartPromotion, allowed int64) error {
+	for i := range promos {
+		p := &promos[i]
+		if !domain.IsStoredValue(p.PromoType) || p.Status != "APPLIED" {
+			continue
+		}
+		amount := min(p.DiscountPaise, allowed)
+		allowed -= amount
+		if amount == p.DiscountPaise {
+			continue
+		}
+
+		if amount == 0 {
+			if err := storedvalue.ReleasePromotion(tx.StoredValue(), p.CartPromoID, "cart total reduced"); err != nil {
+				return err
+			}
+			p.Status = "RELEASED"
+		} else if err := storedvalue.Shrink(tx.StoredValue(), p.CartPromoID, amount); err != nil {
+			return err
+		}
+		p.DiscountPaise = amount
+		if err := tx.Promotions().Update(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revalidateCoupons re-evaluates every coupon on the cart after a change,
+// including the owner's per-user limit: a coupon applied to two carts of the
+// same owner is suspended on the second once the first is checked out.
+// Coupons that no longer qualify are SUSPENDED with a zero discount and come
+// back to APPLIED once the cart qualifies again. promos is updated in place.
+func (r *Repricer) revalidateCoupons(tx repo.Tx, cart *domain.Cart, items []domain.CartItem, promos []domain.CartPromotion) error {
+	if r.promos == nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	for i := range promos {
+		p := &promos[i]
+		if p.PromoType != domain.PromoTypeCoupon {
+			continue
+		}
+		rule, known := r.promos.Rule(p.PromoCode)
+		uses := 0
+		if known && rule.PerUserLimit > 0 {
+			var err error
+			if uses, err = tx.Promotions().CountRedeemed(p.PromoCode, cart); err != nil {
+				return err
+			}
+		}
+
+		res := r.promos.Evaluate(promo.Input{
+			Code: p.PromoCode, Now: now, Currency: cart.Currency, Items: items, Others: promo.Others(promos, p.CartPromoID), UserUses: uses,
+		})
+		status, discount, meta := "APPLIED", res.DiscountPaise, res.Meta
+		if !res.Accepted {
+			status, discount = "SUSPENDED", 0
+			meta = promo.Meta{SuspendReason: res.Reason}
+			if known {
+				meta.Kind = rule.Kind
+			}
+		}
+		if status == p.Status && discount == p.DiscountPaise {
+			continue
+		}
+		p.Status, p.DiscountPaise, p.PromoMeta = status, discount, meta.JSON()
+		if err := tx.Promotions().Update(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package promo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+)
+
+// Reason is a machine-readable rejection code returned to clients.
+type Reason string
+
+const (
+	ReasonNotFound          Reason = "PROMO_NOT_FOUND"
+	ReasonNotStarted        Reason = "PROMO_NOT_STARTED"
+	ReasonExpired           Reason = "PROMO_EXPIRED"
+	ReasonMinCartValue      Reason = "MIN_CART_VALUE_NOT_MET"
+	ReasonNoEligibleItems   Reason = "NO_ELIGIBLE_ITEMS"
+	ReasonUsageLimitReached Reason = "USAGE_LIMIT_REACHED"
+	ReasonAlreadyApplied    Reason = "ALREADY_APPLIED"
+	ReasonNotStackable      Reason = "NOT_STACKABLE"
+	ReasonMaxCoupons        Reason = "MAX_COUPONS_REACHED"
+	ReasonCurrency          Reason = "CURRENCY_NOT_SUPPORTED"
+)
+
+// Input is everything needed to evaluate one coupon against a cart.
+type Input struct {
+	Code string
+	Now  time.Time
+	// Currency is the cart's; empty skips the currency check.
+	Currency string
+	Items    []domain.CartItem
+	// Others are the other coupons on the cart, as Others returns them.
+	Others []domain.CartPromotion
+	// UserUses is how many times this owner has already redeemed Code.
+	UserUses int
+}
+
+// Others returns the coupons of promos a coupon is evaluated against: every
+// APPLIED or SUSPENDED coupon but the one with cartPromoID (nil for a coupon
+// not yet applied). Evaluate rejects a code already among them; only the
+// APPLIED ones count toward stacking and exclusivity, since a SUSPENDED
+// coupon gives no discount and comes back only once it fits again.
+func Others(promos []domain.CartPromotion, cartPromoID []byte) []domain.CartPromotion {
+	var out []domain.CartPromotion
+	for _, p := range promos {
+		if p.PromoType != domain.PromoTypeCoupon || p.Status != "APPLIED" && p.Status != "SUSPENDED" {
+			continue
+		}
+		if cartPromoID != nil && bytes.Equal(p.CartPromoID, cartPromoID) {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// Result of evaluating a coupon. Rejected results carry a Reason and no discount.
+type Result struct {
+	Accepted      bool
+	Reason        Reason
+	DiscountPaise int64
+	Meta          Meta
+}
+
+// Meta is persisted as cart_promotions.promo_meta.
+type Meta struct {
+	Kind          string   `json:"kind"`
+	EligibleSKUs  []string `json:"eligible_skus,omitempty"`
+	FreeUnits     int      `json:"free_units,omitempty"`
+	Exclusive     bool     `json:"exclusive,omitempty"`
+	SuspendReason Reason   `json:"suspend_reason,omitempty"`
+}
+
+func (m Meta) JSON() string {
+	b, _ := json.Marshal(m)
+	return string(b)
+}
+
+// Engine evaluates coupon codes against the configured rules. It is immutable
+// once built and safe for concurrent use.
+type Engine struct {
+	rules      map[string]Rule
+	maxCoupons int
+	currency   string
+}
+
+func NewEngine(cfg Config) (*Engine, error) {
+	e := &Engine{rules: make(map[string]Rule, len(cfg.Rules)), maxCoupons: cfg.MaxCouponsPerCart, currency: cfg.Currency}
+	if e.maxCoupons <= 0 {
+		e.maxCoupons = 1
+	}
+	if e.currency == "" {
+		e.currency = DefaultCurrency
+	}
+	for _, r := range cfg.Rules {
+		r.Code = NormalizeCode(r.Code)
+		if err := validateRule(r); err != nil {
+			return nil, err
+		}
+		if _, dup := e.rules[r.Code]; dup {
+			return nil, fmt.Errorf("promo: duplicate rule %q", r.Code)
+		}
+		e.rules[r.Code] = r
+	}
+	return e, nil
+}
+
+func validateRule(r Rule) error {
+	if r.Code == "" {
+		return fmt.Errorf("promo: rule without code")
+	}
+	switch r.Kind {
+	case KindPercentOff:
+		if r.PercentBps <= 0 || r.PercentBps > 10000 {
+			return fmt.Errorf("promo: %s percent_bps must be 1..10000", r.Code)
+		}
+	case KindFlatOff:
+		if r.FlatPaise <= 0 {
+			return fmt.Errorf("promo: %s flat_paise must be positive", r.Code)
+		}
+	case KindBuyXGetY:
+		if r.BuyQty <= 0 || r.GetQty <= 0 {
+			return fmt.Errorf("promo: %s buy_qty and get_qty must be positive", r.Code)
+		}
+	default:
+		return fmt.Errorf("promo: %s has unknown kind %q", r.Code, r.Kind)
+	}
+	return nil
+}
+
+// Rule returns the rule for code, if configured.
+func (e *Engine) Rule(code string) (Rule, bool) {
+	r, ok := e.rules[NormalizeCode(code)]
+	return r, ok
+}
+
+// Evaluate checks a coupon against the cart and computes its discount. Each
+// coupon is priced on the undiscounted lines; the pricing engine caps the
+// combined discount at the subtotal.
+func (e *Engine) Evaluate(in Input) Result {
+	code := NormalizeCode(in.Code)
+	rule, ok := e.rules[code]
+	if !ok {
+		return reject(ReasonNotFound)
+	}
+	if rule.StartsAt != nil && in.Now.Before(*rule.StartsAt) {
+		return reject(ReasonNotStarted)
+	}
+	if rule.ExpiresAt != nil && !in.Now.Before(*rule.ExpiresAt) {
+		return reject(ReasonExpired)
+	}
+	if in.Currency != "" && in.Currency != e.currency && rule.hasAmounts() {
+		return reject(ReasonCurrency)
+	}
+
+	others := 0
+	for _, p := range in.Others {
+		other := NormalizeCode(p.PromoCode)
+		if other == code {
+			return reject(ReasonAlreadyApplied)
+		}
+		if p.Status == "SUSPENDED" {
+			continue
+		}
+		others++
+		if r, found := e.rules[other]; found && r.Exclusive {
+			return reject(ReasonNotStackable)
+		}
+	}
+	if others > 0 && rule.Exclusive {
+		return reject(ReasonNotStackable)
+	}
+	if others+1 > e.maxCoupons {
+		return reject(ReasonMaxCoupons)
+	}
+
+	if rule.PerUserLimit > 0 && in.UserUses >= rule.PerUserLimit {
+		return reject(ReasonUsageLimitReached)
+	}
+
+	var cartValue int64
+	for _, it := range in.Items {
+		cartValue += lineValue(it)
+	}
+	if cartValue < rule.MinCartValuePaise {
+		return reject(ReasonMinCartValue)
+	}
+
+	eligible := eligibleItems(rule, in.Items)
+	meta := Meta{Kind: rule.Kind, Exclusive: rule.Exclusive}
+	var eligibleValue int64
+	for _, it := range eligible {
+		eligibleValue += lineValue(it)
+		meta.EligibleSKUs = append(meta.EligibleSKUs, it.SKU)
+	}
+
+	var discount int64
+	switch rule.Kind {
+	case KindPercentOff:
+		discount = eligibleValue * int64(rule.PercentBps) / 10000
+		if rule.MaxDiscountPaise > 0 && discount > rule.MaxDiscountPaise {
+			discount = rule.MaxDiscountPaise
+		}
+	case KindFlatOff:
+		discount = rule.FlatPaise
+		if discount > eligibleValue {
+			discount = eligibleValue
+		}
+	case KindBuyXGetY:
+		discount, meta.FreeUnits = buyXGetY(eligible, rule.BuyQty, rule.GetQty)
+	}
+	if discount <= 0 {
+		return reject(ReasonNoEligibleItems)
+	}
+
+	return Result{Accepted: true, DiscountPaise: discount, Meta: meta}
+}
+
+func reject(r Reason) Result { return Result{Reason: r} }
+
+func lineValue(it domain.CartItem) int64 {
+	if it.UnitPricePaise == nil || it.Qty <= 0 {
+		return 0
+	}
+	return *it.UnitPricePaise * int64(it.Qty)
+}
+
+func eligibleItems(rule Rule, items []domain.CartItem) []domain.CartItem {
+	if len(rule.SKUs) == 0 && len(rule.Categories) == 0 {
+		return items
+	}
+	out := make([]domain.CartItem, 0, len(items))
+	for _, it := range items {
+		if contains(rule.SKUs, it.SKU) {
+			out = append(out, it)
+			continue
+		}
+		for _, cat := range itemCategories(it) {
+			if contains(rule.Categories, cat) {
+				out = append(out, it)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// itemCategories reads "category" or "categories" from ProductMeta.
+func itemCategories(it domain.CartItem) []string {
+	if it.ProductMeta == "" {
+		return nil
+	}
+	var meta struct {
+		Category   string   `json:"category"`
+		Categories []string `json:"categories"`
+	}
+	if err := json.Unmarshal([]byte(it.ProductMeta), &meta); err != nil {
+		return nil
+	}
+	if meta.Category != "" {
+		return append(meta.Categories, meta.Category)
+	}
+	return meta.Categories
+}
+
+// buyXGetY makes the cheapest getQty units free in every group of
+// buyQty+getQty eligible units, units ranked from most to least expensive.
+func buyXGetY(items []domain.CartItem, buyQty, getQty int) (int64, int) {
+	var units []int64
+	for _, it := range items {
+		if it.UnitPricePaise == nil {
+			continue
+		}
+		for i := 0; i < it.Qty; i++ {
+			units = append(units, *it.UnitPricePaise)
+		}
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i] > units[j] })
+
+	group := buyQty + getQty
+	var discount int64
+	free := 0
+	for start := 0; start+group <= len(units); start += group {
+		for _, price := range units[start+buyQty : start+group] {
+			discount += price
+			free++
+		}
+	}
+	return discount, free
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
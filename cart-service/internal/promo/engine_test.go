@@ -0,0 +1,152 @@
+package promo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+)
+
+func price(v int64) *int64 { return &v }
+
+func testEngine(t *testing.T) *Engine {
+	t.Helper()
+	expired := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	e, err := NewEngine(Config{
+		MaxCouponsPerCart: 2,
+		Rules: []Rule{
+			{Code: "pct10", Kind: KindPercentOff, PercentBps: 1000, MaxDiscountPaise: 500},
+			{Code: "FLAT100", Kind: KindFlatOff, FlatPaise: 100, MinCartValuePaise: 1000},
+			{Code: "SHOES", Kind: KindPercentOff, PercentBps: 2000, Categories: []string{"footwear"}},
+			{Code: "B2G1", Kind: KindBuyXGetY, BuyQty: 2, GetQty: 1, SKUs: []string{"SOCK"}},
+			{Code: "ONCE", Kind: KindFlatOff, FlatPaise: 50, PerUserLimit: 1},
+			{Code: "OLD", Kind: KindFlatOff, FlatPaise: 50, ExpiresAt: &expired},
+			{Code: "SOLO", Kind: KindFlatOff, FlatPaise: 50, Exclusive: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestEngineEvaluate(t *testing.T) {
+	e := testEngine(t)
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	cart := []domain.CartItem{
+		{SKU: "SHOE", Qty: 1, UnitPricePaise: price(2000), ProductMeta: `{"category":"footwear"}`},
+		{SKU: "SOCK", Qty: 3, UnitPricePaise: price(300)},
+	}
+
+	tests := []struct {
+		name     string
+		in       Input
+		reason   Reason
+		discount int64
+	}{
+		{name: "unknown code", in: Input{Code: "NOPE", Items: cart}, reason: ReasonNotFound},
+		{name: "percent capped and case-insensitive", in: Input{Code: "Pct10", Items: cart}, discount: 290},
+		{name: "flat off", in: Input{Code: "FLAT100", Items: cart}, discount: 100},
+		{name: "min cart value", in: Input{Code: "FLAT100", Items: cart[1:]}, reason: ReasonMinCartValue},
+		{name: "category targeting", in: Input{Code: "SHOES", Items: cart}, discount: 400},
+		{name: "category not in cart", in: Input{Code: "SHOES", Items: cart[1:]}, reason: ReasonNoEligibleItems},
+		{name: "buy two get one", in: Input{Code: "B2G1", Items: cart}, discount: 300},
+		{name: "per user limit", in: Input{Code: "ONCE", Items: cart, UserUses: 1}, reason: ReasonUsageLimitReached},
+		{name: "expired", in: Input{Code: "OLD", Items: cart}, reason: ReasonExpired},
+		{
+			name:   "already applied",
+			in:     Input{Code: "FLAT100", Items: cart, Others: []domain.CartPromotion{{PromoCode: "flat100"}}},
+			reason: ReasonAlreadyApplied,
+		},
+		{
+			name:   "exclusive cannot join others",
+			in:     Input{Code: "SOLO", Items: cart, Others: []domain.CartPromotion{{PromoCode: "FLAT100"}}},
+			reason: ReasonNotStackable,
+		},
+		{
+			name:   "others cannot join exclusive",
+			in:     Input{Code: "FLAT100", Items: cart, Others: []domain.CartPromotion{{PromoCode: "SOLO"}}},
+			reason: ReasonNotStackable,
+		},
+		{
+			name: "max coupons",
+			in: Input{Code: "SHOES", Items: cart, Others: []domain.CartPromotion{
+				{PromoCode: "FLAT100"}, {PromoCode: "PCT10"},
+			}},
+			reason: ReasonMaxCoupons,
+		},
+		{
+			name:   "suspended coupon is still on the cart",
+			in:     Input{Code: "ONCE", Items: cart, Others: []domain.CartPromotion{{PromoCode: "ONCE", Status: "SUSPENDED"}}},
+			reason: ReasonAlreadyApplied,
+		},
+		{
+			name:     "suspended coupons do not stack",
+			in:       Input{Code: "FLAT100", Items: cart, Others: []domain.CartPromotion{{PromoCode: "SOLO", Status: "SUSPENDED"}, {PromoCode: "ONCE", Status: "SUSPENDED"}}},
+			discount: 100,
+		},
+		{name: "amounts are in the rules currency", in: Input{Code: "FLAT100", Currency: "USD", Items: cart}, reason: ReasonCurrency},
+		{name: "capped percent is too", in: Input{Code: "PCT10", Currency: "USD", Items: cart}, reason: ReasonCurrency},
+		{name: "plain percent applies in any currency", in: Input{Code: "SHOES", Currency: "USD", Items: cart}, discount: 400},
+		{name: "rules currency", in: Input{Code: "FLAT100", Currency: "INR", Items: cart}, discount: 100},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.in.Now = now
+			got := e.Evaluate(tc.in)
+			if tc.reason != "" {
+				if got.Accepted || got.Reason != tc.reason {
+					t.Fatalf("got %+v, want rejection %s", got, tc.reason)
+				}
+				return
+			}
+			if !got.Accepted || got.DiscountPaise != tc.discount {
+				t.Fatalf("got %+v, want discount %d", got, tc.discount)
+			}
+		})
+	}
+}
+
+func TestOthers(t *testing.T) {
+	promos := []domain.CartPromotion{
+		{CartPromoID: []byte("a"), PromoCode: "A", PromoType: domain.PromoTypeCoupon, Status: "APPLIED"},
+		{CartPromoID: []byte("b"), PromoCode: "B", PromoType: domain.PromoTypeCoupon, Status: "SUSPENDED"},
+		{CartPromoID: []byte("c"), PromoCode: "C", PromoType: domain.PromoTypeCoupon, Status: "REMOVED"},
+		{CartPromoID: []byte("g"), PromoCode: "G", PromoType: domain.PromoTypeGiftCard, Status: "APPLIED"},
+	}
+	codes := func(ps []domain.CartPromotion) (out string) {
+		for _, p := range ps {
+			out += p.PromoCode
+		}
+		return out
+	}
+	// Applying a new coupon and repricing coupon A see the same rule.
+	if got := codes(Others(promos, nil)); got != "AB" {
+		t.Errorf("others of a new coupon = %s, want AB", got)
+	}
+	if got := codes(Others(promos, []byte("a"))); got != "B" {
+		t.Errorf("others of A = %s, want B", got)
+	}
+}
+
+func TestNewEngineRejectsBadRules(t *testing.T) {
+	bad := []Rule{
+		{Code: "", Kind: KindFlatOff, FlatPaise: 1},
+		{Code: "X", Kind: "MYSTERY"},
+		{Code: "X", Kind: KindPercentOff, PercentBps: 20000},
+		{Code: "X", Kind: KindBuyXGetY, BuyQty: 1},
+	}
+	for _, r := range bad {
+		if _, err := NewEngine(Config{Rules: []Rule{r}}); err == nil {
+			t.Fatalf("expected error for %+v", r)
+		}
+	}
+	if _, err := NewEngine(Config{Rules: []Rule{
+		{Code: "A", Kind: KindFlatOff, FlatPaise: 1},
+		{Code: "a", Kind: KindFlatOff, FlatPaise: 1},
+	}}); err == nil {
+		t.Fatal("expected duplicate code error")
+	}
+}
@@ -0,0 +1,77 @@
+package promo
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+)
+
+// Rule kinds.
+const (
+	KindPercentOff = "PERCENT_OFF"
+	KindFlatOff    = "FLAT_OFF"
+	KindBuyXGetY   = "BUY_X_GET_Y"
+)
+
+// Rule is one coupon definition as loaded from the rules file.
+type Rule struct {
+	Code string `json:"code"`
+	Kind string `json:"kind"`
+
+	PercentBps       int   `json:"percent_bps,omitempty"`        // PERCENT_OFF: 1000 = 10%
+	MaxDiscountPaise int64 `json:"max_discount_paise,omitempty"` // PERCENT_OFF cap, 0 = uncapped
+	FlatPaise        int64 `json:"flat_paise,omitempty"`         // FLAT_OFF
+	BuyQty           int   `json:"buy_qty,omitempty"`            // BUY_X_GET_Y
+	GetQty           int   `json:"get_qty,omitempty"`            // BUY_X_GET_Y
+
+	MinCartValuePaise int64 `json:"min_cart_value_paise,omitempty"`
+
+	// Targeting. Empty means every line in the cart is eligible.
+	SKUs       []string `json:"skus,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+
+	PerUserLimit int        `json:"per_user_limit,omitempty"` // 0 = unlimited
+	StartsAt     *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+
+	// Exclusive coupons cannot be combined with any other coupon on the cart.
+	Exclusive bool `json:"exclusive,omitempty"`
+}
+
+// hasAmounts reports whether the rule states money amounts, which only mean
+// something in the rules file's currency. Percentage and buy-X-get-Y rules
+// without caps or thresholds apply to carts in any currency.
+func (r Rule) hasAmounts() bool {
+	return r.FlatPaise > 0 || r.MaxDiscountPaise > 0 || r.MinCartValuePaise > 0
+}
+
+// DefaultCurrency is the currency of rule amounts when the file names none.
+const DefaultCurrency = "INR"
+
+// Config is the on-disk rules file.
+type Config struct {
+	// MaxCouponsPerCart limits how many coupons may stack. 0 means 1.
+	MaxCouponsPerCart int `json:"max_coupons_per_cart"`
+	// Currency of every *_paise amount in Rules; empty means DefaultCurrency.
+	Currency string `json:"currency"`
+	Rules    []Rule `json:"rules"`
+}
+
+// LoadFile reads a JSON rules file.
+func LoadFile(path string) (*Engine, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, err
+	}
+	return NewEngine(cfg)
+}
+
+// NormalizeCode makes coupon codes case-insensitive.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
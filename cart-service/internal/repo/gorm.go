@@ -0,0 +1,426 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormStore struct{ db *gorm.DB }
+
+// NewGormStore returns the MySQL Store.
+func NewGormStore(db *gorm.DB) Store { return gormStore{db: db} }
+
+func (s gormStore) Transaction(ctx context.Context, fn func(tx Tx) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(gormTx{db: tx})
+	})
+}
+
+// GormTx exposes a gorm transaction the caller already opened as a Tx.
+func GormTx(tx *gorm.DB) Tx { return gormTx{db: tx} }
+
+type gormTx struct{ db *gorm.DB }
+
+func (t gormTx) Carts() CartRepository                     { return gormCarts(t) }
+func (t gormTx) Items() CartItemRepository                 { return gormItems(t) }
+func (t gormTx) Promotions() PromotionRepository           { return gormPromotions(t) }
+func (t gormTx) Totals() TotalsRepository                  { return gormTotals(t) }
+func (t gormTx) Outbox() OutboxRepository                  { return gormOutbox(t) }
+func (t gormTx) StoredValue() StoredValueRepository        { return gormStoredValue(t) }
+func (t gormTx) FXRates() FXRateRepository                 { return gormFXRates(t) }
+func (t gormTx) Sagas() SagaRepository                     { return gormSagas(t) }
+func (t gormTx) Idempotency() IdempotencyRepository        { return gormIdempotency(t) }
+func (t gormTx) History() HistoryRepository                { return gormHistory(t) }
+func (t gormTx) ProcessedEvents() ProcessedEventRepository { return gormProcessedEvents(t) }
+
+// first loads the first row q matches, mapping "no row" to ErrNotFound.
+func first[T any](q *gorm.DB) (*T, error) {
+	var row T
+	err := q.First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+func forUpdate(q *gorm.DB) *gorm.DB {
+	return q.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+// mysqlDuplicateKey is ER_DUP_ENTRY.
+const mysqlDuplicateKey = 1062
+
+// create inserts row, mapping a duplicate key to ErrDuplicate. MySQL rolls
+// back only the failed statement, so the transaction stays usable.
+func create(db *gorm.DB, row any) error {
+	err := db.Create(row).Error
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == mysqlDuplicateKey {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// jsonColumn stores "" in a JSON column as NULL, which MySQL accepts where
+// an empty string is not valid JSON.
+func jsonColumn(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// omitBlankJSON leaves the JSON columns holding "" out of an INSERT, so they
+// default to NULL.
+func omitBlankJSON(db *gorm.DB, cols map[string]string) *gorm.DB {
+	var blank []string
+	for col, v := range cols {
+		if v == "" {
+			blank = append(blank, col)
+		}
+	}
+	if len(blank) == 0 {
+		return db
+	}
+	return db.Omit(blank...)
+}
+
+type gormCarts struct{ db *gorm.DB }
+
+func (r gormCarts) Get(cartID []byte) (*domain.Cart, error) {
+	return first[domain.Cart](r.db.Where("cart_id = ?", cartID))
+}
+
+func (r gormCarts) Lock(cartID []byte) (*domain.Cart, error) {
+	return first[domain.Cart](forUpdate(r.db).Where("cart_id = ?", cartID))
+}
+
+func (r gormCarts) FindActive(ownerType string, userID []byte, guestID, channel string) (*domain.Cart, error) {
+	q := r.db.Where("owner_type = ? AND channel = ? AND status = 'ACTIVE'", ownerType, channel)
+	if ownerType == "USER" {
+		q = q.Where("user_id = ?", userID)
+	} else {
+		q = q.Where("guest_id = ?", guestID)
+	}
+	return first[domain.Cart](q)
+}
+
+func (r gormCarts) ActiveWithSKU(sku, variantID string) ([][]byte, error) {
+	q := r.db.Table("cart_items AS ci").
+		Joins("JOIN carts AS c ON c.cart_id = ci.cart_id").
+		Where("c.status = 'ACTIVE' AND ci.sku = ?", sku)
+	if variantID != "" {
+		q = q.Where("ci.variant_id = ?", variantID)
+	}
+	var ids [][]byte
+	err := q.Distinct("ci.cart_id").Order("ci.cart_id").Pluck("ci.cart_id", &ids).Error
+	return ids, err
+}
+
+func (r gormCarts) Create(cart *domain.Cart) error { return create(r.db, cart) }
+
+func (r gormCarts) Bump(cart *domain.Cart) error {
+	res := r.db.Model(&domain.Cart{}).
+		Where("cart_id = ? AND version = ?", cart.CartID, cart.Version).
+		Updates(map[string]any{
+			"status":       cart.Status,
+			"ship_pincode": cart.ShipPincode,
+			"expires_at":   cart.ExpiresAt,
+			"version":      cart.Version + 1,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	cart.Version++
+	return nil
+}
+
+type gormItems struct{ db *gorm.DB }
+
+func (r gormItems) List(cartID []byte) ([]domain.CartItem, error) {
+	var items []domain.CartItem
+	err := r.db.Where("cart_id = ?", cartID).Order("added_at asc").Find(&items).Error
+	return items, err
+}
+
+func (r gormItems) Lock(cartID []byte, sku, variantID string) (*domain.CartItem, error) {
+	return first[domain.CartItem](forUpdate(r.db).
+		Where("cart_id = ? AND sku = ? AND variant_id = ?", cartID, sku, variantID))
+}
+
+func (r gormItems) Create(item *domain.CartItem) error {
+	return create(omitBlankJSON(r.db, map[string]string{"product_meta": item.ProductMeta}), item)
+}
+
+func (r gormItems) Update(item *domain.CartItem) error {
+	return r.db.Model(&domain.CartItem{}).
+		Where("cart_item_id = ?", item.CartItemID).
+		Updates(map[string]any{
+			"sku":                   item.SKU,
+			"variant_id":            item.VariantID,
+			"qty":                   item.Qty,
+			"product_name":          item.ProductName,
+			"image_url":             item.ImageURL,
+			"currency":              item.Currency,
+			"unit_price_paise":      item.UnitPricePaise,
+			"mrp_paise":             item.MRPPaise,
+			"tax_rate_bps":          item.TaxRateBps,
+			"product_meta":          jsonColumn(item.ProductMeta),
+			"list_currency":         item.ListCurrency,
+			"list_unit_price_paise": item.ListUnitPricePaise,
+			"list_mrp_paise":        item.ListMRPPaise,
+			"fx_rate":               item.FXRate,
+			"fx_rate_at":            item.FXRateAt,
+			"availability":          item.Availability,
+		}).Error
+}
+
+func (r gormItems) Delete(cartItemID []byte) error {
+	return r.db.Where("cart_item_id = ?", cartItemID).Delete(&domain.CartItem{}).Error
+}
+
+type gormPromotions struct{ db *gorm.DB }
+
+func (r gormPromotions) List(cartID []byte, f PromotionFilter) ([]domain.CartPromotion, error) {
+	q := r.db.Where("cart_id = ?", cartID)
+	if len(f.Types) > 0 {
+		q = q.Where("promo_type IN ?", f.Types)
+	}
+	if f.Code != "" {
+		q = q.Where("promo_code = ?", f.Code)
+	}
+	if len(f.Statuses) > 0 {
+		q = q.Where("status IN ?", f.Statuses)
+	}
+	var promos []domain.CartPromotion
+	err := q.Order("applied_at asc").Find(&promos).Error
+	return promos, err
+}
+
+func (r gormPromotions) Create(p *domain.CartPromotion) error {
+	return create(omitBlankJSON(r.db, map[string]string{"promo_meta": p.PromoMeta}), p)
+}
+
+func (r gormPromotions) Update(p *domain.CartPromotion) error {
+	return r.db.Model(&domain.CartPromotion{}).
+		Where("cart_promo_id = ?", p.CartPromoID).
+		Updates(map[string]any{
+			"status":         p.Status,
+			"discount_paise": p.DiscountPaise,
+			"promo_meta":     jsonColumn(p.PromoMeta),
+		}).Error
+}
+
+func (r gormPromotions) CountRedeemed(code string, cart *domain.Cart) (int, error) {
+	q := r.db.Table("cart_promotions AS p").
+		Joins("JOIN carts AS c ON c.cart_id = p.cart_id").
+		Where("p.promo_code = ? AND p.status = 'APPLIED' AND c.status = 'CHECKED_OUT' AND c.cart_id <> ?", code, cart.CartID)
+	if cart.OwnerType == "USER" {
+		q = q.Where("c.user_id = ?", cart.UserID)
+	} else {
+		q = q.Where("c.guest_id = ?", cart.GuestID)
+	}
+	var n int64
+	if err := q.Count(&n).Error; err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+type gormTotals struct{ db *gorm.DB }
+
+func (r gormTotals) Get(cartID []byte) (*domain.CartTotals, error) {
+	return first[domain.CartTotals](r.db.Where("cart_id = ?", cartID))
+}
+
+func (r gormTotals) Save(t *domain.CartTotals) error {
+	set := clause.AssignmentColumns([]string{
+		"subtotal_paise", "tax_paise", "shipping_paise", "discount_paise", "grand_total_paise",
+		"pricing_version", "computed_at", "stored_value_paise", "shipping_rule",
+	})
+	set = append(set, clause.Assignment{Column: clause.Column{Name: "shipping_meta"}, Value: jsonColumn(t.ShippingMeta)})
+	return omitBlankJSON(r.db, map[string]string{"shipping_meta": t.ShippingMeta}).
+		Clauses(clause.OnConflict{DoUpdates: set}).
+		Create(t).Error
+}
+
+type gormOutbox struct{ db *gorm.DB }
+
+func (r gormOutbox) Add(row *domain.CartOutbox) error { return r.db.Create(row).Error }
+
+type gormProcessedEvents struct{ db *gorm.DB }
+
+func (r gormProcessedEvents) Create(row *domain.ProcessedEvent) error { return create(r.db, row) }
+
+type gormHistory struct{ db *gorm.DB }
+
+func (r gormHistory) Append(e *domain.CartHistory) error { return r.db.Create(e).Error }
+
+func (r gormHistory) List(cartID []byte, afterSeq uint64, limit int) ([]domain.CartHistory, error) {
+	var rows []domain.CartHistory
+	err := r.db.Where("cart_id = ? AND seq > ?", cartID, afterSeq).
+		Order("seq ASC").Limit(limit).Find(&rows).Error
+	return rows, err
+}
+
+type gormStoredValue struct{ db *gorm.DB }
+
+func (r gormStoredValue) LockAccount(accountID []byte) (*domain.StoredValueAccount, error) {
+	return first[domain.StoredValueAccount](forUpdate(r.db).Where("account_id = ?", accountID))
+}
+
+func (r gormStoredValue) LockGiftCard(code string) (*domain.StoredValueAccount, error) {
+	return first[domain.StoredValueAccount](forUpdate(r.db).
+		Where("kind = ? AND code = ?", domain.PromoTypeGiftCard, code))
+}
+
+func (r gormStoredValue) LockWallet(userID []byte) (*domain.StoredValueAccount, error) {
+	return first[domain.StoredValueAccount](forUpdate(r.db).
+		Where("kind = ? AND owner_user_id = ?", domain.PromoTypeWallet, userID))
+}
+
+func (r gormStoredValue) SaveBalances(acc *domain.StoredValueAccount) error {
+	return r.db.Model(&domain.StoredValueAccount{}).
+		Where("account_id = ?", acc.AccountID).
+		Updates(map[string]any{
+			"balance_paise": acc.BalancePaise,
+			"held_paise":    acc.HeldPaise,
+		}).Error
+}
+
+func (r gormStoredValue) CreateHold(h *domain.StoredValueHold) error { return r.db.Create(h).Error }
+
+func (r gormStoredValue) LockHeldByCart(cartID []byte) ([]domain.StoredValueHold, error) {
+	return r.lockHeld(r.db.Where("cart_id = ?", cartID))
+}
+
+func (r gormStoredValue) LockHeldByPromotion(cartPromoID []byte) ([]domain.StoredValueHold, error) {
+	return r.lockHeld(r.db.Where("cart_promo_id = ?", cartPromoID))
+}
+
+func (r gormStoredValue) lockHeld(q *gorm.DB) ([]domain.StoredValueHold, error) {
+	var holds []domain.StoredValueHold
+	err := forUpdate(q).Where("status = 'HELD'").Find(&holds).Error
+	return holds, err
+}
+
+func (r gormStoredValue) UpdateHold(h *domain.StoredValueHold) error {
+	return r.db.Model(&domain.StoredValueHold{}).
+		Where("hold_id = ?", h.HoldID).
+		Updates(map[string]any{"amount_paise": h.AmountPaise, "status": h.Status}).Error
+}
+
+func (r gormStoredValue) AppendEntry(e *domain.StoredValueLedgerEntry) error {
+	return r.db.Create(e).Error
+}
+
+type gormFXRates struct{ db *gorm.DB }
+
+func (r gormFXRates) Latest(base, quote string, at time.Time) (*domain.FXRate, error) {
+	var rows []domain.FXRate
+	err := r.db.Where("base_currency = ? AND quote_currency = ? AND effective_at <= ?", base, quote, at).
+		Order("effective_at DESC").Limit(1).Find(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return &rows[0], nil
+}
+
+type gormSagas struct{ db *gorm.DB }
+
+func (r gormSagas) Create(s *domain.CheckoutSaga) error { return r.db.Create(s).Error }
+
+func (r gormSagas) Get(sagaID []byte) (*domain.CheckoutSaga, error) {
+	return first[domain.CheckoutSaga](r.db.Where("saga_id = ?", sagaID))
+}
+
+func (r gormSagas) Lock(sagaID []byte) (*domain.CheckoutSaga, error) {
+	return first[domain.CheckoutSaga](forUpdate(r.db).Where("saga_id = ?", sagaID))
+}
+
+func (r gormSagas) Latest(cartID []byte) (*domain.CheckoutSaga, error) {
+	return first[domain.CheckoutSaga](r.db.Where("cart_id = ?", cartID).Order("created_at desc"))
+}
+
+func (r gormSagas) Update(s *domain.CheckoutSaga) error {
+	return r.db.Model(&domain.CheckoutSaga{}).
+		Where("saga_id = ?", s.SagaID).
+		Updates(map[string]any{
+			"status":         s.Status,
+			"lines":          s.Lines,
+			"failure_reason": s.FailureReason,
+			"error":          s.Error,
+			"lease_until":    s.LeaseUntil,
+			"completed_at":   s.CompletedAt,
+		}).Error
+}
+
+func (r gormSagas) Stalled(statuses []string, now time.Time, limit int) ([][]byte, error) {
+	var ids [][]byte
+	err := r.db.Model(&domain.CheckoutSaga{}).
+		Where("status IN ? AND lease_until <= ?", statuses, now).
+		Order("lease_until ASC").
+		Limit(limit).
+		Pluck("saga_id", &ids).Error
+	return ids, err
+}
+
+func (r gormSagas) Claim(sagaID []byte, statuses []string, now, until time.Time) (bool, error) {
+	res := r.db.Model(&domain.CheckoutSaga{}).
+		Where("saga_id = ? AND status IN ? AND lease_until <= ?", sagaID, statuses, now).
+		Update("lease_until", until)
+	return res.RowsAffected == 1, res.Error
+}
+
+type gormIdempotency struct{ db *gorm.DB }
+
+func (r gormIdempotency) Create(row *domain.CartIdempotency) error {
+	return create(omitBlankJSON(r.db, map[string]string{
+		"response_body":    row.ResponseBody,
+		"response_headers": row.ResponseHeaders,
+	}), row)
+}
+
+func (r gormIdempotency) Lock(clientID, key string) (*domain.CartIdempotency, error) {
+	return first[domain.CartIdempotency](forUpdate(r.db).
+		Where("client_id = ? AND idempotency_key = ?", clientID, key))
+}
+
+func (r gormIdempotency) Relock(clientID, key string, lockedAt time.Time) error {
+	return r.db.Model(&domain.CartIdempotency{}).
+		Where("client_id = ? AND idempotency_key = ? AND state = 'IN_PROGRESS'", clientID, key).
+		Update("locked_at", lockedAt).Error
+}
+
+func (r gormIdempotency) Complete(clientID, key string, lockedAt time.Time, resp domain.CartIdempotency) (bool, error) {
+	updates := map[string]any{
+		"http_status":      resp.HTTPStatus,
+		"response_body":    resp.ResponseBody,
+		"response_headers": jsonColumn(resp.ResponseHeaders),
+		"state":            "COMPLETED",
+	}
+	if resp.ResourceID != nil {
+		updates["resource_id"] = resp.ResourceID
+	}
+	res := r.db.Model(&domain.CartIdempotency{}).
+		Where("client_id = ? AND idempotency_key = ? AND state = 'IN_PROGRESS' AND locked_at = ?", clientID, key, lockedAt).
+		Updates(updates)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r gormIdempotency) Delete(clientID, key string, lockedAt time.Time) error {
+	return r.db.Where("client_id = ? AND idempotency_key = ? AND state = 'IN_PROGRESS' AND locked_at = ?", clientID, key, lockedAt).
+		Delete(&domain.CartIdempotency{}).Error
+}
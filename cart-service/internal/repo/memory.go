@@ -0,0 +1,630 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+)
+
+// Memory is an in-process Store for tests and for running the API without
+// MySQL. Transactions run one at a time and do not nest, so locks are no-ops;
+// a transaction that fails or panics leaves every table as it found it. Rows
+// are copied in and out.
+type Memory struct {
+	mu   sync.Mutex
+	now  func() time.Time
+	seq  uint64 // insertion order, cart_outbox.seq and cart_history.seq
+	data memData
+}
+
+type memData struct {
+	carts    map[string]domain.Cart
+	items    map[string]memRow[domain.CartItem]
+	promos   map[string]memRow[domain.CartPromotion]
+	totals   map[string]domain.CartTotals
+	outbox   []domain.CartOutbox
+	history  []domain.CartHistory
+	accounts map[string]domain.StoredValueAccount
+	holds    map[string]memRow[domain.StoredValueHold]
+	ledger   []domain.StoredValueLedgerEntry
+	rates    []domain.FXRate
+	sagas    map[string]memRow[domain.CheckoutSaga]
+	idem     map[string]domain.CartIdempotency
+	events   map[string]domain.ProcessedEvent
+}
+
+// memRow keeps the insertion order of a row, which breaks ties between rows
+// created within the same clock tick.
+type memRow[T any] struct {
+	row T
+	seq uint64
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		now: func() time.Time { return time.Now().UTC() },
+		data: memData{
+			carts:    map[string]domain.Cart{},
+			items:    map[string]memRow[domain.CartItem]{},
+			promos:   map[string]memRow[domain.CartPromotion]{},
+			totals:   map[string]domain.CartTotals{},
+			accounts: map[string]domain.StoredValueAccount{},
+			holds:    map[string]memRow[domain.StoredValueHold]{},
+			sagas:    map[string]memRow[domain.CheckoutSaga]{},
+			idem:     map[string]domain.CartIdempotency{},
+			events:   map[string]domain.ProcessedEvent{},
+		},
+	}
+}
+
+func (d memData) clone() memData {
+	return memData{
+		carts:    maps.Clone(d.carts),
+		items:    maps.Clone(d.items),
+		promos:   maps.Clone(d.promos),
+		totals:   maps.Clone(d.totals),
+		outbox:   slices.Clone(d.outbox),
+		history:  slices.Clone(d.history),
+		accounts: maps.Clone(d.accounts),
+		holds:    maps.Clone(d.holds),
+		ledger:   slices.Clone(d.ledger),
+		rates:    slices.Clone(d.rates),
+		sagas:    maps.Clone(d.sagas),
+		idem:     maps.Clone(d.idem),
+		events:   maps.Clone(d.events),
+	}
+}
+
+func (m *Memory) Transaction(_ context.Context, fn func(tx Tx) error) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	saved := m.data.clone()
+	defer func() {
+		if p := recover(); p != nil {
+			m.data = saved
+			panic(p)
+		}
+		if err != nil {
+			m.data = saved
+		}
+	}()
+	return fn(memTx{m: m})
+}
+
+// Insert seeds rows for tests: carts, cart items, promotions, totals,
+// stored value accounts, exchange rates and checkout sagas, by value.
+func (m *Memory) Insert(rows ...any) error {
+	return m.Transaction(context.Background(), func(tx Tx) error {
+		for _, row := range rows {
+			var err error
+			switch r := row.(type) {
+			case domain.Cart:
+				err = tx.Carts().Create(&r)
+			case domain.CartItem:
+				err = tx.Items().Create(&r)
+			case domain.CartPromotion:
+				err = tx.Promotions().Create(&r)
+			case domain.CartTotals:
+				err = tx.Totals().Save(&r)
+			case domain.StoredValueAccount:
+				r.CreatedAt, r.UpdatedAt = m.stamp(r.CreatedAt), m.stamp(r.UpdatedAt)
+				m.data.accounts[string(r.AccountID)] = r
+			case domain.FXRate:
+				r.CreatedAt = m.stamp(r.CreatedAt)
+				m.data.rates = append(m.data.rates, r)
+			case domain.CheckoutSaga:
+				err = tx.Sagas().Create(&r)
+			default:
+				err = fmt.Errorf("repo: cannot insert %T", row)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Outbox returns the outbox rows written so far, oldest first.
+func (m *Memory) Outbox() []domain.CartOutbox {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.data.outbox)
+}
+
+// stamp fills an autoCreateTime column left zero, as gorm does.
+func (m *Memory) stamp(t time.Time) time.Time {
+	if t.IsZero() {
+		return m.now()
+	}
+	return t
+}
+
+func (m *Memory) next() uint64 {
+	m.seq++
+	return m.seq
+}
+
+// sorted returns the rows keep selects, ordered by at and then by insertion.
+func sorted[T any](rows map[string]memRow[T], keep func(T) bool, at func(T) time.Time) []T {
+	var matched []memRow[T]
+	for _, r := range rows {
+		if keep(r.row) {
+			matched = append(matched, r)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := at(matched[i].row), at(matched[j].row)
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return matched[i].seq < matched[j].seq
+	})
+	out := make([]T, len(matched))
+	for i, r := range matched {
+		out[i] = r.row
+	}
+	return out
+}
+
+type memTx struct{ m *Memory }
+
+func (t memTx) Carts() CartRepository                     { return memCarts(t) }
+func (t memTx) Items() CartItemRepository                 { return memItems(t) }
+func (t memTx) Promotions() PromotionRepository           { return memPromotions(t) }
+func (t memTx) Totals() TotalsRepository                  { return memTotals(t) }
+func (t memTx) Outbox() OutboxRepository                  { return memOutbox(t) }
+func (t memTx) StoredValue() StoredValueRepository        { return memStoredValue(t) }
+func (t memTx) FXRates() FXRateRepository                 { return memFXRates(t) }
+func (t memTx) Sagas() SagaRepository                     { return memSagas(t) }
+func (t memTx) Idempotency() IdempotencyRepository        { return memIdempotency(t) }
+func (t memTx) History() HistoryRepository                { return memHistory(t) }
+func (t memTx) ProcessedEvents() ProcessedEventRepository { return memProcessedEvents(t) }
+
+type memCarts struct{ m *Memory }
+
+func (r memCarts) Get(cartID []byte) (*domain.Cart, error) {
+	c, ok := r.m.data.carts[string(cartID)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &c, nil
+}
+
+func (r memCarts) Lock(cartID []byte) (*domain.Cart, error) { return r.Get(cartID) }
+
+func (r memCarts) FindActive(ownerType string, userID []byte, guestID, channel string) (*domain.Cart, error) {
+	var found *domain.Cart
+	for _, c := range r.m.data.carts {
+		if c.OwnerType != ownerType || c.Channel != channel || c.Status != domain.CartActive {
+			continue
+		}
+		if ownerType == "USER" && !bytes.Equal(c.UserID, userID) || ownerType != "USER" && c.GuestID != guestID {
+			continue
+		}
+		if found == nil || bytes.Compare(c.CartID, found.CartID) < 0 {
+			found = &c
+		}
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	return found, nil
+}
+
+func (r memCarts) ActiveWithSKU(sku, variantID string) ([][]byte, error) {
+	var ids [][]byte
+	for _, it := range r.m.data.items {
+		c := r.m.data.carts[string(it.row.CartID)]
+		if c.Status != domain.CartActive || it.row.SKU != sku || variantID != "" && it.row.VariantID != variantID {
+			continue
+		}
+		if !slices.ContainsFunc(ids, func(id []byte) bool { return bytes.Equal(id, c.CartID) }) {
+			ids = append(ids, c.CartID)
+		}
+	}
+	slices.SortFunc(ids, bytes.Compare)
+	return ids, nil
+}
+
+func (r memCarts) Create(cart *domain.Cart) error {
+	if _, ok := r.m.data.carts[string(cart.CartID)]; ok {
+		return ErrDuplicate
+	}
+	cart.CreatedAt, cart.UpdatedAt = r.m.stamp(cart.CreatedAt), r.m.stamp(cart.UpdatedAt)
+	r.m.data.carts[string(cart.CartID)] = *cart
+	return nil
+}
+
+func (r memCarts) Bump(cart *domain.Cart) error {
+	c, ok := r.m.data.carts[string(cart.CartID)]
+	if !ok || c.Version != cart.Version {
+		return ErrVersionConflict
+	}
+	c.Status, c.ShipPincode, c.ExpiresAt = cart.Status, cart.ShipPincode, cart.ExpiresAt
+	c.Version++
+	c.UpdatedAt = r.m.now()
+	r.m.data.carts[string(c.CartID)] = c
+	cart.Version++
+	return nil
+}
+
+type memItems struct{ m *Memory }
+
+func (r memItems) List(cartID []byte) ([]domain.CartItem, error) {
+	return sorted(r.m.data.items,
+		func(it domain.CartItem) bool { return bytes.Equal(it.CartID, cartID) },
+		func(it domain.CartItem) time.Time { return it.AddedAt }), nil
+}
+
+func (r memItems) Lock(cartID []byte, sku, variantID string) (*domain.CartItem, error) {
+	for _, e := range r.m.data.items {
+		if bytes.Equal(e.row.CartID, cartID) && e.row.SKU == sku && e.row.VariantID == variantID {
+			it := e.row
+			return &it, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memItems) Create(item *domain.CartItem) error {
+	if _, ok := r.m.data.items[string(item.CartItemID)]; ok {
+		return ErrDuplicate
+	}
+	item.AddedAt, item.UpdatedAt = r.m.stamp(item.AddedAt), r.m.stamp(item.UpdatedAt)
+	r.m.data.items[string(item.CartItemID)] = memRow[domain.CartItem]{*item, r.m.next()}
+	return nil
+}
+
+func (r memItems) Update(item *domain.CartItem) error {
+	e, ok := r.m.data.items[string(item.CartItemID)]
+	if !ok {
+		return nil
+	}
+	updated := *item
+	updated.CartID, updated.AddedAt = e.row.CartID, e.row.AddedAt
+	updated.UpdatedAt = r.m.now()
+	r.m.data.items[string(item.CartItemID)] = memRow[domain.CartItem]{updated, e.seq}
+	return nil
+}
+
+func (r memItems) Delete(cartItemID []byte) error {
+	delete(r.m.data.items, string(cartItemID))
+	return nil
+}
+
+type memPromotions struct{ m *Memory }
+
+func (r memPromotions) List(cartID []byte, f PromotionFilter) ([]domain.CartPromotion, error) {
+	return sorted(r.m.data.promos,
+		func(p domain.CartPromotion) bool {
+			return bytes.Equal(p.CartID, cartID) &&
+				(len(f.Types) == 0 || slices.Contains(f.Types, p.PromoType)) &&
+				(f.Code == "" || p.PromoCode == f.Code) &&
+				(len(f.Statuses) == 0 || slices.Contains(f.Statuses, p.Status))
+		},
+		func(p domain.CartPromotion) time.Time { return p.AppliedAt }), nil
+}
+
+func (r memPromotions) Create(p *domain.CartPromotion) error {
+	if _, ok := r.m.data.promos[string(p.CartPromoID)]; ok {
+		return ErrDuplicate
+	}
+	p.AppliedAt = r.m.stamp(p.AppliedAt)
+	r.m.data.promos[string(p.CartPromoID)] = memRow[domain.CartPromotion]{*p, r.m.next()}
+	return nil
+}
+
+func (r memPromotions) Update(p *domain.CartPromotion) error {
+	e, ok := r.m.data.promos[string(p.CartPromoID)]
+	if !ok {
+		return nil
+	}
+	e.row.Status, e.row.DiscountPaise, e.row.PromoMeta = p.Status, p.DiscountPaise, p.PromoMeta
+	r.m.data.promos[string(p.CartPromoID)] = e
+	return nil
+}
+
+func (r memPromotions) CountRedeemed(code string, cart *domain.Cart) (int, error) {
+	n := 0
+	for _, e := range r.m.data.promos {
+		if e.row.PromoCode != code || e.row.Status != "APPLIED" {
+			continue
+		}
+		c, ok := r.m.data.carts[string(e.row.CartID)]
+		if !ok || c.Status != domain.CartCheckedOut || bytes.Equal(c.CartID, cart.CartID) {
+			continue
+		}
+		if cart.OwnerType == "USER" && bytes.Equal(c.UserID, cart.UserID) ||
+			cart.OwnerType != "USER" && c.GuestID == cart.GuestID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+type memTotals struct{ m *Memory }
+
+func (r memTotals) Get(cartID []byte) (*domain.CartTotals, error) {
+	t, ok := r.m.data.totals[string(cartID)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &t, nil
+}
+
+func (r memTotals) Save(t *domain.CartTotals) error {
+	t.ComputedAt = r.m.stamp(t.ComputedAt)
+	r.m.data.totals[string(t.CartID)] = *t
+	return nil
+}
+
+type memOutbox struct{ m *Memory }
+
+func (r memOutbox) Add(row *domain.CartOutbox) error {
+	row.Seq = r.m.next()
+	row.CreatedAt = r.m.stamp(row.CreatedAt)
+	r.m.data.outbox = append(r.m.data.outbox, *row)
+	return nil
+}
+
+type memProcessedEvents struct{ m *Memory }
+
+func (r memProcessedEvents) Create(row *domain.ProcessedEvent) error {
+	key := string(row.EventID) + "\x00" + row.Consumer
+	if _, ok := r.m.data.events[key]; ok {
+		return ErrDuplicate
+	}
+	row.ProcessedAt = r.m.stamp(row.ProcessedAt)
+	r.m.data.events[key] = *row
+	return nil
+}
+
+type memHistory struct{ m *Memory }
+
+func (r memHistory) Append(e *domain.CartHistory) error {
+	e.Seq = r.m.next()
+	e.CreatedAt = r.m.stamp(e.CreatedAt)
+	r.m.data.history = append(r.m.data.history, *e)
+	return nil
+}
+
+func (r memHistory) List(cartID []byte, afterSeq uint64, limit int) ([]domain.CartHistory, error) {
+	var out []domain.CartHistory
+	for _, e := range r.m.data.history {
+		if len(out) == limit {
+			break
+		}
+		if bytes.Equal(e.CartID, cartID) && e.Seq > afterSeq {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+type memStoredValue struct{ m *Memory }
+
+func (r memStoredValue) LockAccount(accountID []byte) (*domain.StoredValueAccount, error) {
+	acc, ok := r.m.data.accounts[string(accountID)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &acc, nil
+}
+
+func (r memStoredValue) LockGiftCard(code string) (*domain.StoredValueAccount, error) {
+	return r.find(func(acc domain.StoredValueAccount) bool {
+		return acc.Kind == domain.PromoTypeGiftCard && acc.Code == code
+	})
+}
+
+func (r memStoredValue) LockWallet(userID []byte) (*domain.StoredValueAccount, error) {
+	return r.find(func(acc domain.StoredValueAccount) bool {
+		return acc.Kind == domain.PromoTypeWallet && bytes.Equal(acc.OwnerUserID, userID)
+	})
+}
+
+func (r memStoredValue) find(match func(domain.StoredValueAccount) bool) (*domain.StoredValueAccount, error) {
+	for _, acc := range r.m.data.accounts {
+		if match(acc) {
+			return &acc, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memStoredValue) SaveBalances(acc *domain.StoredValueAccount) error {
+	stored, ok := r.m.data.accounts[string(acc.AccountID)]
+	if !ok {
+		return nil
+	}
+	stored.BalancePaise, stored.HeldPaise = acc.BalancePaise, acc.HeldPaise
+	stored.UpdatedAt = r.m.now()
+	r.m.data.accounts[string(acc.AccountID)] = stored
+	return nil
+}
+
+func (r memStoredValue) CreateHold(h *domain.StoredValueHold) error {
+	h.CreatedAt, h.UpdatedAt = r.m.stamp(h.CreatedAt), r.m.stamp(h.UpdatedAt)
+	r.m.data.holds[string(h.HoldID)] = memRow[domain.StoredValueHold]{*h, r.m.next()}
+	return nil
+}
+
+func (r memStoredValue) LockHeldByCart(cartID []byte) ([]domain.StoredValueHold, error) {
+	return r.held(func(h domain.StoredValueHold) bool { return bytes.Equal(h.CartID, cartID) }), nil
+}
+
+func (r memStoredValue) LockHeldByPromotion(cartPromoID []byte) ([]domain.StoredValueHold, error) {
+	return r.held(func(h domain.StoredValueHold) bool { return bytes.Equal(h.CartPromoID, cartPromoID) }), nil
+}
+
+func (r memStoredValue) held(match func(domain.StoredValueHold) bool) []domain.StoredValueHold {
+	return sorted(r.m.data.holds,
+		func(h domain.StoredValueHold) bool { return h.Status == "HELD" && match(h) },
+		func(h domain.StoredValueHold) time.Time { return h.CreatedAt })
+}
+
+func (r memStoredValue) UpdateHold(h *domain.StoredValueHold) error {
+	e, ok := r.m.data.holds[string(h.HoldID)]
+	if !ok {
+		return nil
+	}
+	e.row.AmountPaise, e.row.Status = h.AmountPaise, h.Status
+	e.row.UpdatedAt = r.m.now()
+	r.m.data.holds[string(h.HoldID)] = e
+	return nil
+}
+
+func (r memStoredValue) AppendEntry(e *domain.StoredValueLedgerEntry) error {
+	e.CreatedAt = r.m.stamp(e.CreatedAt)
+	r.m.data.ledger = append(r.m.data.ledger, *e)
+	return nil
+}
+
+type memFXRates struct{ m *Memory }
+
+func (r memFXRates) Latest(base, quote string, at time.Time) (*domain.FXRate, error) {
+	var found *domain.FXRate
+	for _, rate := range r.m.data.rates {
+		if rate.BaseCurrency != base || rate.QuoteCurrency != quote || rate.EffectiveAt.After(at) {
+			continue
+		}
+		if found == nil || rate.EffectiveAt.After(found.EffectiveAt) {
+			found = &rate
+		}
+	}
+	return found, nil
+}
+
+type memSagas struct{ m *Memory }
+
+func (r memSagas) Create(s *domain.CheckoutSaga) error {
+	if _, ok := r.m.data.sagas[string(s.SagaID)]; ok {
+		return ErrDuplicate
+	}
+	s.CreatedAt, s.UpdatedAt = r.m.stamp(s.CreatedAt), r.m.stamp(s.UpdatedAt)
+	r.m.data.sagas[string(s.SagaID)] = memRow[domain.CheckoutSaga]{*s, r.m.next()}
+	return nil
+}
+
+func (r memSagas) Get(sagaID []byte) (*domain.CheckoutSaga, error) {
+	e, ok := r.m.data.sagas[string(sagaID)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &e.row, nil
+}
+
+func (r memSagas) Lock(sagaID []byte) (*domain.CheckoutSaga, error) { return r.Get(sagaID) }
+
+func (r memSagas) Latest(cartID []byte) (*domain.CheckoutSaga, error) {
+	sagas := sorted(r.m.data.sagas,
+		func(s domain.CheckoutSaga) bool { return bytes.Equal(s.CartID, cartID) },
+		func(s domain.CheckoutSaga) time.Time { return s.CreatedAt })
+	if len(sagas) == 0 {
+		return nil, ErrNotFound
+	}
+	return &sagas[len(sagas)-1], nil
+}
+
+func (r memSagas) Update(s *domain.CheckoutSaga) error {
+	e, ok := r.m.data.sagas[string(s.SagaID)]
+	if !ok {
+		return nil
+	}
+	e.row.Status, e.row.Lines = s.Status, s.Lines
+	e.row.FailureReason, e.row.Error = s.FailureReason, s.Error
+	e.row.LeaseUntil, e.row.CompletedAt = s.LeaseUntil, s.CompletedAt
+	e.row.UpdatedAt = r.m.now()
+	r.m.data.sagas[string(s.SagaID)] = e
+	return nil
+}
+
+func (r memSagas) Stalled(statuses []string, now time.Time, limit int) ([][]byte, error) {
+	sagas := sorted(r.m.data.sagas,
+		func(s domain.CheckoutSaga) bool {
+			return slices.Contains(statuses, s.Status) && !s.LeaseUntil.After(now)
+		},
+		func(s domain.CheckoutSaga) time.Time { return s.LeaseUntil })
+	var ids [][]byte
+	for i := 0; i < len(sagas) && i < limit; i++ {
+		ids = append(ids, sagas[i].SagaID)
+	}
+	return ids, nil
+}
+
+func (r memSagas) Claim(sagaID []byte, statuses []string, now, until time.Time) (bool, error) {
+	e, ok := r.m.data.sagas[string(sagaID)]
+	if !ok || !slices.Contains(statuses, e.row.Status) || e.row.LeaseUntil.After(now) {
+		return false, nil
+	}
+	e.row.LeaseUntil = until
+	r.m.data.sagas[string(sagaID)] = e
+	return true, nil
+}
+
+type memIdempotency struct{ m *Memory }
+
+func idemKey(clientID, key string) string { return clientID + "\x00" + key }
+
+func (r memIdempotency) Create(row *domain.CartIdempotency) error {
+	k := idemKey(row.ClientID, row.IdempotencyKey)
+	if _, ok := r.m.data.idem[k]; ok {
+		return ErrDuplicate
+	}
+	row.CreatedAt = r.m.stamp(row.CreatedAt)
+	r.m.data.idem[k] = *row
+	return nil
+}
+
+func (r memIdempotency) Lock(clientID, key string) (*domain.CartIdempotency, error) {
+	row, ok := r.m.data.idem[idemKey(clientID, key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &row, nil
+}
+
+func (r memIdempotency) Relock(clientID, key string, lockedAt time.Time) error {
+	k := idemKey(clientID, key)
+	if row, ok := r.m.data.idem[k]; ok && row.State == "IN_PROGRESS" {
+		row.LockedAt = &lockedAt
+		r.m.data.idem[k] = row
+	}
+	return nil
+}
+
+// held returns the IN_PROGRESS row still held under lockedAt.
+func (r memIdempotency) held(clientID, key string, lockedAt time.Time) (domain.CartIdempotency, bool) {
+	row, ok := r.m.data.idem[idemKey(clientID, key)]
+	return row, ok && row.State == "IN_PROGRESS" && row.LockedAt != nil && row.LockedAt.Equal(lockedAt)
+}
+
+func (r memIdempotency) Complete(clientID, key string, lockedAt time.Time, resp domain.CartIdempotency) (bool, error) {
+	row, ok := r.held(clientID, key, lockedAt)
+	if !ok {
+		return false, nil
+	}
+	if resp.ResourceID != nil {
+		row.ResourceID = resp.ResourceID
+	}
+	row.HTTPStatus, row.ResponseBody, row.ResponseHeaders = resp.HTTPStatus, resp.ResponseBody, resp.ResponseHeaders
+	row.State = "COMPLETED"
+	r.m.data.idem[idemKey(clientID, key)] = row
+	return true, nil
+}
+
+func (r memIdempotency) Delete(clientID, key string, lockedAt time.Time) error {
+	if _, ok := r.held(clientID, key, lockedAt); ok {
+		delete(r.m.data.idem, idemKey(clientID, key))
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+)
+
+func TestMemoryRollsBack(t *testing.T) {
+	m := NewMemory()
+	cart := domain.Cart{CartID: []byte("cart-0000000001"), OwnerType: "GUEST", GuestID: "g", Status: domain.CartActive}
+	if err := m.Insert(cart); err != nil {
+		t.Fatal(err)
+	}
+
+	boom := errors.New("boom")
+	err := m.Transaction(context.Background(), func(tx Tx) error {
+		c, err := tx.Carts().Lock(cart.CartID)
+		if err != nil {
+			return err
+		}
+		c.Status = domain.CartAbandoned
+		if err := tx.Carts().Bump(c); err != nil {
+			return err
+		}
+		if err := tx.Items().Create(&domain.CartItem{CartItemID: []byte("item-1"), CartID: cart.CartID, SKU: "A", Qty: 1}); err != nil {
+			return err
+		}
+		if err := tx.Outbox().Add(&domain.CartOutbox{EventType: "X"}); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("err = %v", err)
+	}
+
+	_ = m.Transaction(context.Background(), func(tx Tx) error {
+		c, err := tx.Carts().Get(cart.CartID)
+		if err != nil || c.Status != domain.CartActive || c.Version != 0 {
+			t.Errorf("cart after rollback = %+v, %v", c, err)
+		}
+		if items, _ := tx.Items().List(cart.CartID); len(items) != 0 {
+			t.Errorf("items after rollback = %v", items)
+		}
+		return nil
+	})
+	if rows := m.Outbox(); len(rows) != 0 {
+		t.Errorf("outbox after rollback = %v", rows)
+	}
+}
+
+func TestMemoryBump(t *testing.T) {
+	m := NewMemory()
+	cart := domain.Cart{CartID: []byte("cart-0000000001"), OwnerType: "GUEST", GuestID: "g", Status: domain.CartActive, Version: 3}
+	if err := m.Insert(cart); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = m.Transaction(context.Background(), func(tx Tx) error {
+		stale := cart
+		stale.Version = 2
+		if err := tx.Carts().Bump(&stale); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("stale bump: err = %v", err)
+		}
+
+		c, _ := tx.Carts().Lock(cart.CartID)
+		c.ShipPincode = "560001"
+		if err := tx.Carts().Bump(c); err != nil || c.Version != 4 {
+			t.Errorf("bump: version %d, err %v", c.Version, err)
+		}
+		got, _ := tx.Carts().Get(cart.CartID)
+		if got.Version != 4 || got.ShipPincode != "560001" {
+			t.Errorf("stored cart = %+v", got)
+		}
+		return nil
+	})
+}
+
+func TestMemoryOrdering(t *testing.T) {
+	m := NewMemory()
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cartID := []byte("cart-0000000001")
+	if err := m.Insert(
+		domain.CartItem{CartItemID: []byte("c"), CartID: cartID, SKU: "C", AddedAt: at.Add(time.Minute)},
+		domain.CartItem{CartItemID: []byte("b"), CartID: cartID, SKU: "B", AddedAt: at},
+		domain.CartItem{CartItemID: []byte("a"), CartID: cartID, SKU: "A", AddedAt: at},
+		domain.CartItem{CartItemID: []byte("x"), CartID: []byte("other"), SKU: "X", AddedAt: at},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = m.Transaction(context.Background(), func(tx Tx) error {
+		items, err := tx.Items().List(cartID)
+		var skus string
+		for _, it := range items {
+			skus += it.SKU
+		}
+		// Same added_at: insertion order breaks the tie.
+		if err != nil || skus != "BAC" {
+			t.Errorf("items = %q, %v; want BAC", skus, err)
+		}
+		return nil
+	})
+}
+
+func TestMemoryIdempotencyDuplicate(t *testing.T) {
+	m := NewMemory()
+	row := domain.CartIdempotency{ClientID: "web", IdempotencyKey: "k1", State: "IN_PROGRESS"}
+	err := m.Transaction(context.Background(), func(tx Tx) error {
+		if err := tx.Idempotency().Create(&row); err != nil {
+			return err
+		}
+		return tx.Idempotency().Create(&row)
+	})
+	if !errors.Is(err, ErrDuplicate) {
+		t.Errorf("err = %v, want ErrDuplicate", err)
+	}
+}
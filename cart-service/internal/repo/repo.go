@@ -0,0 +1,174 @@
+// Package repo is cart-service persistence behind interfaces. A Store runs
+// units of work; each one gets a Tx whose repositories share the
+// transaction, so a cart change, its totals, its outbox events and the
+// idempotency record commit or roll back together.
+//
+// NewGormStore is the MySQL implementation; NewMemory keeps everything in
+// process for tests. Worker jobs that need MySQL-only queries (SKIP LOCKED
+// batches) open gorm transactions themselves and wrap them with GormTx to
+// call code written against these interfaces.
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+)
+
+var (
+	// ErrNotFound means the row looked up does not exist.
+	ErrNotFound = errors.New("repo: not found")
+	// ErrVersionConflict means the cart is no longer at the version the
+	// caller loaded.
+	ErrVersionConflict = errors.New("repo: cart version changed")
+	// ErrDuplicate means a row with the same key already exists.
+	ErrDuplicate = errors.New("repo: duplicate key")
+)
+
+// Store runs units of work.
+type Store interface {
+	// Transaction runs fn in one transaction: it commits when fn returns nil
+	// and rolls back when fn returns an error or panics.
+	Transaction(ctx context.Context, fn func(tx Tx) error) error
+}
+
+// Tx is the repositories of one transaction.
+type Tx interface {
+	Carts() CartRepository
+	Items() CartItemRepository
+	Promotions() PromotionRepository
+	Totals() TotalsRepository
+	Outbox() OutboxRepository
+	StoredValue() StoredValueRepository
+	FXRates() FXRateRepository
+	Sagas() SagaRepository
+	Idempotency() IdempotencyRepository
+	History() HistoryRepository
+	ProcessedEvents() ProcessedEventRepository
+}
+
+// Lock methods hold the row until the transaction ends (SELECT ... FOR
+// UPDATE); lookups of one row return ErrNotFound when there is none.
+
+type CartRepository interface {
+	Get(cartID []byte) (*domain.Cart, error)
+	Lock(cartID []byte) (*domain.Cart, error)
+	// FindActive returns the ACTIVE cart of an owner on a channel: by userID
+	// for USER owners, by guestID for GUEST ones.
+	FindActive(ownerType string, userID []byte, guestID, channel string) (*domain.Cart, error)
+	// ActiveWithSKU lists the ids of ACTIVE carts holding sku (and
+	// variantID, when set), ordered by cart_id so concurrent callers lock
+	// them in the same order.
+	ActiveWithSKU(sku, variantID string) ([][]byte, error)
+	Create(cart *domain.Cart) error
+	// Bump saves the cart's status, ship_pincode and expires_at at the next
+	// version and increments cart.Version. It returns ErrVersionConflict
+	// when the stored cart is no longer at cart.Version.
+	Bump(cart *domain.Cart) error
+}
+
+type CartItemRepository interface {
+	// List returns the cart's lines in the order they were added.
+	List(cartID []byte) ([]domain.CartItem, error)
+	Lock(cartID []byte, sku, variantID string) (*domain.CartItem, error)
+	Create(item *domain.CartItem) error
+	// Update saves every column of an existing line but its ids and added_at.
+	Update(item *domain.CartItem) error
+	Delete(cartItemID []byte) error
+}
+
+// PromotionFilter selects promotions of a cart; empty fields match any.
+type PromotionFilter struct {
+	Types    []string
+	Code     string
+	Statuses []string
+}
+
+type PromotionRepository interface {
+	// List returns the cart's promotions matching f in the order they were
+	// applied.
+	List(cartID []byte, f PromotionFilter) ([]domain.CartPromotion, error)
+	Create(p *domain.CartPromotion) error
+	// Update saves the status, discount and meta of a promotion.
+	Update(p *domain.CartPromotion) error
+	// CountRedeemed counts APPLIED promotions with code on CHECKED_OUT carts
+	// of the same owner as cart, other than cart itself.
+	CountRedeemed(code string, cart *domain.Cart) (int, error)
+}
+
+type TotalsRepository interface {
+	Get(cartID []byte) (*domain.CartTotals, error)
+	// Save inserts or replaces the cart's totals.
+	Save(t *domain.CartTotals) error
+}
+
+type OutboxRepository interface {
+	Add(row *domain.CartOutbox) error
+}
+
+type HistoryRepository interface {
+	Append(e *domain.CartHistory) error
+	// List returns up to limit entries of the cart after seq, oldest first.
+	List(cartID []byte, afterSeq uint64, limit int) ([]domain.CartHistory, error)
+}
+
+type ProcessedEventRepository interface {
+	// Create records that row.Consumer processed row.EventID. It returns
+	// ErrDuplicate when the consumer already has.
+	Create(row *domain.ProcessedEvent) error
+}
+
+type StoredValueRepository interface {
+	LockAccount(accountID []byte) (*domain.StoredValueAccount, error)
+	LockGiftCard(code string) (*domain.StoredValueAccount, error)
+	LockWallet(userID []byte) (*domain.StoredValueAccount, error)
+	// SaveBalances saves the balance and held amounts of an account.
+	SaveBalances(acc *domain.StoredValueAccount) error
+
+	CreateHold(h *domain.StoredValueHold) error
+	// LockHeldByCart and LockHeldByPromotion return the HELD holds of a
+	// cart or of one cart promotion.
+	LockHeldByCart(cartID []byte) ([]domain.StoredValueHold, error)
+	LockHeldByPromotion(cartPromoID []byte) ([]domain.StoredValueHold, error)
+	// UpdateHold saves the amount and status of a hold.
+	UpdateHold(h *domain.StoredValueHold) error
+	AppendEntry(e *domain.StoredValueLedgerEntry) error
+}
+
+type FXRateRepository interface {
+	// Latest returns the newest base->quote rate effective at or before at,
+	// or nil when none is.
+	Latest(base, quote string, at time.Time) (*domain.FXRate, error)
+}
+
+type SagaRepository interface {
+	Create(s *domain.CheckoutSaga) error
+	Get(sagaID []byte) (*domain.CheckoutSaga, error)
+	Lock(sagaID []byte) (*domain.CheckoutSaga, error)
+	// Latest returns the cart's most recent saga.
+	Latest(cartID []byte) (*domain.CheckoutSaga, error)
+	// Update saves the status, lines, failure, lease and completion time.
+	Update(s *domain.CheckoutSaga) error
+	// Stalled lists up to limit sagas in one of statuses whose lease lapsed
+	// by now, oldest lease first.
+	Stalled(statuses []string, now time.Time, limit int) ([][]byte, error)
+	// Claim extends the lease of a saga Stalled returned to until. It
+	// reports false when another runner claimed it first.
+	Claim(sagaID []byte, statuses []string, now, until time.Time) (bool, error)
+}
+
+type IdempotencyRepository interface {
+	// Create returns ErrDuplicate when the key is already claimed.
+	Create(row *domain.CartIdempotency) error
+	Lock(clientID, key string) (*domain.CartIdempotency, error)
+	// Relock moves the IN_PROGRESS lease of a stuck row to lockedAt.
+	Relock(clientID, key string, lockedAt time.Time) error
+	// Complete stores resp's ResourceID (when set), HTTPStatus, ResponseBody
+	// and ResponseHeaders on the row still held under lockedAt and marks it
+	// COMPLETED. It reports false when the lease was lost.
+	Complete(clientID, key string, lockedAt time.Time, resp domain.CartIdempotency) (bool, error)
+	// Delete removes an IN_PROGRESS row still held under lockedAt.
+	Delete(clientID, key string, lockedAt time.Time) error
+}
@@ -0,0 +1,214 @@
+package shipping
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+)
+
+// Shipping charges are driven by a JSON rules file: the destination pincode
+// picks a zone, the cart's chargeable weight and channel pick the first
+// matching rule, and a per-channel threshold can make shipping free.
+
+const anyChannel = "*"
+
+type Zone struct {
+	Zone            string   `json:"zone"`
+	PincodePrefixes []string `json:"pincode_prefixes"`
+}
+
+type FreeShipping struct {
+	Channel        string `json:"channel"` // "*" matches every channel
+	ThresholdPaise int64  `json:"threshold_paise"`
+}
+
+// Rule charges BasePaise up to BaseWeightGrams, then ExtraStepPaise for every
+// started ExtraStepGrams above it.
+type Rule struct {
+	ID             string `json:"id"`
+	Description    string `json:"description"`
+	Zone           string `json:"zone"`    // "*" matches every zone
+	Channel        string `json:"channel"` // "*" matches every channel
+	MinWeightGrams int64  `json:"min_weight_grams"`
+	MaxWeightGrams int64  `json:"max_weight_grams"` // 0 = no upper bound
+
+	BasePaise       int64 `json:"base_paise"`
+	BaseWeightGrams int64 `json:"base_weight_grams"`
+	ExtraStepGrams  int64 `json:"extra_step_grams"`
+	ExtraStepPaise  int64 `json:"extra_step_paise"`
+}
+
+type Config struct {
+	// Currency of every *_paise amount in the file; empty means INR. Carts in
+	// another currency have their quote converted by the caller.
+	Currency string `json:"currency"`
+	// VolumetricDivisor converts cm^3 to kg of volumetric weight (5000 is the
+	// usual courier figure).
+	VolumetricDivisor int64          `json:"volumetric_divisor"`
+	DefaultZone       string         `json:"default_zone"`
+	Zones             []Zone         `json:"zones"`
+	FreeShipping      []FreeShipping `json:"free_shipping"`
+	Rules             []Rule         `json:"rules"`
+}
+
+// Input describes the cart being shipped.
+type Input struct {
+	Items   []domain.CartItem
+	Pincode string
+	Channel string
+	// OrderValuePaise is compared with the free shipping threshold
+	// (subtotal after discounts, before tax).
+	OrderValuePaise int64
+}
+
+// Quote is the computed charge and the reason for it.
+type Quote struct {
+	Paise           int64  `json:"shipping_paise"`
+	Currency        string `json:"currency"`
+	RuleID          string `json:"rule_id"`
+	Description     string `json:"description,omitempty"`
+	Zone            string `json:"zone"`
+	ChargeableGrams int64  `json:"chargeable_grams"`
+	Free            bool   `json:"free"`
+	ThresholdPaise  int64  `json:"free_threshold_paise,omitempty"`
+}
+
+// Rule IDs reported when no configured rule produced the charge.
+const (
+	RuleEmptyCart    = "EMPTY_CART"
+	RuleFreeShipping = "FREE_SHIPPING_THRESHOLD"
+	RuleNoMatch      = "NO_MATCHING_RULE"
+)
+
+type Calculator struct {
+	cfg Config
+}
+
+func NewCalculator(cfg Config) (*Calculator, error) {
+	if cfg.VolumetricDivisor <= 0 {
+		cfg.VolumetricDivisor = 5000
+	}
+	if cfg.Currency == "" {
+		cfg.Currency = "INR"
+	}
+	for _, r := range cfg.Rules {
+		if r.ID == "" {
+			return nil, fmt.Errorf("shipping: rule without id")
+		}
+		if r.ExtraStepPaise > 0 && r.ExtraStepGrams <= 0 {
+			return nil, fmt.Errorf("shipping: rule %s needs extra_step_grams", r.ID)
+		}
+	}
+	return &Calculator{cfg: cfg}, nil
+}
+
+// LoadFile reads a JSON rules file.
+func LoadFile(path string) (*Calculator, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, err
+	}
+	return NewCalculator(cfg)
+}
+
+// Currency is the currency quotes and thresholds are in.
+func (c *Calculator) Currency() string { return c.cfg.Currency }
+
+// Quote computes the shipping charge for a cart. OrderValuePaise must be in
+// Currency.
+func (c *Calculator) Quote(in Input) Quote {
+	q := Quote{Currency: c.cfg.Currency, Zone: c.zoneFor(in.Pincode)}
+	for _, it := range in.Items {
+		q.ChargeableGrams += c.chargeableGrams(it)
+	}
+	if len(in.Items) == 0 {
+		q.RuleID = RuleEmptyCart
+		return q
+	}
+
+	if threshold, ok := c.freeThreshold(in.Channel); ok {
+		q.ThresholdPaise = threshold
+		if in.OrderValuePaise >= threshold {
+			q.RuleID = RuleFreeShipping
+			q.Free = true
+			return q
+		}
+	}
+
+	for _, r := range c.cfg.Rules {
+		if !matches(r.Zone, q.Zone) || !matches(r.Channel, in.Channel) {
+			continue
+		}
+		if q.ChargeableGrams < r.MinWeightGrams || (r.MaxWeightGrams > 0 && q.ChargeableGrams > r.MaxWeightGrams) {
+			continue
+		}
+		q.RuleID = r.ID
+		q.Description = r.Description
+		q.Paise = r.BasePaise
+		if extra := q.ChargeableGrams - r.BaseWeightGrams; extra > 0 && r.ExtraStepPaise > 0 {
+			steps := (extra + r.ExtraStepGrams - 1) / r.ExtraStepGrams
+			q.Paise += steps * r.ExtraStepPaise
+		}
+		return q
+	}
+	q.RuleID = RuleNoMatch
+	return q
+}
+
+func (c *Calculator) zoneFor(pincode string) string {
+	best, bestLen := c.cfg.DefaultZone, 0
+	for _, z := range c.cfg.Zones {
+		for _, p := range z.PincodePrefixes {
+			if len(p) > bestLen && strings.HasPrefix(pincode, p) {
+				best, bestLen = z.Zone, len(p)
+			}
+		}
+	}
+	return best
+}
+
+func (c *Calculator) freeThreshold(channel string) (int64, bool) {
+	var fallback *FreeShipping
+	for i, f := range c.cfg.FreeShipping {
+		if f.Channel == channel {
+			return f.ThresholdPaise, true
+		}
+		if f.Channel == anyChannel && fallback == nil {
+			fallback = &c.cfg.FreeShipping[i]
+		}
+	}
+	if fallback != nil {
+		return fallback.ThresholdPaise, true
+	}
+	return 0, false
+}
+
+// chargeableGrams is max(actual, volumetric) weight of a line, read from
+// ProductMeta: weight_grams, length_cm, width_cm, height_cm.
+func (c *Calculator) chargeableGrams(it domain.CartItem) int64 {
+	var meta struct {
+		WeightGrams float64 `json:"weight_grams"`
+		LengthCM    float64 `json:"length_cm"`
+		WidthCM     float64 `json:"width_cm"`
+		HeightCM    float64 `json:"height_cm"`
+	}
+	if it.ProductMeta != "" {
+		_ = json.Unmarshal([]byte(it.ProductMeta), &meta)
+	}
+	unit := meta.WeightGrams
+	if vol := meta.LengthCM * meta.WidthCM * meta.HeightCM * 1000 / float64(c.cfg.VolumetricDivisor); vol > unit {
+		unit = vol
+	}
+	return int64(unit+0.5) * int64(it.Qty)
+}
+
+func matches(pattern, v string) bool {
+	return pattern == "" || pattern == anyChannel || pattern == v
+}
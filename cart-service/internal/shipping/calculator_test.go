@@ -0,0 +1,95 @@
+package shipping
+
+import (
+	"testing"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+)
+
+func testCalculator(t *testing.T) *Calculator {
+	t.Helper()
+	c, err := NewCalculator(Config{
+		DefaultZone: "NATIONAL",
+		Zones: []Zone{
+			{Zone: "LOCAL", PincodePrefixes: []string{"560"}},
+			{Zone: "REGIONAL", PincodePrefixes: []string{"56"}},
+		},
+		FreeShipping: []FreeShipping{
+			{Channel: "APP", ThresholdPaise: 30000},
+			{Channel: "*", ThresholdPaise: 50000},
+		},
+		Rules: []Rule{
+			{ID: "LOCAL", Zone: "LOCAL", BasePaise: 2900, BaseWeightGrams: 1000, ExtraStepGrams: 500, ExtraStepPaise: 1000},
+			{ID: "HEAVY", Zone: "*", MinWeightGrams: 10001, BasePaise: 29900},
+			{ID: "STD", Zone: "*", BasePaise: 6900, BaseWeightGrams: 1000, ExtraStepGrams: 500, ExtraStepPaise: 2500},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func item(qty int, meta string) domain.CartItem {
+	return domain.CartItem{Qty: qty, ProductMeta: meta}
+}
+
+func TestQuote(t *testing.T) {
+	c := testCalculator(t)
+
+	tests := []struct {
+		name   string
+		in     Input
+		rule   string
+		zone   string
+		paise  int64
+		grams  int64
+		isFree bool
+	}{
+		{
+			name: "empty cart",
+			in:   Input{Pincode: "560001"},
+			rule: RuleEmptyCart, zone: "LOCAL",
+		},
+		{
+			name: "longest prefix wins",
+			in:   Input{Items: []domain.CartItem{item(1, `{"weight_grams":800}`)}, Pincode: "560001", Channel: "WEB"},
+			rule: "LOCAL", zone: "LOCAL", paise: 2900, grams: 800,
+		},
+		{
+			name: "extra weight charged per started step",
+			in:   Input{Items: []domain.CartItem{item(2, `{"weight_grams":600}`)}, Pincode: "560001", Channel: "WEB"},
+			rule: "LOCAL", zone: "LOCAL", paise: 3900, grams: 1200,
+		},
+		{
+			name: "volumetric weight beats actual weight",
+			// 30*20*10 cm = 6000 cm3 / 5000 = 1.2 kg
+			in:   Input{Items: []domain.CartItem{item(1, `{"weight_grams":300,"length_cm":30,"width_cm":20,"height_cm":10}`)}, Pincode: "110001", Channel: "WEB"},
+			rule: "STD", zone: "NATIONAL", paise: 6900 + 2500, grams: 1200,
+		},
+		{
+			name: "heavy rule by weight band",
+			in:   Input{Items: []domain.CartItem{item(3, `{"weight_grams":4000}`)}, Pincode: "400001", Channel: "WEB"},
+			rule: "HEAVY", zone: "NATIONAL", paise: 29900, grams: 12000,
+		},
+		{
+			name: "channel specific free shipping threshold",
+			in:   Input{Items: []domain.CartItem{item(1, `{"weight_grams":500}`)}, Pincode: "400001", Channel: "APP", OrderValuePaise: 30000},
+			rule: RuleFreeShipping, zone: "NATIONAL", grams: 500, isFree: true,
+		},
+		{
+			name: "wildcard threshold not met",
+			in:   Input{Items: []domain.CartItem{item(1, `{"weight_grams":500}`)}, Pincode: "400001", Channel: "WEB", OrderValuePaise: 30000},
+			rule: "STD", zone: "NATIONAL", paise: 6900, grams: 500,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			q := c.Quote(tc.in)
+			if q.RuleID != tc.rule || q.Zone != tc.zone || q.Paise != tc.paise || q.ChargeableGrams != tc.grams || q.Free != tc.isFree {
+				t.Fatalf("got %+v", q)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package storedvalue
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/repo"
+
+	"github.com/google/uuid"
+)
+
+// Gift card and wallet balances. A hold reserves part of an account balance
+// for one cart promotion; it is captured on checkout or released when the
+// promotion is removed or the cart expires. All functions run inside the
+// caller's transaction and append one ledger entry per movement.
+
+// Hold states.
+const (
+	HoldHeld     = "HELD"
+	HoldCaptured = "CAPTURED"
+	HoldReleased = "RELEASED"
+)
+
+// Ledger entry types.
+const (
+	EntryHold    = "HOLD"
+	EntryCapture = "CAPTURE"
+	EntryRelease = "RELEASE"
+)
+
+var (
+	ErrAccountNotFound     = errors.New("stored value account not found")
+	ErrAccountInactive     = errors.New("stored value account is not active")
+	ErrAccountExpired      = errors.New("stored value account has expired")
+	ErrCurrencyMismatch    = errors.New("stored value currency does not match cart")
+	ErrInsufficientBalance = errors.New("stored value account has no available balance")
+)
+
+// Reason maps a hold error to the machine-readable promotion rejection code.
+func Reason(err error) string {
+	switch {
+	case errors.Is(err, ErrAccountNotFound):
+		return "ACCOUNT_NOT_FOUND"
+	case errors.Is(err, ErrAccountInactive):
+		return "ACCOUNT_INACTIVE"
+	case errors.Is(err, ErrAccountExpired):
+		return "ACCOUNT_EXPIRED"
+	case errors.Is(err, ErrCurrencyMismatch):
+		return "CURRENCY_MISMATCH"
+	case errors.Is(err, ErrInsufficientBalance):
+		return "INSUFFICIENT_BALANCE"
+	}
+	return ""
+}
+
+// LockGiftCard loads a gift card by code with FOR UPDATE.
+func LockGiftCard(sv repo.StoredValueRepository, code string) (*domain.StoredValueAccount, error) {
+	return account(sv.LockGiftCard(code))
+}
+
+// LockWallet loads a user's wallet with FOR UPDATE.
+func LockWallet(sv repo.StoredValueRepository, userID []byte) (*domain.StoredValueAccount, error) {
+	return account(sv.LockWallet(userID))
+}
+
+func account(acc *domain.StoredValueAccount, err error) (*domain.StoredValueAccount, error) {
+	if errors.Is(err, repo.ErrNotFound) {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// Hold reserves up to maxPaise of the account's available balance for a cart
+// promotion and returns the hold.
+func Hold(sv repo.StoredValueRepository, acc *domain.StoredValueAccount, cart *domain.Cart, cartPromoID []byte, maxPaise int64, now time.Time) (*domain.StoredValueHold, error) {
+	if acc.Status != "ACTIVE" {
+		return nil, ErrAccountInactive
+	}
+	if acc.ExpiresAt != nil && !now.Before(*acc.ExpiresAt) {
+		return nil, ErrAccountExpired
+	}
+	if acc.Currency != cart.Currency {
+		return nil, ErrCurrencyMismatch
+	}
+	amount := min(acc.BalancePaise-acc.HeldPaise, maxPaise)
+	if amount <= 0 {
+		return nil, ErrInsufficientBalance
+	}
+
+	hold := &domain.StoredValueHold{
+		HoldID:      domain.UUIDToBin16(uuid.New()),
+		AccountID:   acc.AccountID,
+		CartID:      cart.CartID,
+		CartPromoID: cartPromoID,
+		AmountPaise: amount,
+		Status:      HoldHeld,
+	}
+	if err := sv.CreateHold(hold); err != nil {
+		return nil, err
+	}
+	acc.HeldPaise += amount
+	if err := sv.SaveBalances(acc); err != nil {
+		return nil, err
+	}
+	return hold, appendEntry(sv, acc, hold, EntryHold, amount, "")
+}
+
+// Capture turns every HELD hold on the cart into a debit.
+func Capture(sv repo.StoredValueRepository, cartID []byte) error {
+	holds, err := sv.LockHeldByCart(cartID)
+	if err != nil {
+		return err
+	}
+	return settle(sv, holds, EntryCapture, "checkout")
+}
+
+// ReleaseCart gives back every HELD hold on the cart.
+func ReleaseCart(sv repo.StoredValueRepository, cartID []byte, reason string) error {
+	holds, err := sv.LockHeldByCart(cartID)
+	if err != nil {
+		return err
+	}
+	return settle(sv, holds, EntryRelease, reason)
+}
+
+// ReleasePromotion gives back the hold behind one cart promotion.
+func ReleasePromotion(sv repo.StoredValueRepository, cartPromoID []byte, reason string) error {
+	holds, err := sv.LockHeldByPromotion(cartPromoID)
+	if err != nil {
+		return err
+	}
+	return settle(sv, holds, EntryRelease, reason)
+}
+
+// Shrink releases the part of a promotion's hold above newPaise, used when
+// the cart total drops below what was held.
+func Shrink(sv repo.StoredValueRepository, cartPromoID []byte, newPaise int64) error {
+	holds, err := sv.LockHeldByPromotion(cartPromoID)
+	if err != nil {
+		return err
+	}
+	for i := range holds {
+		h := &holds[i]
+		excess := h.AmountPaise - max(newPaise, 0)
+		if excess <= 0 {
+			continue
+		}
+		acc, err := sv.LockAccount(h.AccountID)
+		if err != nil {
+			return err
+		}
+		h.AmountPaise -= excess
+		acc.HeldPaise -= excess
+		if err := sv.UpdateHold(h); err != nil {
+			return err
+		}
+		if err := sv.SaveBalances(acc); err != nil {
+			return err
+		}
+		if err := appendEntry(sv, acc, h, EntryRelease, excess, "cart total reduced"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func settle(sv repo.StoredValueRepository, holds []domain.StoredValueHold, entryType, reason string) error {
+	status := HoldReleased
+	if entryType == EntryCapture {
+		status = HoldCaptured
+	}
+	for i := range holds {
+		h := &holds[i]
+		acc, err := sv.LockAccount(h.AccountID)
+		if err != nil {
+			return err
+		}
+		acc.HeldPaise -= h.AmountPaise
+		if entryType == EntryCapture {
+			acc.BalancePaise -= h.AmountPaise
+		}
+		h.Status = status
+		if err := sv.UpdateHold(h); err != nil {
+			return err
+		}
+		if err := sv.SaveBalances(acc); err != nil {
+			return err
+		}
+		if err := appendEntry(sv, acc, h, entryType, h.AmountPaise, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendEntry(sv repo.StoredValueRepository, acc *domain.StoredValueAccount, h *domain.StoredValueHold, entryType string, amount int64, reason string) error {
+	return sv.AppendEntry(&domain.StoredValueLedgerEntry{
+		EntryID:           domain.UUIDToBin16(uuid.New()),
+		AccountID:         acc.AccountID,
+		HoldID:            h.HoldID,
+		CartID:            h.CartID,
+		EntryType:         entryType,
+		AmountPaise:       amount,
+		BalanceAfterPaise: acc.BalancePaise,
+		HeldAfterPaise:    acc.HeldPaise,
+		Reason:            reason,
+	})
+}
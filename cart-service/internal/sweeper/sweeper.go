@@ -0,0 +1,169 @@
+package sweeper
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"time"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/cart-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+// The sweeper keeps cart_idempotency and cart_outbox from growing without
+// bound. Each pass removes rows in batches of BatchSize, committing after
+// every batch so no statement holds locks on a large range, and stops after
+// MaxBatches so one pass never monopolises the database.
+
+// Metrics are published under "cart_sweeper" on /debug/vars.
+var metrics = expvar.NewMap("cart_sweeper")
+
+const (
+	metricRuns               = "runs"
+	metricErrors             = "errors"
+	metricIdempotencyDeleted = "idempotency_deleted"
+	metricOutboxDeleted      = "outbox_deleted"
+	metricOutboxArchived     = "outbox_archived"
+)
+
+type Options struct {
+	Interval   time.Duration // time between passes
+	BatchSize  int           // rows per DELETE
+	MaxBatches int           // batches per table per pass
+
+	// OutboxRetention is how long PUBLISHED rows are kept after publishing.
+	OutboxRetention time.Duration
+	// ArchiveOutbox copies swept outbox rows to cart_outbox_archive before
+	// deleting them.
+	ArchiveOutbox bool
+}
+
+type Sweeper struct {
+	db   *gorm.DB
+	opts Options
+	now  func() time.Time
+}
+
+func New(db *gorm.DB, opts Options) *Sweeper {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.MaxBatches <= 0 {
+		opts.MaxBatches = 20
+	}
+	if opts.OutboxRetention <= 0 {
+		opts.OutboxRetention = 7 * 24 * time.Hour
+	}
+	return &Sweeper{db: db, opts: opts, now: time.Now}
+}
+
+func (s *Sweeper) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			s.SweepOnce(ctx)
+		}
+	}
+}
+
+// SweepOnce runs one pass over every table.
+func (s *Sweeper) SweepOnce(ctx context.Context) {
+	metrics.Add(metricRuns, 1)
+	now := s.now().UTC()
+
+	if n, err := s.sweepIdempotency(ctx, now); err != nil {
+		metrics.Add(metricErrors, 1)
+		log.Printf("sweeper: idempotency: %v\n", err)
+	} else if n > 0 {
+		log.Printf("sweeper: deleted %d expired idempotency keys\n", n)
+	}
+
+	if n, err := s.sweepOutbox(ctx, now.Add(-s.opts.OutboxRetention)); err != nil {
+		metrics.Add(metricErrors, 1)
+		log.Printf("sweeper: outbox: %v\n", err)
+	} else if n > 0 {
+		log.Printf("sweeper: removed %d published outbox rows\n", n)
+	}
+}
+
+// sweepIdempotency deletes keys past expires_at. Expired IN_PROGRESS rows
+// belong to requests that died long ago and are removed as well.
+func (s *Sweeper) sweepIdempotency(ctx context.Context, now time.Time) (int64, error) {
+	var total int64
+	for i := 0; i < s.opts.MaxBatches; i++ {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		res := s.db.WithContext(ctx).
+			Exec(`DELETE FROM cart_idempotency WHERE expires_at < ? LIMIT ?`, now, s.opts.BatchSize)
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+		metrics.Add(metricIdempotencyDeleted, res.RowsAffected)
+		if res.RowsAffected < int64(s.opts.BatchSize) {
+			break
+		}
+	}
+	return total, nil
+}
+
+// sweepOutbox removes PUBLISHED rows published before cutoff, archiving them
+// first when configured. Rows in any other state are never touched.
+func (s *Sweeper) sweepOutbox(ctx context.Context, cutoff time.Time) (int64, error) {
+	var total int64
+	for i := 0; i < s.opts.MaxBatches; i++ {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		var n int64
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var ids [][]byte
+			if err := tx.Model(&domain.CartOutbox{}).
+				Where("status = 'PUBLISHED' AND published_at < ?", cutoff).
+				Order("published_at ASC").
+				Limit(s.opts.BatchSize).
+				Pluck("outbox_id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				return nil
+			}
+			if s.opts.ArchiveOutbox {
+				if err := tx.Exec(`
+					INSERT INTO cart_outbox_archive
+						(outbox_id, aggregate_type, aggregate_id, event_type, payload, status, created_at, published_at, archived_at)
+					SELECT outbox_id, aggregate_type, aggregate_id, event_type, payload, status, created_at, published_at, ?
+					FROM cart_outbox WHERE outbox_id IN ?
+				`, s.now().UTC(), ids).Error; err != nil {
+					return err
+				}
+			}
+			res := tx.Where("outbox_id IN ?", ids).Delete(&domain.CartOutbox{})
+			n = res.RowsAffected
+			return res.Error
+		})
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if s.opts.ArchiveOutbox {
+			metrics.Add(metricOutboxArchived, n)
+		} else {
+			metrics.Add(metricOutboxDeleted, n)
+		}
+		if n < int64(s.opts.BatchSize) {
+			break
+		}
+	}
+	return total, nil
+}
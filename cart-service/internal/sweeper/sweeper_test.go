@@ -0,0 +1,231 @@
+package sweeper
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeDB stands in for MySQL behind gorm: it keeps just enough of
+// cart_idempotency and cart_outbox to answer the sweeper's statements, and
+// logs every statement, commit and rollback in order.
+type fakeDB struct {
+	expiredKeys int         // cart_idempotency rows past expires_at
+	outbox      []outboxRow // PUBLISHED cart_outbox rows
+	archived    []string    // outbox_ids copied to cart_outbox_archive
+	log         []string
+	cutoffs     []time.Time // time arguments the sweeper compared against
+}
+
+type outboxRow struct {
+	id          string
+	publishedAt time.Time
+}
+
+func (f *fakeDB) exec(query string, args []driver.NamedValue) (int64, error) {
+	switch {
+	case strings.HasPrefix(query, "DELETE FROM cart_idempotency"):
+		f.log = append(f.log, "DELETE idempotency")
+		f.cutoffs = append(f.cutoffs, args[0].Value.(time.Time))
+		n := min(args[1].Value.(int64), int64(f.expiredKeys))
+		f.expiredKeys -= int(n)
+		return n, nil
+	case strings.Contains(query, "INSERT INTO cart_outbox_archive"):
+		f.log = append(f.log, "ARCHIVE outbox")
+		for _, a := range args[1:] {
+			f.archived = append(f.archived, string(a.Value.([]byte)))
+		}
+		return int64(len(args) - 1), nil
+	case strings.HasPrefix(query, "DELETE FROM `cart_outbox`"):
+		f.log = append(f.log, "DELETE outbox")
+		gone := map[string]bool{}
+		for _, a := range args {
+			gone[string(a.Value.([]byte))] = true
+		}
+		kept := f.outbox[:0]
+		for _, r := range f.outbox {
+			if !gone[r.id] {
+				kept = append(kept, r)
+			}
+		}
+		n := int64(len(f.outbox) - len(kept))
+		f.outbox = kept
+		return n, nil
+	}
+	return 0, fmt.Errorf("fake: unexpected exec %q", query)
+}
+
+func (f *fakeDB) query(query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !strings.HasPrefix(query, "SELECT `outbox_id` FROM `cart_outbox`") {
+		return nil, fmt.Errorf("fake: unexpected query %q", query)
+	}
+	f.log = append(f.log, "SELECT outbox")
+	cutoff := args[0].Value.(time.Time)
+	f.cutoffs = append(f.cutoffs, cutoff)
+	limit := int(args[1].Value.(int64))
+
+	var due []outboxRow
+	for _, r := range f.outbox {
+		if r.publishedAt.Before(cutoff) {
+			due = append(due, r)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].publishedAt.Before(due[j].publishedAt) })
+	rows := &fakeRows{}
+	for i := 0; i < len(due) && i < limit; i++ {
+		rows.ids = append(rows.ids, []byte(due[i].id))
+	}
+	return rows, nil
+}
+
+// The database/sql driver plumbing around fakeDB.
+
+type fakeConnector struct{ f *fakeDB }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn(c), nil }
+func (c fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ f *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c fakeConn) Close() error                        { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)           { return fakeTx(c), nil }
+
+func (c fakeConn) ExecContext(_ context.Context, q string, args []driver.NamedValue) (_ driver.Result, err error) {
+	defer recoverFake(q, &err)
+	n, err := c.f.exec(q, args)
+	return driver.RowsAffected(n), err
+}
+
+func (c fakeConn) QueryContext(_ context.Context, q string, args []driver.NamedValue) (_ driver.Rows, err error) {
+	defer recoverFake(q, &err)
+	return c.f.query(q, args)
+}
+
+// recoverFake fails a statement the fake cannot read; a panic inside a
+// database/sql transaction would deadlock its rollback instead.
+func recoverFake(q string, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("fake: %q: %v", q, r)
+	}
+}
+
+type fakeTx struct{ f *fakeDB }
+
+func (t fakeTx) Commit() error   { t.f.log = append(t.f.log, "COMMIT"); return nil }
+func (t fakeTx) Rollback() error { t.f.log = append(t.f.log, "ROLLBACK"); return nil }
+
+type fakeRows struct {
+	ids  [][]byte
+	next int
+}
+
+func (r *fakeRows) Columns() []string { return []string{"outbox_id"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next == len(r.ids) {
+		return io.EOF
+	}
+	dest[0] = r.ids[r.next]
+	r.next++
+	return nil
+}
+
+func newTestSweeper(t *testing.T, f *fakeDB, now time.Time, opts Options) *Sweeper {
+	t.Helper()
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sql.OpenDB(fakeConnector{f}),
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(db, opts)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestSweepIdempotencyBatches(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name                 string
+		expired              int
+		batchSize, batches   int
+		wantDeleted, wantRun int // rows deleted and DELETEs run
+	}{
+		{"stops at a short batch", 5, 2, 10, 5, 3},
+		{"stops at max batches", 7, 2, 3, 6, 3},
+		{"nothing expired", 0, 2, 3, 0, 1},
+	}
+	for _, tc := range tests {
+		f := &fakeDB{expiredKeys: tc.expired}
+		s := newTestSweeper(t, f, now, Options{BatchSize: tc.batchSize, MaxBatches: tc.batches})
+		n, err := s.sweepIdempotency(context.Background(), now)
+		if err != nil || n != int64(tc.wantDeleted) || len(f.log) != tc.wantRun {
+			t.Errorf("%s: deleted %d (%v) in %v, want %d in %d DELETEs", tc.name, n, err, f.log, tc.wantDeleted, tc.wantRun)
+		}
+		for _, c := range f.cutoffs {
+			if !c.Equal(now) {
+				t.Errorf("%s: deleted keys expiring before %s, want %s", tc.name, c, now)
+			}
+		}
+	}
+}
+
+func TestSweepOutboxRetention(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	f := &fakeDB{outbox: []outboxRow{
+		{"kept", now.Add(-6 * 24 * time.Hour)},
+		{"old", now.Add(-8 * 24 * time.Hour)},
+		{"just-old", now.Add(-7*24*time.Hour - time.Second)},
+		{"just-new", now.Add(-7 * 24 * time.Hour)},
+	}}
+	s := newTestSweeper(t, f, now, Options{BatchSize: 1, MaxBatches: 10, OutboxRetention: 7 * 24 * time.Hour})
+	s.SweepOnce(context.Background())
+
+	var left []string
+	for _, r := range f.outbox {
+		left = append(left, r.id)
+	}
+	if strings.Join(left, ",") != "kept,just-new" {
+		t.Errorf("outbox left %v, want rows published within the retention", left)
+	}
+	for _, c := range f.cutoffs[1:] { // [0] is the idempotency sweep's now
+		if want := now.Add(-7 * 24 * time.Hour); !c.Equal(want) {
+			t.Errorf("outbox cutoff %s, want %s", c, want)
+		}
+	}
+	if len(f.archived) != 0 {
+		t.Errorf("archived %v without ArchiveOutbox", f.archived)
+	}
+}
+
+func TestSweepOutboxArchivesBeforeDeleting(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-30 * 24 * time.Hour)
+	f := &fakeDB{outbox: []outboxRow{{"a", old}, {"b", old.Add(time.Minute)}, {"c", old.Add(2 * time.Minute)}}}
+	s := newTestSweeper(t, f, now, Options{BatchSize: 2, MaxBatches: 10, ArchiveOutbox: true})
+
+	n, err := s.sweepOutbox(context.Background(), now.Add(-s.opts.OutboxRetention))
+	if err != nil || n != 3 || len(f.outbox) != 0 {
+		t.Fatalf("swept %d (%v), %d rows left", n, err, len(f.outbox))
+	}
+	if got := strings.Join(f.archived, ","); got != "a,b,c" {
+		t.Errorf("archived %s, want a,b,c oldest first", got)
+	}
+	batch := []string{"SELECT outbox", "ARCHIVE outbox", "DELETE outbox", "COMMIT"}
+	want := append(append([]string{}, batch...), batch...)
+	if got := strings.Join(f.log, "; "); got != strings.Join(want, "; ") {
+		t.Errorf("statements:\n got %s\nwant %s", got, strings.Join(want, "; "))
+	}
+}
@@ -4,6 +4,7 @@ go 1.25.0
 
 require (
 	github.com/IBM/sarama v1.47.0
+	github.com/dhananjayksharma/golang-k8s-microservices/pkg/cloudevents v0.0.0
 	github.com/gin-gonic/gin v1.12.0
 )
 
@@ -50,3 +51,5 @@ require (
 	golang.org/x/text v0.34.0 // indirect
 	google.golang.org/protobuf v1.36.10 // indirect
 )
+
+replace github.com/dhananjayksharma/golang-k8s-microservices/pkg/cloudevents => ../pkg/cloudevents
@@ -0,0 +1,39 @@
+package kafka
+
+import (
+	"github.com/IBM/sarama"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/pkg/cloudevents"
+)
+
+// Source is the CloudEvents source of events published by this service.
+const Source = "/kafka-order-service"
+
+// PublishEvent sends e as a CloudEvent in the producer's mode.
+func (p *Producer) PublishEvent(key string, e cloudevents.Event) (partition int32, offset int64, err error) {
+	value, headers, err := cloudevents.Encode(e, p.mode)
+	if err != nil {
+		return 0, 0, err
+	}
+	msg := &sarama.ProducerMessage{
+		Topic: p.topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.ByteEncoder(value),
+	}
+	for _, h := range headers {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(h.Key), Value: h.Value})
+	}
+	return p.sp.SendMessage(msg)
+}
+
+// decodeEvent reads a CloudEvent from msg in either mode. Messages that are
+// not CloudEvents return cloudevents.ErrNotCloudEvent.
+func decodeEvent(msg *sarama.ConsumerMessage) (cloudevents.Event, error) {
+	headers := make([]cloudevents.Header, 0, len(msg.Headers))
+	for _, h := range msg.Headers {
+		if h != nil {
+			headers = append(headers, cloudevents.Header{Key: string(h.Key), Value: h.Value})
+		}
+	}
+	return cloudevents.Decode(headers, msg.Value)
+}
@@ -3,9 +3,12 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 
 	"github.com/IBM/sarama"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/pkg/cloudevents"
 )
 
 type Consumer struct {
@@ -37,8 +40,21 @@ func (h handler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
 
 func (h handler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	for msg := range claim.Messages() {
+		// CloudEvents carry the order in their data; older records are the
+		// bare event.
+		data := msg.Value
+		ce, err := decodeEvent(msg)
+		switch {
+		case err == nil:
+			data = ce.Data
+		case !errors.Is(err, cloudevents.ErrNotCloudEvent):
+			log.Printf("❌ bad cloudevent: %v", err)
+			sess.MarkMessage(msg, "bad_cloudevent")
+			continue
+		}
+
 		var ev OrderCreatedEvent
-		if err := json.Unmarshal(msg.Value, &ev); err != nil {
+		if err := json.Unmarshal(data, &ev); err != nil {
 			log.Printf("❌ bad message: %v value=%s", err, string(msg.Value))
 			// mark consumed to avoid poison-loop (or send to DLQ in real systems)
 			sess.MarkMessage(msg, "bad_json")
@@ -6,14 +6,19 @@ import (
 	"time"
 
 	"github.com/IBM/sarama"
+
+	"github.com/dhananjayksharma/golang-k8s-microservices/pkg/cloudevents"
 )
 
 type Producer struct {
 	topic string
+	mode  cloudevents.Mode
 	sp    sarama.SyncProducer
 }
 
-func NewProducer(brokers []string, topic string) (*Producer, error) {
+// NewProducer publishes to topic as CloudEvents in mode (binary or
+// structured).
+func NewProducer(brokers []string, topic string, mode cloudevents.Mode) (*Producer, error) {
 	cfg := sarama.NewConfig()
 	cfg.Version = sarama.V2_6_0_0
 	cfg.Producer.RequiredAcks = sarama.WaitForAll
@@ -27,7 +32,7 @@ func NewProducer(brokers []string, topic string) (*Producer, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Producer{topic: topic, sp: sp}, nil
+	return &Producer{topic: topic, mode: mode, sp: sp}, nil
 }
 
 func (p *Producer) Close() error { return p.sp.Close() }
@@ -42,20 +47,24 @@ type OrderCreatedEvent struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// PublishOrderCreated sends ev as a CloudEvent whose data is ev itself, so
+// in binary mode the record value is the same JSON as before.
 func (p *Producer) PublishOrderCreated(ev OrderCreatedEvent) error {
 	b, err := json.Marshal(ev)
 	if err != nil {
 		return err
 	}
 
-	msg := &sarama.ProducerMessage{
-		Topic: p.topic,
-		// Key helps preserve order for the same order/user
-		Key:   sarama.StringEncoder(ev.OrderID),
-		Value: sarama.ByteEncoder(b),
-	}
-
-	partition, offset, err := p.sp.SendMessage(msg)
+	// Key helps preserve order for the same order/user
+	partition, offset, err := p.PublishEvent(ev.OrderID, cloudevents.Event{
+		ID:              ev.EventID,
+		Source:          Source,
+		Type:            ev.EventType,
+		Subject:         ev.OrderID,
+		DataContentType: cloudevents.ContentTypeJSON,
+		Time:            ev.CreatedAt,
+		Data:            b,
+	})
 	if err != nil {
 		return err
 	}
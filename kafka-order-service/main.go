@@ -13,6 +13,7 @@ import (
 
 	"kafka-order-service/kafka"
 
+	"github.com/dhananjayksharma/golang-k8s-microservices/pkg/cloudevents"
 	"github.com/gin-gonic/gin"
 )
 
@@ -66,7 +67,14 @@ func main() {
 		log.Fatal("KAFKA_GROUP_ID is empty")
 	}
 
-	producer, err := kafka.NewProducer(brokers, topic)
+	// binary keeps the record value the plain order JSON; structured wraps
+	// it in the CloudEvents JSON envelope.
+	mode, err := cloudevents.ParseMode(getenv("CLOUDEVENTS_MODE", "binary"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	producer, err := kafka.NewProducer(brokers, topic, mode)
 	if err != nil {
 		log.Fatal(err)
 	}
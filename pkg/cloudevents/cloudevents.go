@@ -0,0 +1,152 @@
+// Package cloudevents encodes and decodes CloudEvents 1.0 for Kafka, in
+// both modes of the Kafka protocol binding:
+//
+//   - binary: attributes travel as ce_* headers, datacontenttype as the
+//     content-type header, and the record value is the event data as is;
+//   - structured: the record value is the JSON event format
+//     (application/cloudevents+json) with attributes and data in one object.
+//
+// It has no Kafka client dependency. Services adapt Header to their client's
+// header type (segmentio kafka-go, sarama) in their own kafka package.
+package cloudevents
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// SpecVersion is the only CloudEvents version this package reads and writes.
+const SpecVersion = "1.0"
+
+// Content types.
+const (
+	ContentTypeJSON       = "application/json"
+	ContentTypeStructured = "application/cloudevents+json"
+)
+
+// Mode is how an event is laid out in a Kafka record.
+type Mode string
+
+const (
+	Binary     Mode = "binary"
+	Structured Mode = "structured"
+)
+
+// ParseMode parses "binary" or "structured".
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case Binary, Structured:
+		return m, nil
+	}
+	return "", fmt.Errorf("cloudevents: unknown mode %q", s)
+}
+
+var (
+	// ErrNotCloudEvent means a record carries neither ce_* headers nor a
+	// structured content type.
+	ErrNotCloudEvent = errors.New("cloudevents: record is not a cloudevent")
+	// ErrInvalid means an event is missing or has malformed attributes.
+	ErrInvalid = errors.New("cloudevents: invalid event")
+)
+
+// Event is a CloudEvent. ID, Source and Type are required; SpecVersion
+// defaults to 1.0 when encoding. Data is the raw payload in DataContentType,
+// which defaults to application/json when Data is set.
+type Event struct {
+	SpecVersion     string
+	ID              string
+	Source          string // URI-reference, e.g. "/cart-service"
+	Type            string
+	Subject         string
+	DataContentType string
+	DataSchema      string // URI
+	Time            time.Time
+
+	// Extensions are extension attributes such as traceparent. Values are
+	// kept in their canonical string form, which is how binary mode carries
+	// them anyway.
+	Extensions map[string]string
+
+	Data []byte
+}
+
+// Header is a Kafka record header.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// reserved are attribute names an extension may not use, plus the member
+// names the JSON format gives to data.
+var reserved = map[string]bool{
+	"specversion": true, "id": true, "source": true, "type": true,
+	"subject": true, "datacontenttype": true, "dataschema": true, "time": true,
+	"data": true, "data_base64": true,
+}
+
+// Validate checks the required attributes and extension names.
+func (e Event) Validate() error {
+	if e.SpecVersion != "" && e.SpecVersion != SpecVersion {
+		return fmt.Errorf("%w: specversion %q", ErrInvalid, e.SpecVersion)
+	}
+	switch {
+	case e.ID == "":
+		return fmt.Errorf("%w: missing id", ErrInvalid)
+	case e.Source == "":
+		return fmt.Errorf("%w: missing source", ErrInvalid)
+	case e.Type == "":
+		return fmt.Errorf("%w: missing type", ErrInvalid)
+	}
+	if e.DataContentType != "" {
+		if _, _, err := mime.ParseMediaType(e.DataContentType); err != nil {
+			return fmt.Errorf("%w: datacontenttype %q", ErrInvalid, e.DataContentType)
+		}
+	}
+	for name := range e.Extensions {
+		if !validName(name) || reserved[name] {
+			return fmt.Errorf("%w: extension name %q", ErrInvalid, name)
+		}
+	}
+	return nil
+}
+
+// validName reports whether name is a legal attribute name: lower-case ASCII
+// letters and digits only.
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// isJSON reports whether a content type is JSON (application/json or any
+// +json suffix), which the structured format embeds as a JSON value rather
+// than base64.
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mt == ContentTypeJSON || mt == "text/json" || strings.HasSuffix(mt, "+json")
+}
+
+func (e Event) withDefaults() Event {
+	if e.SpecVersion == "" {
+		e.SpecVersion = SpecVersion
+	}
+	if e.DataContentType == "" && e.Data != nil {
+		e.DataContentType = ContentTypeJSON
+	}
+	return e
+}
@@ -0,0 +1,242 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sample() Event {
+	return Event{
+		ID:         "0b7e6a52-1c1a-4b0e-9d61-3f2f1f1b2c3d",
+		Source:     "/cart-service",
+		Type:       "CartItemAdded.v2",
+		Subject:    "5f0c1b3e-8a7d-4e51-9f34-2a6b7c8d9e0f",
+		Time:       time.Date(2026, 3, 1, 10, 30, 0, 123000000, time.UTC),
+		Extensions: map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		Data:       []byte(`{"sku":"SOCK-001","qty":2}`),
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	binaryData := sample()
+	binaryData.DataContentType = "application/octet-stream"
+	binaryData.Data = []byte{0, 1, 2, 0xff}
+
+	noData := sample()
+	noData.Data = nil
+
+	for _, mode := range []Mode{Binary, Structured} {
+		for name, in := range map[string]Event{"json": sample(), "octets": binaryData, "no data": noData} {
+			value, headers, err := Encode(in, mode)
+			if err != nil {
+				t.Fatalf("%s/%s: encode: %v", mode, name, err)
+			}
+			got, err := Decode(headers, value)
+			if err != nil {
+				t.Fatalf("%s/%s: decode: %v", mode, name, err)
+			}
+			want := in.withDefaults()
+			if mode == Structured && want.Data != nil && isJSON(want.DataContentType) {
+				// The JSON format may re-space embedded data; compare it as JSON.
+				if !jsonEqual(t, got.Data, want.Data) {
+					t.Errorf("%s/%s: data = %s, want %s", mode, name, got.Data, want.Data)
+				}
+				got.Data, want.Data = nil, nil
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s/%s:\n got %+v\nwant %+v", mode, name, got, want)
+			}
+		}
+	}
+}
+
+func TestEncodeBinary(t *testing.T) {
+	value, headers, err := EncodeBinary(sample())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != `{"sku":"SOCK-001","qty":2}` {
+		t.Errorf("value = %s", value)
+	}
+	want := []string{
+		"content-type=application/json",
+		"ce_id=0b7e6a52-1c1a-4b0e-9d61-3f2f1f1b2c3d",
+		"ce_source=/cart-service",
+		"ce_specversion=1.0",
+		"ce_subject=5f0c1b3e-8a7d-4e51-9f34-2a6b7c8d9e0f",
+		"ce_time=2026-03-01T10:30:00.123Z",
+		"ce_traceparent=00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"ce_type=CartItemAdded.v2",
+	}
+	var got []string
+	for _, h := range headers {
+		got = append(got, h.Key+"="+string(h.Value))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("headers:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestEncodeStructured(t *testing.T) {
+	value, headers, err := EncodeStructured(sample())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 1 || headers[0].Key != "content-type" || string(headers[0].Value) != "application/cloudevents+json; charset=UTF-8" {
+		t.Errorf("headers = %q", headers)
+	}
+	want := `{
+		"specversion":"1.0","id":"0b7e6a52-1c1a-4b0e-9d61-3f2f1f1b2c3d","source":"/cart-service",
+		"type":"CartItemAdded.v2","subject":"5f0c1b3e-8a7d-4e51-9f34-2a6b7c8d9e0f",
+		"time":"2026-03-01T10:30:00.123Z","datacontenttype":"application/json",
+		"traceparent":"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"data":{"sku":"SOCK-001","qty":2}
+	}`
+	if !jsonEqual(t, value, []byte(want)) {
+		t.Errorf("value = %s", value)
+	}
+
+	e := sample()
+	e.Data = []byte(`{`)
+	if _, _, err := EncodeStructured(e); !errors.Is(err, ErrInvalid) {
+		t.Errorf("invalid JSON data: err = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(*Event)
+		wantErr string
+	}{
+		{"valid", func(*Event) {}, ""},
+		{"missing id", func(e *Event) { e.ID = "" }, "missing id"},
+		{"missing source", func(e *Event) { e.Source = "" }, "missing source"},
+		{"missing type", func(e *Event) { e.Type = "" }, "missing type"},
+		{"other specversion", func(e *Event) { e.SpecVersion = "0.3" }, `specversion "0.3"`},
+		{"bad content type", func(e *Event) { e.DataContentType = "application json" }, "datacontenttype"},
+		{"upper-case extension", func(e *Event) { e.Extensions = map[string]string{"traceParent": "x"} }, "extension name"},
+		{"reserved extension", func(e *Event) { e.Extensions = map[string]string{"data": "x"} }, "extension name"},
+	}
+	for _, tc := range tests {
+		e := sample()
+		tc.edit(&e)
+		err := e.Validate()
+		switch {
+		case tc.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tc.name, err)
+		case tc.wantErr != "" && (!errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), tc.wantErr)):
+			t.Errorf("%s: err = %v, want %q", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []Header
+		value   string
+		want    Event
+		wantErr error
+	}{
+		{
+			name:    "plain JSON record",
+			headers: []Header{{Key: "content-type", Value: []byte("application/json")}},
+			value:   `{"event_type":"OrderCreated"}`,
+			wantErr: ErrNotCloudEvent,
+		},
+		{
+			name: "binary, mixed-case keys, no content type",
+			headers: []Header{
+				{Key: "CE_SpecVersion", Value: []byte("1.0")}, {Key: "ce_id", Value: []byte("1")},
+				{Key: "ce_source", Value: []byte("/catalog")}, {Key: "ce_type", Value: []byte("CatalogPriceChanged.v1")},
+				{Key: "ce_partitionkey", Value: []byte("SOCK-001")}, {Key: "event_type", Value: []byte("ignored")},
+			},
+			value: `{"sku":"SOCK-001"}`,
+			want: Event{
+				SpecVersion: "1.0", ID: "1", Source: "/catalog", Type: "CatalogPriceChanged.v1",
+				Extensions: map[string]string{"partitionkey": "SOCK-001"}, Data: []byte(`{"sku":"SOCK-001"}`),
+			},
+		},
+		{
+			name:    "binary without id",
+			headers: []Header{{Key: "ce_specversion", Value: []byte("1.0")}, {Key: "ce_source", Value: []byte("/x")}, {Key: "ce_type", Value: []byte("T")}},
+			wantErr: ErrInvalid,
+		},
+		{
+			name:    "binary with bad time",
+			headers: []Header{{Key: "ce_specversion", Value: []byte("1.0")}, {Key: "ce_time", Value: []byte("yesterday")}},
+			wantErr: ErrInvalid,
+		},
+		{
+			name:    "structured, non-string extensions",
+			headers: []Header{{Key: "Content-Type", Value: []byte("application/cloudevents+json")}},
+			value:   `{"specversion":"1.0","id":"1","source":"/x","type":"T","seq":42,"replayed":true,"gone":null,"data":null}`,
+			want: Event{
+				SpecVersion: "1.0", ID: "1", Source: "/x", Type: "T",
+				Extensions: map[string]string{"seq": "42", "replayed": "true"},
+			},
+		},
+		{
+			name:    "structured, data and data_base64",
+			headers: []Header{{Key: "content-type", Value: []byte("application/cloudevents+json")}},
+			value:   `{"specversion":"1.0","id":"1","source":"/x","type":"T","data":{},"data_base64":"AA=="}`,
+			wantErr: ErrInvalid,
+		},
+		{
+			name:    "structured, object extension",
+			headers: []Header{{Key: "content-type", Value: []byte("application/cloudevents+json")}},
+			value:   `{"specversion":"1.0","id":"1","source":"/x","type":"T","meta":{}}`,
+			wantErr: ErrInvalid,
+		},
+		{
+			name:    "structured, not JSON",
+			headers: []Header{{Key: "content-type", Value: []byte("application/cloudevents+json")}},
+			value:   `{`,
+			wantErr: ErrInvalid,
+		},
+	}
+	for _, tc := range tests {
+		got, err := Decode(tc.headers, []byte(tc.value))
+		if tc.wantErr != nil {
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("%s: err = %v, want %v", tc.name, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for in, want := range map[string]Mode{"binary": Binary, " Structured ": Structured} {
+		if got, err := ParseMode(in); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseMode("batch"); err == nil {
+		t.Error("ParseMode(batch) succeeded")
+	}
+}
+
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var x, y any
+	if err := json.Unmarshal(a, &x); err != nil {
+		t.Fatalf("%s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &y); err != nil {
+		t.Fatalf("%s: %v", b, err)
+	}
+	return reflect.DeepEqual(x, y)
+}
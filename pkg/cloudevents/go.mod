@@ -0,0 +1,3 @@
+module github.com/dhananjayksharma/golang-k8s-microservices/pkg/cloudevents
+
+go 1.25
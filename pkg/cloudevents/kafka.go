@@ -0,0 +1,274 @@
+package cloudevents
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	headerPrefix      = "ce_"
+	headerContentType = "content-type"
+)
+
+// Encode lays e out as a Kafka record value and headers in the given mode.
+func Encode(e Event, mode Mode) (value []byte, headers []Header, err error) {
+	switch mode {
+	case Binary:
+		return EncodeBinary(e)
+	case Structured:
+		return EncodeStructured(e)
+	}
+	return nil, nil, fmt.Errorf("cloudevents: unknown mode %q", mode)
+}
+
+// EncodeBinary returns e.Data as the value and the attributes as ce_*
+// headers, with datacontenttype as content-type. Headers are sorted so equal
+// events encode identically.
+func EncodeBinary(e Event) ([]byte, []Header, error) {
+	if err := e.Validate(); err != nil {
+		return nil, nil, err
+	}
+	e = e.withDefaults()
+
+	attrs := attributes(e)
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := make([]Header, 0, len(names)+1)
+	if e.DataContentType != "" {
+		headers = append(headers, Header{Key: headerContentType, Value: []byte(e.DataContentType)})
+	}
+	for _, name := range names {
+		headers = append(headers, Header{Key: headerPrefix + name, Value: []byte(attrs[name])})
+	}
+	return e.Data, headers, nil
+}
+
+// EncodeStructured returns the JSON event format as the value and a single
+// content-type header. JSON data is embedded as "data"; anything else as
+// "data_base64".
+func EncodeStructured(e Event) ([]byte, []Header, error) {
+	if err := e.Validate(); err != nil {
+		return nil, nil, err
+	}
+	e = e.withDefaults()
+
+	obj := make(map[string]any, len(e.Extensions)+9)
+	for name, v := range attributes(e) {
+		obj[name] = v
+	}
+	if e.DataContentType != "" {
+		obj["datacontenttype"] = e.DataContentType
+	}
+	if e.Data != nil {
+		if isJSON(e.DataContentType) {
+			if !json.Valid(e.Data) {
+				return nil, nil, fmt.Errorf("%w: data is not valid %s", ErrInvalid, e.DataContentType)
+			}
+			obj["data"] = json.RawMessage(e.Data)
+		} else {
+			obj["data_base64"] = base64.StdEncoding.EncodeToString(e.Data)
+		}
+	}
+	value, err := json.Marshal(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, []Header{{Key: headerContentType, Value: []byte(ContentTypeStructured + "; charset=UTF-8")}}, nil
+}
+
+// attributes returns every set attribute except datacontenttype, which binary
+// mode carries as content-type, in canonical string form.
+func attributes(e Event) map[string]string {
+	attrs := make(map[string]string, len(e.Extensions)+7)
+	for name, v := range e.Extensions {
+		attrs[name] = v
+	}
+	attrs["specversion"] = e.SpecVersion
+	attrs["id"] = e.ID
+	attrs["source"] = e.Source
+	attrs["type"] = e.Type
+	if e.Subject != "" {
+		attrs["subject"] = e.Subject
+	}
+	if e.DataSchema != "" {
+		attrs["dataschema"] = e.DataSchema
+	}
+	if !e.Time.IsZero() {
+		attrs["time"] = e.Time.UTC().Format(time.RFC3339Nano)
+	}
+	return attrs
+}
+
+// Decode reads a CloudEvent from a Kafka record in either mode: a
+// content-type of application/cloudevents+json means structured, a
+// ce_specversion header means binary. Other records return ErrNotCloudEvent.
+// Header keys are matched case-insensitively.
+func Decode(headers []Header, value []byte) (Event, error) {
+	contentType := ""
+	binary := false
+	for _, h := range headers {
+		key := strings.ToLower(h.Key)
+		switch {
+		case key == headerContentType:
+			contentType = string(h.Value)
+		case key == headerPrefix+"specversion":
+			binary = true
+		}
+	}
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil && mt == ContentTypeStructured {
+		return decodeStructured(value)
+	}
+	if binary {
+		return decodeBinary(headers, contentType, value)
+	}
+	return Event{}, ErrNotCloudEvent
+}
+
+func decodeBinary(headers []Header, contentType string, value []byte) (Event, error) {
+	e := Event{DataContentType: contentType, Data: value}
+	for _, h := range headers {
+		key := strings.ToLower(h.Key)
+		if !strings.HasPrefix(key, headerPrefix) {
+			continue
+		}
+		if err := e.set(strings.TrimPrefix(key, headerPrefix), string(h.Value)); err != nil {
+			return Event{}, err
+		}
+	}
+	if err := e.check(); err != nil {
+		return Event{}, err
+	}
+	return e, nil
+}
+
+func decodeStructured(value []byte) (Event, error) {
+	var obj map[string]json.RawMessage
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return Event{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	var e Event
+	for name, raw := range obj {
+		switch name {
+		case "data":
+			if !bytes.Equal(raw, []byte("null")) {
+				e.Data = raw
+			}
+			continue
+		case "data_base64":
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return Event{}, fmt.Errorf("%w: data_base64 is not a string", ErrInvalid)
+			}
+			data, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return Event{}, fmt.Errorf("%w: data_base64: %v", ErrInvalid, err)
+			}
+			e.Data = data
+			continue
+		}
+		v, err := scalar(raw)
+		if err != nil {
+			return Event{}, fmt.Errorf("%w: attribute %q: %v", ErrInvalid, name, err)
+		}
+		if err := e.set(name, v); err != nil {
+			return Event{}, err
+		}
+	}
+	if _, ok := obj["data"]; ok {
+		if _, ok := obj["data_base64"]; ok {
+			return Event{}, fmt.Errorf("%w: both data and data_base64", ErrInvalid)
+		}
+	}
+	if e.Data != nil && e.DataContentType == "" {
+		e.DataContentType = ContentTypeJSON
+	}
+	if err := e.check(); err != nil {
+		return Event{}, err
+	}
+	return e, nil
+}
+
+// scalar returns the canonical string form of a JSON attribute value.
+// Extensions may be strings, numbers or booleans; null means unset.
+func scalar(raw json.RawMessage) (string, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	}
+	return "", fmt.Errorf("is not a string, number or boolean")
+}
+
+// set assigns one decoded attribute.
+func (e *Event) set(name, v string) error {
+	switch name {
+	case "specversion":
+		e.SpecVersion = v
+	case "id":
+		e.ID = v
+	case "source":
+		e.Source = v
+	case "type":
+		e.Type = v
+	case "subject":
+		e.Subject = v
+	case "datacontenttype":
+		e.DataContentType = v
+	case "dataschema":
+		e.DataSchema = v
+	case "time":
+		if v == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return fmt.Errorf("%w: time %q is not RFC 3339", ErrInvalid, v)
+		}
+		e.Time = t
+	default:
+		if v == "" {
+			return nil
+		}
+		if e.Extensions == nil {
+			e.Extensions = map[string]string{}
+		}
+		e.Extensions[name] = v
+	}
+	return nil
+}
+
+// check validates a decoded event, which unlike one being encoded must state
+// its specversion.
+func (e Event) check() error {
+	if e.SpecVersion == "" {
+		return fmt.Errorf("%w: missing specversion", ErrInvalid)
+	}
+	return e.Validate()
+}